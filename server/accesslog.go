@@ -0,0 +1,52 @@
+package server
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const commonLogTimeFormat = "02/Jan/2006:15:04:05 -0700"
+
+// commonLog writes access logs in the Common Log Format used by Apache and nginx.
+type commonLog struct {
+	mu     sync.Mutex
+	writer io.Writer
+}
+
+func (l *commonLog) write(request *http.Request, start time.Time, statusCode int, size int) {
+	host, _, err := net.SplitHostPort(request.RemoteAddr)
+	if err != nil {
+		host = request.RemoteAddr
+	}
+
+	user := "-"
+	if username, _, ok := request.BasicAuth(); ok && username != "" {
+		user = username
+	}
+
+	bytes := "-"
+	if size > 0 {
+		bytes = strconv.Itoa(size)
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	_, _ = fmt.Fprintf(
+		l.writer,
+		"%s - %s [%s] \"%s %s %s\" %d %s\n",
+		host,
+		user,
+		start.Format(commonLogTimeFormat),
+		request.Method,
+		request.URL.RequestURI(),
+		request.Proto,
+		statusCode,
+		bytes,
+	)
+}
@@ -0,0 +1,48 @@
+package server_test
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/b-sea/go-server/server"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCommonLogFormat(t *testing.T) {
+	var buffer bytes.Buffer
+
+	testServer := httptest.NewServer(
+		server.New(context.Background(), &server.NoOpRecorder{}, server.WithCommonLogFormat(&buffer)),
+	)
+
+	request, _ := http.NewRequestWithContext(context.Background(), http.MethodGet, testServer.URL+"/ping?x=1", nil)
+	request.SetBasicAuth("alice", "secret")
+	request.Close = true
+
+	response, err := http.DefaultClient.Do(request)
+	assert.NoError(t, err)
+	assert.NoError(t, response.Body.Close())
+
+	request, _ = http.NewRequestWithContext(context.Background(), http.MethodDelete, testServer.URL+"/ping", nil)
+	request.Close = true
+
+	response, err = http.DefaultClient.Do(request)
+	assert.NoError(t, err)
+	assert.NoError(t, response.Body.Close())
+
+	testServer.Close()
+
+	lines := strings.Split(strings.TrimSuffix(buffer.String(), "\n"), "\n")
+	if !assert.Len(t, lines, 2) {
+		return
+	}
+
+	date := `\[\d{2}/[A-Z][a-z]{2}/\d{4}:\d{2}:\d{2}:\d{2} [+-]\d{4}\]`
+
+	assert.Regexp(t, `^127\.0\.0\.1 - alice `+date+` "GET /ping\?x=1 HTTP/1\.1" 200 4$`, lines[0])
+	assert.Regexp(t, `^127\.0\.0\.1 - - `+date+` "DELETE /ping HTTP/1\.1" 404 19$`, lines[1])
+}
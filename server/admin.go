@@ -0,0 +1,80 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+
+	"github.com/rs/zerolog"
+)
+
+// adminPaths are the paths served on the admin port. Only the built-in endpoints are listed, so application routes
+// that share a prefix, such as /admin, are never treated as operational.
+var adminPaths = []string{ //nolint: gochecknoglobals
+	pingEndpoint,
+	versionEndpoint,
+	metricsEndpoint,
+	healthEndpoint,
+	healthEndpoint + "/",
+	readyEndpoint,
+	routesEndpoint,
+	configEndpoint,
+}
+
+// adminHandler serves the operational endpoints through the main router, so they keep their middleware.
+func (s *Server) adminHandler() http.Handler {
+	handler := http.NewServeMux()
+
+	for _, path := range adminPaths {
+		handler.Handle(path, s.handler())
+	}
+
+	return handler
+}
+
+// startAdmin binds the admin port and serves it in the background.
+func (s *Server) startAdmin(ctx context.Context) error {
+	listener, err := net.Listen("tcp", s.adminAddr)
+	if err != nil {
+		return err //nolint: wrapcheck
+	}
+
+	admin := &http.Server{
+		Addr:              s.adminAddr,
+		Handler:           s.adminHandler(),
+		ReadTimeout:       s.http.ReadTimeout,
+		ReadHeaderTimeout: s.http.ReadHeaderTimeout,
+		WriteTimeout:      s.http.WriteTimeout,
+	}
+
+	s.mu.Lock()
+	s.admin = admin
+	s.mu.Unlock()
+
+	zerolog.Ctx(ctx).Info().Str("addr", listener.Addr().String()).Msg("starting admin server")
+
+	go func() {
+		if err := admin.Serve(listener); !errors.Is(err, http.ErrServerClosed) {
+			zerolog.Ctx(ctx).Error().Err(err).Msg("admin server")
+		}
+	}()
+
+	return nil
+}
+
+// stopAdmin shuts the admin port down, if it is running.
+func (s *Server) stopAdmin(ctx context.Context) error {
+	s.mu.Lock()
+	admin := s.admin
+	s.admin = nil
+	s.mu.Unlock()
+
+	if admin == nil {
+		return nil
+	}
+
+	zerolog.Ctx(ctx).Info().Str("addr", admin.Addr).Msg("stopping admin server")
+
+	return admin.Shutdown(ctx) //nolint: wrapcheck
+}
@@ -0,0 +1,126 @@
+package server_test
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/b-sea/go-server/server"
+	"github.com/stretchr/testify/assert"
+)
+
+func fetch(t *testing.T, url string) (int, string, error) {
+	t.Helper()
+
+	request, _ := http.NewRequestWithContext(context.Background(), http.MethodGet, url, nil)
+	request.Close = true
+
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		return 0, "", err
+	}
+
+	body, err := io.ReadAll(response.Body)
+	assert.NoError(t, err)
+	assert.NoError(t, response.Body.Close())
+
+	return response.StatusCode, string(body), nil
+}
+
+func TestServerAdminPortDraining(t *testing.T) {
+	port := findOpenPort(t)
+	adminPort := findOpenPort(t)
+
+	mainURL := fmt.Sprintf("http://localhost:%d", port)
+	adminURL := fmt.Sprintf("http://localhost:%d", adminPort)
+
+	testServer := server.New(
+		context.Background(),
+		&server.NoOpRecorder{},
+		server.WithPort(port),
+		server.WithAdminPort(adminPort),
+		server.WithRoutesEndpoint(),
+	)
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+
+	testServer.Router().Handle("/slow", http.HandlerFunc(func(writer http.ResponseWriter, _ *http.Request) {
+		close(started)
+		<-release
+
+		_, _ = writer.Write([]byte(`done`))
+	})).Methods(http.MethodGet)
+	testServer.Router().Handle("/admin/jobs", http.HandlerFunc(func(writer http.ResponseWriter, _ *http.Request) {
+		_, _ = writer.Write([]byte(`jobs`))
+	})).Methods(http.MethodGet)
+
+	go func() {
+		assert.NoError(t, testServer.Start(context.Background()))
+	}()
+
+	waitForServer(t, mainURL)
+	waitForServer(t, adminURL)
+
+	code, _, err := fetch(t, adminURL+"/slow")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, code)
+
+	code, _, err = fetch(t, mainURL+"/admin/jobs")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, code)
+
+	code, _, err = fetch(t, adminURL+"/admin/jobs")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, code)
+
+	code, _, err = fetch(t, adminURL+"/admin/routes")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, code)
+
+	slow := make(chan string, 1)
+
+	go func() {
+		_, body, err := fetch(t, mainURL+"/slow")
+		assert.NoError(t, err)
+
+		slow <- body
+	}()
+
+	<-started
+
+	stopped := make(chan error, 1)
+
+	go func() {
+		stopped <- testServer.Stop(context.Background())
+	}()
+
+	assert.Eventually(t, func() bool {
+		code, _, err := fetch(t, adminURL+"/ready?verbose")
+
+		return err == nil && code == http.StatusServiceUnavailable
+	}, 5*time.Second, 10*time.Millisecond)
+
+	code, body, err := fetch(t, adminURL+"/ready?verbose")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Contains(t, body, `"status":"draining"`)
+
+	code, _, err = fetch(t, adminURL+"/health")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, code)
+
+	_, _, err = fetch(t, mainURL+"/ping")
+	assert.Error(t, err)
+
+	close(release)
+
+	assert.Equal(t, "done", <-slow)
+	assert.NoError(t, <-stopped)
+
+	_, _, err = fetch(t, adminURL+"/health")
+	assert.Error(t, err)
+}
@@ -0,0 +1,46 @@
+package server
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+// Authenticator reports whether a request carries valid credentials.
+type Authenticator func(request *http.Request) bool
+
+func requireAuth(authenticate Authenticator, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		if !authenticate(request) {
+			http.Error(writer, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+
+			return
+		}
+
+		next.ServeHTTP(writer, request)
+	})
+}
+
+// authenticate checks a request with the authenticator set when the request arrives, so an authenticator applied after
+// routes are added still guards them. Without an authenticator, every request is rejected so routes fail closed.
+func (s *Server) authenticate(request *http.Request) bool {
+	if s.authenticator == nil {
+		return false
+	}
+
+	return s.authenticator(request)
+}
+
+// AddAuthenticatedHandler registers a route whose requests must pass the authenticator set with WithAuthenticator or
+// receive a 401 Unauthorized, while routes added to the router directly stay public. Without an authenticator, every
+// request to the route is rejected. The authenticator is looked up per request, so it applies to routes added before
+// it was set. The route is returned so it can be configured further.
+func (s *Server) AddAuthenticatedHandler(path string, handler http.Handler, methods ...string) *mux.Route {
+	route := s.router.Handle(path, requireAuth(s.authenticate, handler))
+
+	if len(methods) > 0 {
+		route.Methods(methods...)
+	}
+
+	return route
+}
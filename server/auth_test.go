@@ -0,0 +1,112 @@
+package server_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/b-sea/go-server/server"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAuthenticatedHandler(t *testing.T) {
+	type testCase struct {
+		options      []server.Option
+		path         string
+		apiKey       string
+		expectedCode int
+	}
+
+	withAPIKey := server.WithAuthenticator(func(request *http.Request) bool {
+		return request.Header.Get("X-Api-Key") == "secret"
+	})
+
+	tests := map[string]testCase{
+		"public": {
+			options:      []server.Option{withAPIKey},
+			path:         "/public",
+			apiKey:       "",
+			expectedCode: http.StatusOK,
+		},
+		"no credentials": {
+			options:      []server.Option{withAPIKey},
+			path:         "/private",
+			apiKey:       "",
+			expectedCode: http.StatusUnauthorized,
+		},
+		"bad credentials": {
+			options:      []server.Option{withAPIKey},
+			path:         "/private",
+			apiKey:       "guess",
+			expectedCode: http.StatusUnauthorized,
+		},
+		"credentials": {
+			options:      []server.Option{withAPIKey},
+			path:         "/private",
+			apiKey:       "secret",
+			expectedCode: http.StatusOK,
+		},
+		"no authenticator": {
+			options:      nil,
+			path:         "/private",
+			apiKey:       "secret",
+			expectedCode: http.StatusUnauthorized,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			ok := http.HandlerFunc(func(writer http.ResponseWriter, _ *http.Request) {
+				_, _ = writer.Write([]byte(`ok`))
+			})
+
+			svr := server.New(context.Background(), &server.NoOpRecorder{}, test.options...)
+			svr.Router().Handle("/public", ok).Methods(http.MethodGet)
+			svr.AddAuthenticatedHandler("/private", ok, http.MethodGet)
+
+			testServer := httptest.NewServer(svr)
+			defer testServer.Close()
+
+			request, _ := http.NewRequestWithContext(context.Background(), http.MethodGet, testServer.URL+test.path, nil)
+			request.Close = true
+
+			if test.apiKey != "" {
+				request.Header.Set("X-Api-Key", test.apiKey)
+			}
+
+			response, err := http.DefaultClient.Do(request)
+			assert.NoError(t, err)
+			assert.NoError(t, response.Body.Close())
+
+			assert.Equal(t, test.expectedCode, response.StatusCode)
+		})
+	}
+}
+
+func TestAuthenticatedHandlerLateAuthenticator(t *testing.T) {
+	svr := server.New(context.Background(), &server.NoOpRecorder{})
+	svr.AddAuthenticatedHandler("/private", http.HandlerFunc(func(writer http.ResponseWriter, _ *http.Request) {
+		_, _ = writer.Write([]byte(`ok`))
+	}), http.MethodGet)
+
+	get := func(apiKey string) int {
+		request := httptest.NewRequest(http.MethodGet, "/private", nil)
+		request.Header.Set("X-Api-Key", apiKey)
+
+		response := httptest.NewRecorder()
+		svr.ServeHTTP(response, request)
+
+		return response.Code
+	}
+
+	assert.Equal(t, http.StatusUnauthorized, get("secret"))
+
+	// The authenticator is resolved per request, so one applied after the route is added still guards it
+	server.WithAuthenticator(func(request *http.Request) bool {
+		return request.Header.Get("X-Api-Key") == "secret"
+	})(context.Background(), svr)
+
+	assert.Equal(t, http.StatusOK, get("secret"))
+	assert.Equal(t, http.StatusUnauthorized, get("guess"))
+}
@@ -0,0 +1,101 @@
+package server
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// panicBreaker tracks panics per route and opens the route's circuit once too many happen in a window.
+type panicBreaker struct {
+	mu        sync.Mutex
+	threshold int
+	window    time.Duration
+	panics    map[string][]time.Time
+	openUntil map[string]time.Time
+}
+
+func newPanicBreaker(threshold int, window time.Duration) *panicBreaker {
+	return &panicBreaker{
+		mu:        sync.Mutex{},
+		threshold: threshold,
+		window:    window,
+		panics:    make(map[string][]time.Time),
+		openUntil: make(map[string]time.Time),
+	}
+}
+
+// retryAfter returns how long the route's circuit remains open, or zero if it is closed.
+func (b *panicBreaker) retryAfter(route string, now time.Time) time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	until, ok := b.openUntil[route]
+	if !ok {
+		return 0
+	}
+
+	if !now.Before(until) {
+		delete(b.openUntil, route)
+
+		return 0
+	}
+
+	return until.Sub(now)
+}
+
+func (b *panicBreaker) record(route string, now time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	recent := []time.Time{now}
+
+	for _, panicked := range b.panics[route] {
+		if now.Sub(panicked) < b.window {
+			recent = append(recent, panicked)
+		}
+	}
+
+	if len(recent) < b.threshold {
+		b.panics[route] = recent
+
+		return
+	}
+
+	delete(b.panics, route)
+	b.openUntil[route] = now.Add(b.window)
+}
+
+func (s *Server) panicBreakerMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		route, _ := mux.CurrentRoute(request).GetPathTemplate()
+
+		if wait := s.panicBreaker.retryAfter(route, s.now()); wait > 0 {
+			writer.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			http.Error(writer, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+
+			return
+		}
+
+		defer func() {
+			panicked := recover()
+			if panicked == nil {
+				return
+			}
+
+			if err, ok := panicked.(error); !ok || !errors.Is(err, http.ErrAbortHandler) {
+				s.panicBreaker.record(route, s.now())
+			}
+
+			// Let the telemetry middleware log and respond to the panic
+			panic(panicked)
+		}()
+
+		next.ServeHTTP(writer, request)
+	})
+}
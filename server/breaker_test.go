@@ -0,0 +1,107 @@
+package server_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/b-sea/go-server/server"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPanicCircuitBreaker(t *testing.T) {
+	clock := NewFakeClock()
+	calls := atomic.Int32{}
+
+	svr := server.New(
+		context.Background(),
+		&server.NoOpRecorder{},
+		server.WithClock(clock.Now),
+		server.WithPanicCircuitBreaker(2, time.Minute),
+	)
+
+	svr.Router().Handle(
+		"/boom/{id}",
+		func() http.HandlerFunc {
+			return func(http.ResponseWriter, *http.Request) {
+				calls.Add(1)
+				panic("uh oh!")
+			}
+		}(),
+	).Methods(http.MethodGet)
+
+	testServer := httptest.NewServer(svr)
+
+	get := func(path string) *http.Response {
+		request, _ := http.NewRequestWithContext(context.Background(), http.MethodGet, testServer.URL+path, nil)
+		request.Close = true
+
+		response, err := http.DefaultClient.Do(request)
+		assert.NoError(t, err)
+		assert.NoError(t, response.Body.Close())
+
+		return response
+	}
+
+	assert.Equal(t, http.StatusInternalServerError, get("/boom/1").StatusCode)
+
+	clock.Advance(30 * time.Second)
+	assert.Equal(t, http.StatusInternalServerError, get("/boom/2").StatusCode)
+
+	response := get("/boom/3")
+	assert.Equal(t, http.StatusServiceUnavailable, response.StatusCode)
+	assert.Equal(t, "60", response.Header.Get("Retry-After"))
+	assert.Equal(t, int32(2), calls.Load())
+
+	assert.Equal(t, http.StatusOK, get("/ping").StatusCode)
+
+	clock.Advance(59 * time.Second)
+	assert.Equal(t, http.StatusServiceUnavailable, get("/boom/4").StatusCode)
+	assert.Equal(t, int32(2), calls.Load())
+
+	clock.Advance(time.Second)
+	assert.Equal(t, http.StatusInternalServerError, get("/boom/5").StatusCode)
+	assert.Equal(t, int32(3), calls.Load())
+
+	testServer.Close()
+}
+
+func TestPanicCircuitBreakerWindow(t *testing.T) {
+	clock := NewFakeClock()
+
+	svr := server.New(
+		context.Background(),
+		&server.NoOpRecorder{},
+		server.WithClock(clock.Now),
+		server.WithPanicCircuitBreaker(2, time.Minute),
+	)
+
+	svr.Router().Handle(
+		"/boom",
+		func() http.HandlerFunc {
+			return func(http.ResponseWriter, *http.Request) {
+				panic("uh oh!")
+			}
+		}(),
+	).Methods(http.MethodGet)
+
+	testServer := httptest.NewServer(svr)
+
+	for range 3 {
+		request, _ := http.NewRequestWithContext(context.Background(), http.MethodGet, testServer.URL+"/boom", nil)
+		request.Close = true
+
+		response, err := http.DefaultClient.Do(request)
+		assert.NoError(t, err)
+		assert.NoError(t, response.Body.Close())
+
+		assert.Equal(t, http.StatusInternalServerError, response.StatusCode)
+
+		clock.Advance(time.Minute)
+	}
+
+	testServer.Close()
+}
@@ -0,0 +1,225 @@
+package server
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+)
+
+const (
+	acceptEncodingHeader  = "Accept-Encoding"
+	contentEncodingHeader = "Content-Encoding"
+
+	brotliEncoding   = "br"
+	gzipEncoding     = "gzip"
+	xGzipEncoding    = "x-gzip"
+	deflateEncoding  = "deflate"
+	identityEncoding = "identity"
+	anyEncoding      = "*"
+)
+
+// supportedEncodings lists the response encodings in order of server preference.
+var supportedEncodings = []string{brotliEncoding, gzipEncoding} //nolint: gochecknoglobals
+
+// negotiateEncoding picks the best supported encoding from an Accept-Encoding header by quality value, falling
+// back to identity when nothing acceptable is supported.
+func negotiateEncoding(header string) string {
+	qualities := make(map[string]float64)
+
+	for part := range strings.SplitSeq(header, ",") {
+		coding, params, _ := strings.Cut(part, ";")
+
+		coding = strings.ToLower(strings.TrimSpace(coding))
+		if coding == "" {
+			continue
+		}
+
+		quality := 1.0
+
+		if value, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				continue
+			}
+
+			quality = parsed
+		}
+
+		qualities[coding] = quality
+	}
+
+	best := identityEncoding
+	bestQuality := 0.0
+
+	for _, encoding := range supportedEncodings {
+		quality, ok := qualities[encoding]
+		if !ok {
+			quality, ok = qualities[anyEncoding]
+		}
+
+		if ok && quality > bestQuality {
+			best = encoding
+			bestQuality = quality
+		}
+	}
+
+	return best
+}
+
+type compressWriter struct {
+	http.ResponseWriter
+
+	encoding    string
+	encoder     io.WriteCloser
+	wroteHeader bool
+}
+
+func (w *compressWriter) WriteHeader(statusCode int) {
+	if w.wroteHeader {
+		w.ResponseWriter.WriteHeader(statusCode)
+
+		return
+	}
+
+	w.wroteHeader = true
+
+	if w.shouldCompress(statusCode) {
+		w.Header().Set(contentEncodingHeader, w.encoding)
+		w.Header().Del("Content-Length")
+
+		switch w.encoding {
+		case brotliEncoding:
+			w.encoder = brotli.NewWriter(w.ResponseWriter)
+		default:
+			w.encoder = gzip.NewWriter(w.ResponseWriter)
+		}
+	}
+
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (w *compressWriter) Write(p []byte) (int, error) {
+	if !w.wroteHeader {
+		// Content sniffing would otherwise see the compressed bytes.
+		if w.shouldCompress(http.StatusOK) && w.Header().Get("Content-Type") == "" {
+			w.Header().Set("Content-Type", http.DetectContentType(p))
+		}
+
+		w.WriteHeader(http.StatusOK)
+	}
+
+	if w.encoder == nil {
+		return w.ResponseWriter.Write(p) //nolint: wrapcheck
+	}
+
+	return w.encoder.Write(p) //nolint: wrapcheck
+}
+
+func (w *compressWriter) Close() error {
+	if w.encoder == nil {
+		return nil
+	}
+
+	return w.encoder.Close() //nolint: wrapcheck
+}
+
+// Flush sends any compressed bytes buffered so far, so streaming responses reach the client promptly.
+func (w *compressWriter) Flush() {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+
+	if flusher, ok := w.encoder.(interface{ Flush() error }); ok {
+		_ = flusher.Flush()
+	}
+
+	_ = http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (w *compressWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func (w *compressWriter) shouldCompress(statusCode int) bool {
+	if statusCode < http.StatusOK || statusCode == http.StatusNoContent || statusCode == http.StatusNotModified {
+		return false
+	}
+
+	// Leave responses that are already encoded alone, such as gzipped metrics.
+	return w.Header().Get(contentEncodingHeader) == ""
+}
+
+func compressionMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		writer.Header().Add("Vary", acceptEncodingHeader)
+
+		encoding := negotiateEncoding(request.Header.Get(acceptEncodingHeader))
+		if encoding == identityEncoding || request.Method == http.MethodHead {
+			next.ServeHTTP(writer, request)
+
+			return
+		}
+
+		compressor := &compressWriter{
+			ResponseWriter: writer,
+			encoding:       encoding,
+			encoder:        nil,
+			wroteHeader:    false,
+		}
+		defer func() { _ = compressor.Close() }()
+
+		next.ServeHTTP(compressor, request)
+	})
+}
+
+// decompressedBody reads a decompressed request body and closes the original body.
+type decompressedBody struct {
+	io.Reader
+	io.Closer
+}
+
+// requestDecompressionMiddleware decompresses gzip and deflate request bodies so handlers read plain bytes. The
+// decompressed body is held to the maximum request body size, guarding against decompression bombs.
+func (s *Server) requestDecompressionMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		var (
+			reader io.Reader
+			err    error
+		)
+
+		switch strings.ToLower(strings.TrimSpace(request.Header.Get(contentEncodingHeader))) {
+		case gzipEncoding, xGzipEncoding:
+			reader, err = gzip.NewReader(request.Body)
+		case deflateEncoding:
+			reader, err = zlib.NewReader(request.Body)
+		default:
+			next.ServeHTTP(writer, request)
+
+			return
+		}
+
+		if err != nil {
+			http.Error(writer, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+
+			return
+		}
+
+		var body io.ReadCloser = &decompressedBody{Reader: reader, Closer: request.Body}
+		if s.maxRequestBodySize > 0 {
+			body = http.MaxBytesReader(writer, body, s.maxRequestBodySize)
+		}
+
+		request.Body = body
+		request.ContentLength = -1
+		request.Header.Del(contentEncodingHeader)
+		request.Header.Del("Content-Length")
+
+		next.ServeHTTP(writer, request)
+	})
+}
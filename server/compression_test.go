@@ -0,0 +1,240 @@
+package server_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+	"github.com/b-sea/go-server/server"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompression(t *testing.T) {
+	type testCase struct {
+		acceptEncoding  string
+		contentEncoding string
+		decode          func(io.Reader) io.Reader
+	}
+
+	payload := strings.Repeat("a compressible response body ", 100)
+
+	tests := map[string]testCase{
+		"brotli": {
+			acceptEncoding:  "br",
+			contentEncoding: "br",
+			decode:          func(r io.Reader) io.Reader { return brotli.NewReader(r) },
+		},
+		"gzip": {
+			acceptEncoding:  "gzip",
+			contentEncoding: "gzip",
+			decode: func(r io.Reader) io.Reader {
+				reader, err := gzip.NewReader(r)
+				assert.NoError(t, err)
+
+				return reader
+			},
+		},
+		"prefer brotli": {
+			acceptEncoding:  "gzip, deflate, br",
+			contentEncoding: "br",
+			decode:          func(r io.Reader) io.Reader { return brotli.NewReader(r) },
+		},
+		"quality values": {
+			acceptEncoding:  "br;q=0.5, gzip;q=0.8",
+			contentEncoding: "gzip",
+			decode: func(r io.Reader) io.Reader {
+				reader, err := gzip.NewReader(r)
+				assert.NoError(t, err)
+
+				return reader
+			},
+		},
+		"wildcard": {
+			acceptEncoding:  "br;q=0, *",
+			contentEncoding: "gzip",
+			decode: func(r io.Reader) io.Reader {
+				reader, err := gzip.NewReader(r)
+				assert.NoError(t, err)
+
+				return reader
+			},
+		},
+		"no compression": {
+			acceptEncoding:  "",
+			contentEncoding: "",
+			decode:          func(r io.Reader) io.Reader { return r },
+		},
+		"unsupported": {
+			acceptEncoding:  "deflate, br;q=0",
+			contentEncoding: "",
+			decode:          func(r io.Reader) io.Reader { return r },
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			recorder := &SpyRecorder{}
+			svr := server.New(context.Background(), recorder, server.WithCompression())
+
+			svr.Router().Handle(
+				"/test",
+				func() http.HandlerFunc {
+					return func(writer http.ResponseWriter, _ *http.Request) {
+						_, _ = writer.Write([]byte(payload))
+					}
+				}(),
+			).Methods(http.MethodGet)
+
+			testServer := httptest.NewServer(svr)
+
+			request, _ := http.NewRequestWithContext(context.Background(), http.MethodGet, testServer.URL+"/test", nil)
+			request.Header.Set("Accept-Encoding", test.acceptEncoding)
+			request.Close = true
+
+			response, err := (&http.Transport{DisableCompression: true}).RoundTrip(request)
+			assert.NoError(t, err)
+
+			raw, err := io.ReadAll(response.Body)
+			assert.NoError(t, err)
+
+			assert.NoError(t, response.Body.Close())
+
+			body, err := io.ReadAll(test.decode(bytes.NewReader(raw)))
+			assert.NoError(t, err)
+
+			assert.Equal(t, http.StatusOK, response.StatusCode)
+			assert.Equal(t, test.contentEncoding, response.Header.Get("Content-Encoding"))
+			assert.Equal(t, "text/plain; charset=utf-8", response.Header.Get("Content-Type"))
+			assert.Equal(t, "Accept-Encoding", response.Header.Get("Vary"))
+			assert.Equal(t, payload, string(body))
+
+			testServer.Close()
+
+			sizes := recorder.SizeObservations()
+			if assert.Len(t, sizes, 1) {
+				assert.Equal(t, float64(len(raw)), sizes[0].Value)
+			}
+		})
+	}
+}
+
+func TestRequestDecompression(t *testing.T) {
+	compress := func(encoding string, data string) []byte {
+		var buffer bytes.Buffer
+
+		var writer io.WriteCloser
+
+		switch encoding {
+		case "deflate":
+			writer = zlib.NewWriter(&buffer)
+		default:
+			writer = gzip.NewWriter(&buffer)
+		}
+
+		_, _ = writer.Write([]byte(data))
+		_ = writer.Close()
+
+		return buffer.Bytes()
+	}
+
+	type testCase struct {
+		encoding   string
+		body       []byte
+		statusCode int
+		result     string
+	}
+
+	tests := map[string]testCase{
+		"plain": {
+			encoding:   "",
+			body:       []byte(`hello there`),
+			statusCode: http.StatusOK,
+			result:     "hello there",
+		},
+		"plain too large": {
+			encoding:   "",
+			body:       bytes.Repeat([]byte("0"), 2048),
+			statusCode: http.StatusRequestEntityTooLarge,
+			result:     "Request Entity Too Large\n",
+		},
+		"gzip": {
+			encoding:   "gzip",
+			body:       compress("gzip", `hello there`),
+			statusCode: http.StatusOK,
+			result:     "hello there",
+		},
+		"deflate": {
+			encoding:   "deflate",
+			body:       compress("deflate", `hello there`),
+			statusCode: http.StatusOK,
+			result:     "hello there",
+		},
+		"corrupt": {
+			encoding:   "gzip",
+			body:       []byte(`not gzip`),
+			statusCode: http.StatusBadRequest,
+			result:     "Bad Request\n",
+		},
+		"bomb": {
+			encoding:   "gzip",
+			body:       compress("gzip", strings.Repeat("0", 1<<20)),
+			statusCode: http.StatusRequestEntityTooLarge,
+			result:     "Request Entity Too Large\n",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			svr := server.New(
+				context.Background(),
+				&server.NoOpRecorder{},
+				server.WithRequestDecompression(),
+				server.WithMaxRequestBodySize(1024),
+			)
+			svr.Router().Handle("/echo", server.ErrorHandlerFunc(func(writer http.ResponseWriter, request *http.Request) error {
+				body, err := io.ReadAll(request.Body)
+				if maxErr := (&http.MaxBytesError{}); errors.As(err, &maxErr) {
+					return server.NewHTTPError(http.StatusRequestEntityTooLarge, "")
+				}
+
+				assert.Empty(t, request.Header.Get("Content-Encoding"))
+
+				_, _ = writer.Write(body)
+
+				return nil
+			})).Methods(http.MethodPost)
+
+			testServer := httptest.NewServer(svr)
+
+			request, _ := http.NewRequestWithContext(
+				context.Background(),
+				http.MethodPost,
+				testServer.URL+"/echo",
+				bytes.NewReader(test.body),
+			)
+			request.Header.Set("Content-Encoding", test.encoding)
+			request.Close = true
+
+			response, err := http.DefaultClient.Do(request)
+			assert.NoError(t, err)
+
+			body, err := io.ReadAll(response.Body)
+			assert.NoError(t, err)
+
+			assert.NoError(t, response.Body.Close())
+
+			assert.Equal(t, test.statusCode, response.StatusCode)
+			assert.Equal(t, test.result, string(body))
+
+			testServer.Close()
+		})
+	}
+}
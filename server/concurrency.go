@@ -0,0 +1,42 @@
+package server
+
+import (
+	"net/http"
+	"time"
+)
+
+// concurrencyMiddleware limits how many requests are handled at once. Requests over the limit wait for a slot, and
+// the wait is recorded as the request queue time by recorders that implement QueueRecorder. Requests whose context
+// ends while waiting get a 503 Service Unavailable. The operational endpoints do not take a slot, so probes never
+// queue behind application requests.
+func (s *Server) concurrencyMiddleware(recorder Recorder) func(http.Handler) http.Handler {
+	slots := make(chan struct{}, s.maxConcurrentRequests)
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+			if isOperationalPath(request.URL.Path) {
+				next.ServeHTTP(writer, request)
+
+				return
+			}
+
+			start := time.Now()
+
+			select {
+			case slots <- struct{}{}:
+			case <-request.Context().Done():
+				http.Error(writer, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+
+				return
+			}
+
+			defer func() { <-slots }()
+
+			if queue, ok := observerFor(recorder, metricContext(request.Context())).(QueueRecorder); ok {
+				queue.ObserveHTTPQueueTime(request.Method, s.metricPath(routeTemplate(request)), time.Since(start))
+			}
+
+			next.ServeHTTP(writer, request)
+		})
+	}
+}
@@ -0,0 +1,142 @@
+package server_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/b-sea/go-server/server"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMaxConcurrentRequests(t *testing.T) {
+	type testCase struct {
+		route string
+		path  string
+	}
+
+	tests := map[string]testCase{
+		"application route": {
+			route: "/slow/{id}",
+			path:  "/slow/1",
+		},
+		"application route under admin": {
+			route: "/admin/slow/{id}",
+			path:  "/admin/slow/1",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			recorder := &SpyRecorder{}
+			started := make(chan struct{}, 2)
+			release := make(chan struct{})
+
+			svr := server.New(context.Background(), recorder, server.WithMaxConcurrentRequests(1))
+			svr.Router().Handle(test.route, http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+				started <- struct{}{}
+				<-release
+			})).Methods(http.MethodGet)
+
+			testServer := httptest.NewServer(svr)
+
+			var group sync.WaitGroup
+
+			for range 2 {
+				group.Go(func() {
+					request, _ := http.NewRequestWithContext(context.Background(), http.MethodGet, testServer.URL+test.path, nil)
+					request.Close = true
+
+					response, err := http.DefaultClient.Do(request)
+					assert.NoError(t, err)
+					assert.NoError(t, response.Body.Close())
+					assert.Equal(t, http.StatusOK, response.StatusCode)
+				})
+			}
+
+			// Only one request runs at a time, so the second waits until the first is released
+			<-started
+
+			select {
+			case <-started:
+				t.Fatal("second request was not queued")
+			case <-time.After(200 * time.Millisecond):
+			}
+
+			// Probes do not wait for a slot
+			for _, path := range []string{"/ping", "/health"} {
+				request, _ := http.NewRequestWithContext(context.Background(), http.MethodGet, testServer.URL+path, nil)
+				request.Close = true
+
+				response, err := http.DefaultClient.Do(request)
+				assert.NoError(t, err)
+				assert.NoError(t, response.Body.Close())
+				assert.Equal(t, http.StatusOK, response.StatusCode, path)
+			}
+
+			release <- struct{}{}
+
+			<-started
+			release <- struct{}{}
+
+			group.Wait()
+			testServer.Close()
+
+			observations := recorder.QueueTimeObservations()
+			if assert.Len(t, observations, 2) {
+				slices.SortFunc(observations, func(a, b Observation) int { return int((a.Value - b.Value) * 1e9) })
+
+				assert.Equal(t, test.route, observations[0].Path)
+				assert.Less(t, observations[0].Value, 0.1)
+				assert.GreaterOrEqual(t, observations[1].Value, 0.2)
+			}
+		})
+	}
+}
+
+func TestMaxConcurrentRequestsCancelled(t *testing.T) {
+	recorder := &SpyRecorder{}
+	started := make(chan struct{})
+	release := make(chan struct{})
+	handled := 0
+
+	svr := server.New(context.Background(), recorder, server.WithMaxConcurrentRequests(1))
+	svr.Router().Handle("/slow", http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+		handled++
+
+		close(started)
+		<-release
+	})).Methods(http.MethodGet)
+	svr.Router().Handle("/fast", http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+		handled++
+	})).Methods(http.MethodGet)
+
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+
+		request := httptest.NewRequestWithContext(context.Background(), http.MethodGet, "/slow", nil)
+		svr.ServeHTTP(httptest.NewRecorder(), request)
+	}()
+
+	<-started
+
+	// A client that gives up while queued is answered without running the handler
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	response := httptest.NewRecorder()
+	svr.ServeHTTP(response, httptest.NewRequestWithContext(ctx, http.MethodGet, "/fast", nil))
+
+	close(release)
+	<-done
+
+	assert.Equal(t, http.StatusServiceUnavailable, response.Code)
+	assert.Equal(t, 1, handled)
+	assert.Len(t, recorder.QueueTimeObservations(), 1)
+}
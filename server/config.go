@@ -0,0 +1,99 @@
+package server
+
+import (
+	"maps"
+	"net/http"
+	"slices"
+	"time"
+)
+
+// ConfigInfo describes the effective configuration of the Server. It never includes secrets.
+type ConfigInfo struct {
+	Addr            string                   `json:"addr"`
+	AdminAddr       string                   `json:"admin_addr,omitempty"`
+	TLS             bool                     `json:"tls"`
+	Version         string                   `json:"version,omitempty"`
+	ReadTimeout     time.Duration            `json:"read_timeout"`
+	WriteTimeout    time.Duration            `json:"write_timeout"`
+	ShutdownTimeout time.Duration            `json:"shutdown_timeout"`
+	RequestTimeout  time.Duration            `json:"request_timeout,omitempty"`
+	RequestTimeouts map[string]time.Duration `json:"request_timeouts,omitempty"`
+	Warmup          time.Duration            `json:"warmup,omitempty"`
+	MaxHeaderCount  int                      `json:"max_header_count,omitempty"`
+	MinReadRate     int                      `json:"min_read_rate,omitempty"`
+	MaxRequestBody  int64                    `json:"max_request_body_size,omitempty"`
+	MaxResponseSize int64                    `json:"max_response_size,omitempty"`
+	MaxConcurrent   int                      `json:"max_concurrent_requests,omitempty"`
+	DefaultHeaders  []string                 `json:"default_headers,omitempty"`
+	Features        []string                 `json:"features"`
+	Dependencies    []string                 `json:"dependencies"`
+	ReadinessOnly   []string                 `json:"readiness_dependencies,omitempty"`
+}
+
+// Config returns the effective Server configuration. Default headers are listed by name only, and TLS is reported as
+// enabled without the certificate and key paths.
+func (s *Server) Config() ConfigInfo {
+	features := make([]string, 0)
+
+	for feature, enabled := range map[string]bool{
+		"compression":             s.compression,
+		"server_timing":           s.serverTiming,
+		"common_log":              s.commonLog != nil,
+		"panic_circuit_breaker":   s.panicBreaker != nil,
+		"rate_limit":              s.rateLimit.enabled() || len(s.routeRateLimits) > 0,
+		"read_correlation_header": s.readCorrelationHeader,
+		"request_decompression":   s.requestDecompression,
+		"correlation_id":          s.newCorrelationID != nil,
+		"routes_endpoint":         s.routesEndpoint,
+		"traceparent":             s.traceparent,
+		"config_endpoint":         s.configAuth != nil,
+		"favicon":                 s.favicon != nil,
+		"idempotency":             s.idempotency != nil,
+		"auto_head":               s.autoHead,
+		"response_transformer":    s.responseTransformer != nil,
+		"authentication":          s.authenticator != nil,
+		"gcp_log_fields":          s.gcpLogFields,
+		"start_request_log":       s.startRequestLog,
+		"heartbeat":               s.heartbeat != nil,
+		"fail_fast_health":        s.failFastHealth,
+		"health_timings":          s.healthTimings,
+		"write_deadline_context":  s.writeDeadlineContext,
+		"reuse_port":              s.reusePort,
+	} {
+		if enabled {
+			features = append(features, feature)
+		}
+	}
+
+	slices.Sort(features)
+
+	return ConfigInfo{
+		Addr:            s.Addr(),
+		AdminAddr:       s.adminAddr,
+		TLS:             s.tlsEnabled(),
+		Version:         s.version,
+		ReadTimeout:     s.http.ReadTimeout,
+		WriteTimeout:    s.http.WriteTimeout,
+		ShutdownTimeout: s.shutdownTimeout,
+		RequestTimeout:  s.requestTimeout,
+		RequestTimeouts: maps.Clone(s.requestTimeouts),
+		Warmup:          s.warmup,
+		MaxHeaderCount:  s.maxHeaderCount,
+		MinReadRate:     s.minReadRate,
+		MaxRequestBody:  s.maxRequestBodySize,
+		MaxResponseSize: s.maxResponseSize,
+		MaxConcurrent:   s.maxConcurrentRequests,
+		DefaultHeaders:  slices.Sorted(maps.Keys(s.defaultHeaders)),
+		Features:        features,
+		Dependencies:    slices.Sorted(maps.Keys(s.healthDependencies)),
+		ReadinessOnly:   slices.Sorted(maps.Keys(s.readyDependencies)),
+	}
+}
+
+func (s *Server) configHandler() http.Handler {
+	return http.HandlerFunc(func(writer http.ResponseWriter, _ *http.Request) {
+		writer.Header().Add("Content-Type", "application/json")
+
+		_ = s.encodeJSON(writer, s.Config())
+	})
+}
@@ -0,0 +1,132 @@
+package server_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/b-sea/go-server/server"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfigEndpoint(t *testing.T) {
+	type testCase struct {
+		token      string
+		statusCode int
+		config     *server.ConfigInfo
+	}
+
+	tests := map[string]testCase{
+		"authenticated": {
+			token:      "secret",
+			statusCode: http.StatusOK,
+			config: &server.ConfigInfo{
+				Addr:            ":8080",
+				AdminAddr:       ":9090",
+				TLS:             true,
+				Version:         "v1.2.3",
+				ReadTimeout:     time.Second,
+				WriteTimeout:    2 * time.Second,
+				ShutdownTimeout: 30 * time.Second,
+				RequestTimeout:  3 * time.Second,
+				RequestTimeouts: map[string]time.Duration{"/export": time.Minute},
+				Warmup:          0,
+				MaxHeaderCount:  50,
+				MaxResponseSize: 1 << 20,
+				DefaultHeaders:  []string{"X-Content-Type-Options"},
+				Features: []string{
+					"auto_head",
+					"compression",
+					"config_endpoint",
+					"correlation_id",
+					"idempotency",
+					"response_transformer",
+				},
+				Dependencies: []string{"cache", "database"},
+			},
+		},
+		"wrong token": {
+			token:      "guess",
+			statusCode: http.StatusUnauthorized,
+			config:     nil,
+		},
+		"no token": {
+			token:      "",
+			statusCode: http.StatusUnauthorized,
+			config:     nil,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			testServer := httptest.NewServer(
+				server.New(
+					context.Background(),
+					&server.NoOpRecorder{},
+					server.WithPort(8080),
+					server.WithAdminPort(9090),
+					server.WithTLS("cert.pem", "key.pem"),
+					server.WithVersion("v1.2.3"),
+					server.WithReadTimeout(time.Second),
+					server.WithWriteTimeout(2*time.Second),
+					server.WithShutdownTimeout(30*time.Second),
+					server.WithRequestTimeout(3*time.Second),
+					server.WithRequestTimeouts(map[string]time.Duration{"/export": time.Minute}),
+					server.WithMaxHeaderCount(50),
+					server.WithMaxResponseSize(1<<20),
+					server.WithIdempotency(server.NewMemoryIdempotencyStore(time.Hour), "Idempotency-Key"),
+					server.WithAutoHead(),
+					server.WithResponseTransformer(func(_ int, _ string, body []byte) []byte { return body }),
+					server.WithDefaultHeaders(map[string]string{"X-Content-Type-Options": "nosniff"}),
+					server.WithCompression(),
+					server.WithHealthDependencies(map[string]server.HealthChecker{
+						"database": &HealthCheck{Err: nil},
+						"cache":    &HealthCheck{Err: nil},
+					}),
+					server.WithConfigEndpoint(func(request *http.Request) bool {
+						return request.Header.Get("Authorization") == "Bearer secret"
+					}),
+				),
+			)
+
+			request, _ := http.NewRequestWithContext(context.Background(), http.MethodGet, testServer.URL+"/admin/config", nil)
+			request.Header.Set("Authorization", "Bearer "+test.token)
+			request.Close = true
+
+			response, err := http.DefaultClient.Do(request)
+			assert.NoError(t, err)
+
+			assert.Equal(t, test.statusCode, response.StatusCode)
+
+			if test.config != nil {
+				body, err := io.ReadAll(response.Body)
+				assert.NoError(t, err)
+				assert.NotContains(t, string(body), "pem")
+
+				var config server.ConfigInfo
+
+				assert.NoError(t, json.Unmarshal(body, &config))
+				assert.Equal(t, *test.config, config)
+			}
+
+			assert.NoError(t, response.Body.Close())
+
+			testServer.Close()
+		})
+	}
+}
+
+func TestConfigEndpointDisabled(t *testing.T) {
+	for _, svr := range []*server.Server{
+		server.New(context.Background(), &server.NoOpRecorder{}),
+		server.New(context.Background(), &server.NoOpRecorder{}, server.WithConfigEndpoint(nil)),
+	} {
+		for _, route := range svr.Routes() {
+			assert.NotEqual(t, "/admin/config", route.Path)
+		}
+	}
+}
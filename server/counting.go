@@ -0,0 +1,81 @@
+package server
+
+import (
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	_ FailureRecorder = (*CountingRecorder)(nil)
+	_ ErrorRecorder   = (*CountingRecorder)(nil)
+)
+
+// CountingRecorder is a lightweight metrics recorder that keeps running totals in atomic counters. It does much
+// less work than PrometheusRecorder while still being observable, which suits benchmarks and small embedded servers.
+// The zero value is ready to use.
+type CountingRecorder struct {
+	requests atomic.Int64
+	bytes    atomic.Int64
+	failures atomic.Int64
+	errors   atomic.Int64
+}
+
+// Requests returns the number of requests recorded.
+func (r *CountingRecorder) Requests() int64 {
+	return r.requests.Load()
+}
+
+// Bytes returns the total size of the responses recorded.
+func (r *CountingRecorder) Bytes() int64 {
+	return r.bytes.Load()
+}
+
+// Failures returns the number of requests marked as failed.
+func (r *CountingRecorder) Failures() int64 {
+	return r.failures.Load()
+}
+
+// Errors returns the number of requests that failed with a server error.
+func (r *CountingRecorder) Errors() int64 {
+	return r.errors.Load()
+}
+
+// Handler serves the current totals as JSON.
+func (r *CountingRecorder) Handler() http.Handler {
+	return http.HandlerFunc(func(writer http.ResponseWriter, _ *http.Request) {
+		writer.Header().Add("Content-Type", "application/json")
+
+		_ = encodeJSON(writer, struct {
+			Requests      int64 `json:"requests"`
+			ResponseBytes int64 `json:"response_bytes"`
+			Failures      int64 `json:"failures"`
+			Errors        int64 `json:"errors"`
+		}{
+			Requests:      r.Requests(),
+			ResponseBytes: r.Bytes(),
+			Failures:      r.Failures(),
+			Errors:        r.Errors(),
+		})
+	})
+}
+
+// ObserveHTTPRequestDuration counts an HTTP request.
+func (r *CountingRecorder) ObserveHTTPRequestDuration(string, string, int, time.Duration) {
+	r.requests.Add(1)
+}
+
+// ObserveHTTPResponseSize adds the size of an HTTP response to the total.
+func (r *CountingRecorder) ObserveHTTPResponseSize(_ string, _ string, _ int, bytes int64) {
+	r.bytes.Add(bytes)
+}
+
+// ObserveHTTPFailure counts an HTTP request marked as failed.
+func (r *CountingRecorder) ObserveHTTPFailure(string, string, int) {
+	r.failures.Add(1)
+}
+
+// ObserveHTTPError counts an HTTP request that failed with a server error.
+func (r *CountingRecorder) ObserveHTTPError(string, string, int) {
+	r.errors.Add(1)
+}
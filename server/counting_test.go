@@ -0,0 +1,62 @@
+package server_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/b-sea/go-server/server"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCountingRecorder(t *testing.T) {
+	recorder := &server.CountingRecorder{}
+
+	svr := server.New(context.Background(), recorder)
+	svr.Router().Handle("/fail", http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		server.RecordFailure(request)
+		writer.WriteHeader(http.StatusBadGateway)
+	})).Methods(http.MethodGet)
+
+	testServer := httptest.NewServer(svr)
+	defer testServer.Close()
+
+	for _, path := range []string{"/ping", "/ping", "/fail"} {
+		_, _, err := fetch(t, testServer.URL+path)
+		assert.NoError(t, err)
+	}
+
+	assert.Equal(t, int64(3), recorder.Requests())
+	assert.Equal(t, int64(len("pong")*2), recorder.Bytes())
+	assert.Equal(t, int64(1), recorder.Failures())
+	assert.Equal(t, int64(1), recorder.Errors())
+
+	code, body, err := fetch(t, testServer.URL+"/metrics")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "{\"requests\":3,\"response_bytes\":8,\"failures\":1,\"errors\":1}\n", body)
+}
+
+func BenchmarkRecorderOverhead(b *testing.B) {
+	recorders := map[string]func() server.Recorder{
+		"noop":     func() server.Recorder { return &server.NoOpRecorder{} },
+		"counting": func() server.Recorder { return &server.CountingRecorder{} },
+		"prometheus": func() server.Recorder {
+			return server.NewPrometheus("bench", server.WithRegisterer(prometheus.NewRegistry()))
+		},
+	}
+
+	for name, recorder := range recorders {
+		b.Run(name, func(b *testing.B) {
+			svr := server.New(context.Background(), recorder())
+
+			b.ReportAllocs()
+
+			for b.Loop() {
+				svr.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/ping", nil))
+			}
+		})
+	}
+}
@@ -0,0 +1,51 @@
+package server
+
+import (
+	"net/http"
+	"strings"
+)
+
+const (
+	defaultDrainingBody        = "server draining\n"
+	defaultDrainingContentType = "text/plain; charset=utf-8"
+)
+
+// Drain marks the Server as draining without stopping it, such as when a shutdown signal arrives before the load
+// balancer has stopped routing traffic. While draining, /ready reports the draining state and every other request
+// except the operational endpoints is rejected with a 503 Service Unavailable and the draining response. Stop also
+// drains the Server, and Start clears the draining state.
+func (s *Server) Drain() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.draining = true
+}
+
+// isOperationalPath reports whether a path is one of the operational endpoints, which keep working while draining and
+// are not held back by the rate and concurrency limits. Paths match exactly, apart from the per dependency health
+// endpoints under /health/.
+func isOperationalPath(path string) bool {
+	for _, operational := range adminPaths {
+		if path == operational || (strings.HasSuffix(operational, "/") && strings.HasPrefix(path, operational)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (s *Server) drainingMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		if !s.isDraining() || isOperationalPath(request.URL.Path) {
+			next.ServeHTTP(writer, request)
+
+			return
+		}
+
+		writer.Header().Set("Content-Type", s.drainContentType)
+		writer.Header().Set("X-Content-Type-Options", "nosniff")
+		writer.Header().Set("Connection", "close")
+		writer.WriteHeader(http.StatusServiceUnavailable)
+		_, _ = writer.Write([]byte(s.drainBody))
+	})
+}
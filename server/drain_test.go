@@ -0,0 +1,114 @@
+package server_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/b-sea/go-server/server"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDrainingResponse(t *testing.T) {
+	type testCase struct {
+		options             []server.Option
+		path                string
+		expectedCode        int
+		expectedBody        string
+		expectedContentType string
+	}
+
+	tests := map[string]testCase{
+		"default": {
+			options:             nil,
+			path:                "/orders",
+			expectedCode:        http.StatusServiceUnavailable,
+			expectedBody:        "server draining\n",
+			expectedContentType: "text/plain; charset=utf-8",
+		},
+		"json": {
+			options: []server.Option{
+				server.WithDrainingResponse(`{"error":"server draining"}`, "application/json"),
+			},
+			path:                "/orders",
+			expectedCode:        http.StatusServiceUnavailable,
+			expectedBody:        `{"error":"server draining"}`,
+			expectedContentType: "application/json",
+		},
+		"no content type": {
+			options:             []server.Option{server.WithDrainingResponse("going away", "")},
+			path:                "/orders",
+			expectedCode:        http.StatusServiceUnavailable,
+			expectedBody:        "going away",
+			expectedContentType: "text/plain; charset=utf-8",
+		},
+		"operational endpoint": {
+			options:             nil,
+			path:                "/ping",
+			expectedCode:        http.StatusOK,
+			expectedBody:        "pong",
+			expectedContentType: "text/plain; charset=utf-8",
+		},
+		"application route under admin": {
+			options:             nil,
+			path:                "/admin/orders",
+			expectedCode:        http.StatusServiceUnavailable,
+			expectedBody:        "server draining\n",
+			expectedContentType: "text/plain; charset=utf-8",
+		},
+		"routes endpoint": {
+			options:             []server.Option{server.WithRoutesEndpoint()},
+			path:                "/admin/routes",
+			expectedCode:        http.StatusOK,
+			expectedBody:        "",
+			expectedContentType: "application/json",
+		},
+		"readiness": {
+			options:             nil,
+			path:                "/ready?verbose",
+			expectedCode:        http.StatusServiceUnavailable,
+			expectedBody:        "{\"status\":\"draining\",\"uptime\":0}\n",
+			expectedContentType: "application/json",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			svr := server.New(context.Background(), &server.NoOpRecorder{}, test.options...)
+			orders := http.HandlerFunc(func(writer http.ResponseWriter, _ *http.Request) {
+				_, _ = writer.Write([]byte(`orders`))
+			})
+			svr.Router().Handle("/orders", orders).Methods(http.MethodGet)
+			svr.Router().Handle("/admin/orders", orders).Methods(http.MethodGet)
+
+			testServer := httptest.NewServer(svr)
+			defer testServer.Close()
+
+			code, body, err := fetch(t, testServer.URL+"/orders")
+			assert.NoError(t, err)
+			assert.Equal(t, http.StatusOK, code)
+			assert.Equal(t, "orders", body)
+
+			svr.Drain()
+
+			request, _ := http.NewRequestWithContext(context.Background(), http.MethodGet, testServer.URL+test.path, nil)
+			request.Close = true
+
+			response, err := http.DefaultClient.Do(request)
+			assert.NoError(t, err)
+
+			responseBody, err := io.ReadAll(response.Body)
+			assert.NoError(t, err)
+			assert.NoError(t, response.Body.Close())
+
+			assert.Equal(t, test.expectedCode, response.StatusCode)
+			if test.expectedBody != "" {
+				assert.Equal(t, test.expectedBody, string(responseBody))
+			}
+
+			assert.Equal(t, test.expectedContentType, response.Header.Get("Content-Type"))
+		})
+	}
+}
@@ -0,0 +1,24 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+)
+
+type failureKey struct{}
+
+// RecordFailure marks a request as failed for metrics and logging, whatever its status code. Handlers use it for
+// responses that succeed at the HTTP level but carry an application error. It does nothing outside the server.
+func RecordFailure(request *http.Request) {
+	if failed, ok := request.Context().Value(failureKey{}).(*atomic.Bool); ok {
+		failed.Store(true)
+	}
+}
+
+// requestFailed reports whether the request the context belongs to was marked as failed.
+func requestFailed(ctx context.Context) bool {
+	failed, ok := ctx.Value(failureKey{}).(*atomic.Bool)
+
+	return ok && failed.Load()
+}
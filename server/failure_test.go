@@ -0,0 +1,69 @@
+package server_test
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/b-sea/go-server/server"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecordFailure(t *testing.T) {
+	type testCase struct {
+		fail     bool
+		expected []Observation
+	}
+
+	tests := map[string]testCase{
+		"failed": {
+			fail:     true,
+			expected: []Observation{{Method: http.MethodGet, Path: "/test", Code: http.StatusOK, Value: 1}},
+		},
+		"succeeded": {
+			fail:     false,
+			expected: []Observation{},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			var buffer bytes.Buffer
+
+			recorder := &SpyRecorder{}
+
+			svr := server.New(context.Background(), recorder)
+			svr.Router().Handle("/test", http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+				if test.fail {
+					server.RecordFailure(request)
+				}
+
+				_, _ = writer.Write([]byte(`{"error":"something bad"}`))
+			})).Methods(http.MethodGet)
+
+			testServer := httptest.NewServer(withLogger(svr, zerolog.New(&buffer)))
+
+			request, _ := http.NewRequestWithContext(context.Background(), http.MethodGet, testServer.URL+"/test", nil)
+			request.Close = true
+
+			response, err := http.DefaultClient.Do(request)
+			assert.NoError(t, err)
+			assert.NoError(t, response.Body.Close())
+
+			testServer.Close()
+
+			assert.Equal(t, http.StatusOK, response.StatusCode)
+			assert.Equal(t, test.expected, recorder.FailureObservations())
+			assert.Equal(t, test.fail, bytes.Contains(buffer.Bytes(), []byte(`"failed":true`)))
+		})
+	}
+}
+
+func TestRecordFailureOutsideServer(t *testing.T) {
+	request := httptest.NewRequest(http.MethodGet, "/test", nil)
+
+	assert.NotPanics(t, func() { server.RecordFailure(request) })
+}
@@ -0,0 +1,32 @@
+package server
+
+import (
+	"net/http"
+	"sync/atomic"
+
+	"github.com/gorilla/mux"
+)
+
+// AddFeatureFlagHandler registers a route that only matches while the flag is enabled, so endpoints can be rolled out
+// or pulled without a redeploy. While the flag is disabled, requests to the route get the same 404 Not Found as any
+// unknown path. A nil flag leaves the route disabled. The route is returned so it can be configured further.
+func (s *Server) AddFeatureFlagHandler(
+	path string,
+	handler http.Handler,
+	enabled *atomic.Bool,
+	methods ...string,
+) *mux.Route {
+	if enabled == nil {
+		enabled = &atomic.Bool{}
+	}
+
+	route := s.router.Handle(path, handler).MatcherFunc(func(*http.Request, *mux.RouteMatch) bool {
+		return enabled.Load()
+	})
+
+	if len(methods) > 0 {
+		route.Methods(methods...)
+	}
+
+	return route
+}
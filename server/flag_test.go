@@ -0,0 +1,55 @@
+package server_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/b-sea/go-server/server"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFeatureFlagHandler(t *testing.T) {
+	enabled := &atomic.Bool{}
+
+	svr := server.New(context.Background(), &server.NoOpRecorder{})
+	svr.AddFeatureFlagHandler("/beta", http.HandlerFunc(func(writer http.ResponseWriter, _ *http.Request) {
+		_, _ = writer.Write([]byte(`beta`))
+	}), enabled, http.MethodGet)
+
+	testServer := httptest.NewServer(svr)
+	defer testServer.Close()
+
+	code, _, err := fetch(t, testServer.URL+"/beta")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, code)
+
+	enabled.Store(true)
+
+	code, body, err := fetch(t, testServer.URL+"/beta")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "beta", body)
+
+	enabled.Store(false)
+
+	code, _, err = fetch(t, testServer.URL+"/beta")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, code)
+}
+
+func TestFeatureFlagHandlerNilFlag(t *testing.T) {
+	svr := server.New(context.Background(), &server.NoOpRecorder{})
+	svr.AddFeatureFlagHandler("/beta", http.HandlerFunc(func(writer http.ResponseWriter, _ *http.Request) {
+		_, _ = writer.Write([]byte(`beta`))
+	}), nil, http.MethodGet)
+
+	response := httptest.NewRecorder()
+
+	assert.NotPanics(t, func() {
+		svr.ServeHTTP(response, httptest.NewRequest(http.MethodGet, "/beta", nil))
+	})
+	assert.Equal(t, http.StatusNotFound, response.Code)
+}
@@ -0,0 +1,96 @@
+package server
+
+import (
+	"net/http"
+	"sync"
+	"time"
+)
+
+// autoFlushWriter tracks whether anything was written since the last flush, so idle responses are not flushed.
+type autoFlushWriter struct {
+	http.ResponseWriter
+
+	mu      sync.Mutex
+	pending bool
+}
+
+func (w *autoFlushWriter) WriteHeader(statusCode int) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (w *autoFlushWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.pending = true
+
+	return w.ResponseWriter.Write(p) //nolint: wrapcheck
+}
+
+func (w *autoFlushWriter) Flush() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.flush()
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (w *autoFlushWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// flushPending flushes the response if anything was written since the last flush.
+func (w *autoFlushWriter) flushPending() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.pending {
+		w.flush()
+	}
+}
+
+func (w *autoFlushWriter) flush() {
+	w.pending = false
+
+	_ = http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+// autoFlushMiddleware flushes responses on an interval while the handler runs, so streamed data reaches the client
+// even when the handler never flushes.
+func (s *Server) autoFlushMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		flusher := &autoFlushWriter{
+			ResponseWriter: writer,
+			mu:             sync.Mutex{},
+			pending:        false,
+		}
+
+		ticker := time.NewTicker(s.autoFlushInterval)
+		done := make(chan struct{})
+		stopped := make(chan struct{})
+
+		go func() {
+			defer close(stopped)
+
+			for {
+				select {
+				case <-ticker.C:
+					flusher.flushPending()
+				case <-done:
+					return
+				}
+			}
+		}()
+
+		defer func() {
+			ticker.Stop()
+			close(done)
+			<-stopped
+		}()
+
+		next.ServeHTTP(flusher, request)
+	})
+}
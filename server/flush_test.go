@@ -0,0 +1,139 @@
+package server_test
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/b-sea/go-server/server"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAutoFlushInterval(t *testing.T) {
+	type testCase struct {
+		option  server.Option
+		arrives bool
+	}
+
+	tests := map[string]testCase{
+		"enabled": {
+			option:  server.WithAutoFlushInterval(10 * time.Millisecond),
+			arrives: true,
+		},
+		"disabled": {
+			option:  server.WithAutoFlushInterval(0),
+			arrives: false,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			release := make(chan struct{})
+
+			svr := server.New(context.Background(), &server.NoOpRecorder{}, test.option)
+			svr.Router().Handle("/stream", http.HandlerFunc(func(writer http.ResponseWriter, _ *http.Request) {
+				_, _ = writer.Write([]byte("first\n"))
+				<-release
+				_, _ = writer.Write([]byte("second\n"))
+			})).Methods(http.MethodGet)
+
+			testServer := httptest.NewServer(svr)
+
+			request, _ := http.NewRequestWithContext(context.Background(), http.MethodGet, testServer.URL+"/stream", nil)
+			request.Close = true
+
+			lines := make(chan string, 2)
+
+			go func() {
+				response, err := http.DefaultClient.Do(request)
+				if !assert.NoError(t, err) {
+					close(lines)
+
+					return
+				}
+
+				scanner := bufio.NewScanner(response.Body)
+				for scanner.Scan() {
+					lines <- scanner.Text()
+				}
+
+				assert.NoError(t, response.Body.Close())
+				close(lines)
+			}()
+
+			select {
+			case line := <-lines:
+				assert.True(t, test.arrives, "first line arrived before the handler finished")
+				assert.Equal(t, "first", line)
+			case <-time.After(200 * time.Millisecond):
+				assert.False(t, test.arrives, "first line did not arrive before the handler finished")
+			}
+
+			close(release)
+
+			remaining := []string{}
+			for line := range lines {
+				remaining = append(remaining, line)
+			}
+
+			if test.arrives {
+				assert.Equal(t, []string{"second"}, remaining)
+			} else {
+				assert.Equal(t, []string{"first", "second"}, remaining)
+			}
+
+			testServer.Close()
+		})
+	}
+}
+
+func TestAutoFlushResponseController(t *testing.T) {
+	release := make(chan struct{})
+	controlled := make(chan error, 2)
+
+	// The interval is long enough that only the handler's own flush can send the first line early
+	svr := server.New(context.Background(), &server.NoOpRecorder{}, server.WithAutoFlushInterval(time.Hour))
+	svr.Router().Handle("/stream", http.HandlerFunc(func(writer http.ResponseWriter, _ *http.Request) {
+		controller := http.NewResponseController(writer)
+
+		writer.WriteHeader(http.StatusAccepted)
+		_, _ = writer.Write([]byte("first\n"))
+
+		controlled <- controller.Flush()
+		controlled <- controller.SetWriteDeadline(time.Now().Add(time.Minute))
+
+		<-release
+		_, _ = writer.Write([]byte("second\n"))
+	})).Methods(http.MethodGet)
+
+	testServer := httptest.NewServer(svr)
+	defer testServer.Close()
+
+	request, _ := http.NewRequestWithContext(context.Background(), http.MethodGet, testServer.URL+"/stream", nil)
+	request.Close = true
+
+	response, err := http.DefaultClient.Do(request)
+	if !assert.NoError(t, err) {
+		close(release)
+
+		return
+	}
+
+	assert.Equal(t, http.StatusAccepted, response.StatusCode)
+
+	scanner := bufio.NewScanner(response.Body)
+	assert.True(t, scanner.Scan())
+	assert.Equal(t, "first", scanner.Text())
+
+	assert.NoError(t, <-controlled)
+	assert.NoError(t, <-controlled)
+
+	close(release)
+
+	assert.True(t, scanner.Scan())
+	assert.Equal(t, "second", scanner.Text())
+	assert.NoError(t, response.Body.Close())
+}
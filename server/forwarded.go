@@ -0,0 +1,81 @@
+package server
+
+import (
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+const forwardedProtoHeader = "X-Forwarded-Proto"
+
+type schemeKey struct{}
+
+// RequestScheme returns the scheme the client used for the request, either http or https. Behind a proxy set with
+// WithTrustedProxies, it honours the X-Forwarded-Proto header, so links and logs stay correct when TLS is terminated
+// before the server.
+func RequestScheme(request *http.Request) string {
+	if scheme, ok := request.Context().Value(schemeKey{}).(string); ok {
+		return scheme
+	}
+
+	return directScheme(request)
+}
+
+// directScheme returns the scheme of the connection to the server itself.
+func directScheme(request *http.Request) string {
+	if request.TLS != nil {
+		return "https"
+	}
+
+	if request.URL.Scheme != "" {
+		return strings.ToLower(request.URL.Scheme)
+	}
+
+	return "http"
+}
+
+// requestScheme returns the effective scheme, trusting X-Forwarded-Proto only from trusted proxies.
+func (s *Server) requestScheme(request *http.Request) string {
+	scheme := directScheme(request)
+
+	if !s.trustedProxy(request.RemoteAddr) {
+		return scheme
+	}
+
+	// Each proxy appends to the list, so the first value is the one the client used
+	forwarded, _, _ := strings.Cut(request.Header.Get(forwardedProtoHeader), ",")
+
+	switch forwarded = strings.ToLower(strings.TrimSpace(forwarded)); forwarded {
+	case "http", "https":
+		return forwarded
+	default:
+		return scheme
+	}
+}
+
+func (s *Server) trustedProxy(remoteAddr string) bool {
+	if len(s.trustedProxies) == 0 {
+		return false
+	}
+
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		host = remoteAddr
+	}
+
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return false
+	}
+
+	addr = addr.Unmap()
+
+	for _, prefix := range s.trustedProxies {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+
+	return false
+}
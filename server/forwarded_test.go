@@ -0,0 +1,133 @@
+package server_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/b-sea/go-server/server"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequestScheme(t *testing.T) {
+	type testCase struct {
+		proxies []string
+		header  string
+		tls     bool
+		scheme  string
+	}
+
+	tests := map[string]testCase{
+		"plain request": {
+			proxies: nil,
+			header:  "",
+			tls:     false,
+			scheme:  "http",
+		},
+		"tls request": {
+			proxies: nil,
+			header:  "",
+			tls:     true,
+			scheme:  "https",
+		},
+		"untrusted forwarded header": {
+			proxies: nil,
+			header:  "https",
+			tls:     false,
+			scheme:  "http",
+		},
+		"forwarded header from untrusted range": {
+			proxies: []string{"10.0.0.0/8"},
+			header:  "https",
+			tls:     false,
+			scheme:  "http",
+		},
+		"forwarded header from trusted range": {
+			proxies: []string{"10.0.0.0/8", "127.0.0.0/8"},
+			header:  "https",
+			tls:     false,
+			scheme:  "https",
+		},
+		"forwarded header from trusted address": {
+			proxies: []string{"127.0.0.1"},
+			header:  "HTTPS",
+			tls:     false,
+			scheme:  "https",
+		},
+		"forwarded header list": {
+			proxies: []string{"127.0.0.1"},
+			header:  "https, http",
+			tls:     false,
+			scheme:  "https",
+		},
+		"forwarded http over tls": {
+			proxies: []string{"127.0.0.1"},
+			header:  "http",
+			tls:     true,
+			scheme:  "http",
+		},
+		"invalid forwarded header": {
+			proxies: []string{"127.0.0.1"},
+			header:  "gopher",
+			tls:     false,
+			scheme:  "http",
+		},
+		"invalid trusted proxy": {
+			proxies: []string{"localhost"},
+			header:  "https",
+			tls:     false,
+			scheme:  "http",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			var (
+				logs   safeBuffer
+				scheme string
+			)
+
+			svr := server.New(context.Background(), &server.NoOpRecorder{}, server.WithTrustedProxies(test.proxies...))
+			svr.Router().Handle("/link", http.HandlerFunc(func(_ http.ResponseWriter, request *http.Request) {
+				scheme = server.RequestScheme(request)
+			})).Methods(http.MethodGet)
+
+			var testServer *httptest.Server
+			if test.tls {
+				testServer = httptest.NewTLSServer(withLogger(svr, zerolog.New(&logs)))
+			} else {
+				testServer = httptest.NewServer(withLogger(svr, zerolog.New(&logs)))
+			}
+
+			request, _ := http.NewRequestWithContext(context.Background(), http.MethodGet, testServer.URL+"/link", nil)
+			request.Close = true
+
+			if test.header != "" {
+				request.Header.Set("X-Forwarded-Proto", test.header)
+			}
+
+			response, err := testServer.Client().Do(request)
+			assert.NoError(t, err)
+			assert.NoError(t, response.Body.Close())
+
+			testServer.Close()
+
+			assert.Equal(t, test.scheme, scheme)
+
+			var entry struct {
+				Scheme string `json:"scheme"`
+			}
+
+			assert.NoError(t, json.Unmarshal([]byte(logs.String()), &entry))
+			assert.Equal(t, test.scheme, entry.Scheme)
+		})
+	}
+}
+
+func TestRequestSchemeWithoutServer(t *testing.T) {
+	assert.Equal(t, "http", server.RequestScheme(httptest.NewRequest(http.MethodGet, "/link", nil)))
+	assert.Equal(t, "https", server.RequestScheme(httptest.NewRequest(http.MethodGet, "https://example.com/link", nil)))
+}
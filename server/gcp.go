@@ -0,0 +1,82 @@
+package server
+
+import (
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+const (
+	gcpSeverityField = "severity"
+	gcpTraceField    = "logging.googleapis.com/trace"
+	gcpSpanField     = "logging.googleapis.com/spanId"
+)
+
+// gcpSeverityHook adds the severity field Google Cloud Logging reads the log level from.
+type gcpSeverityHook struct{}
+
+func (gcpSeverityHook) Run(event *zerolog.Event, level zerolog.Level, _ string) {
+	event.Str(gcpSeverityField, gcpSeverity(level))
+}
+
+// gcpSeverity maps a zerolog level to its Google Cloud Logging severity.
+func gcpSeverity(level zerolog.Level) string {
+	switch level {
+	case zerolog.TraceLevel, zerolog.DebugLevel:
+		return "DEBUG"
+	case zerolog.InfoLevel:
+		return "INFO"
+	case zerolog.WarnLevel:
+		return "WARNING"
+	case zerolog.ErrorLevel:
+		return "ERROR"
+	case zerolog.FatalLevel:
+		return "CRITICAL"
+	case zerolog.PanicLevel:
+		return "ALERT"
+	case zerolog.NoLevel, zerolog.Disabled:
+		return "DEFAULT"
+	}
+
+	return "DEFAULT"
+}
+
+// gcpLogger returns a copy of a request logger that writes the fields Google Cloud Logging expects, tying the
+// request's logs together by its trace. Cloud Logging only links traces named with their project, so the trace is
+// left out without one.
+func gcpLogger(log *zerolog.Logger, project string, trace string, span string) *zerolog.Logger {
+	fields := log.With()
+
+	if project != "" && trace != "" {
+		fields = fields.Str(gcpTraceField, "projects/"+project+"/traces/"+trace)
+	}
+
+	if span != "" {
+		fields = fields.Str(gcpSpanField, span)
+	}
+
+	logger := fields.Logger().Hook(gcpSeverityHook{})
+
+	return &logger
+}
+
+// gcpHTTPRequest returns a completed request in the Google Cloud Logging HttpRequest format.
+func gcpHTTPRequest(request *http.Request, status int, size int, duration time.Duration) *zerolog.Event {
+	remoteIP, _, err := net.SplitHostPort(request.RemoteAddr)
+	if err != nil {
+		remoteIP = request.RemoteAddr
+	}
+
+	return zerolog.Dict().
+		Str("requestMethod", request.Method).
+		Str("requestUrl", request.URL.RequestURI()).
+		Int("status", status).
+		Str("responseSize", strconv.Itoa(size)).
+		Str("userAgent", request.UserAgent()).
+		Str("remoteIp", remoteIP).
+		Str("protocol", request.Proto).
+		Str("latency", strconv.FormatFloat(duration.Seconds(), 'f', -1, 64)+"s")
+}
@@ -0,0 +1,125 @@
+package server_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/b-sea/go-server/server"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGCPSeverity(t *testing.T) {
+	type testCase struct {
+		level    zerolog.Level
+		severity string
+	}
+
+	tests := map[string]testCase{
+		"trace": {
+			level:    zerolog.TraceLevel,
+			severity: "DEBUG",
+		},
+		"debug": {
+			level:    zerolog.DebugLevel,
+			severity: "DEBUG",
+		},
+		"info": {
+			level:    zerolog.InfoLevel,
+			severity: "INFO",
+		},
+		"warn": {
+			level:    zerolog.WarnLevel,
+			severity: "WARNING",
+		},
+		"error": {
+			level:    zerolog.ErrorLevel,
+			severity: "ERROR",
+		},
+		"fatal": {
+			level:    zerolog.FatalLevel,
+			severity: "CRITICAL",
+		},
+		"panic": {
+			level:    zerolog.PanicLevel,
+			severity: "ALERT",
+		},
+		"no level": {
+			level:    zerolog.NoLevel,
+			severity: "DEFAULT",
+		},
+		"unknown level": {
+			level:    zerolog.Level(42),
+			severity: "DEFAULT",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			var buffer safeBuffer
+
+			svr := server.New(context.Background(), &server.NoOpRecorder{}, server.WithGCPLogFields("my-project"))
+			svr.Router().Handle("/test", http.HandlerFunc(func(_ http.ResponseWriter, request *http.Request) {
+				// WithLevel logs fatal and panic messages without exiting or panicking
+				zerolog.Ctx(request.Context()).WithLevel(test.level).Msg("from handler")
+			})).Methods(http.MethodGet)
+
+			request := httptest.NewRequestWithContext(context.Background(), http.MethodGet, "/test", nil)
+			withLogger(svr, zerolog.New(&buffer).Level(zerolog.TraceLevel)).ServeHTTP(httptest.NewRecorder(), request)
+
+			var entry struct {
+				Message  string `json:"message"`
+				Severity string `json:"severity"`
+			}
+
+			lines := strings.Split(strings.TrimSpace(buffer.String()), "\n")
+			assert.NoError(t, json.Unmarshal([]byte(lines[0]), &entry))
+			assert.Equal(t, "from handler", entry.Message)
+			assert.Equal(t, test.severity, entry.Severity)
+		})
+	}
+}
+
+func TestGCPRemoteIP(t *testing.T) {
+	type testCase struct {
+		remoteAddr string
+		remoteIP   string
+	}
+
+	tests := map[string]testCase{
+		"host and port": {
+			remoteAddr: "192.0.2.1:51234",
+			remoteIP:   "192.0.2.1",
+		},
+		"host only": {
+			remoteAddr: "192.0.2.1",
+			remoteIP:   "192.0.2.1",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			var buffer safeBuffer
+
+			svr := server.New(context.Background(), &server.NoOpRecorder{}, server.WithGCPLogFields("my-project"))
+
+			request := httptest.NewRequestWithContext(context.Background(), http.MethodGet, "/ping", nil)
+			request.RemoteAddr = test.remoteAddr
+
+			withLogger(svr, zerolog.New(&buffer)).ServeHTTP(httptest.NewRecorder(), request)
+
+			var entry struct {
+				HTTPRequest struct {
+					RemoteIP string `json:"remoteIp"`
+				} `json:"httpRequest"`
+			}
+
+			assert.NoError(t, json.Unmarshal([]byte(strings.TrimSpace(buffer.String())), &entry))
+			assert.Equal(t, test.remoteIP, entry.HTTPRequest.RemoteIP)
+		})
+	}
+}
@@ -0,0 +1,71 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/rs/zerolog"
+)
+
+var _ http.Handler = ErrorHandlerFunc(nil)
+
+// HTTPError is an error that carries the HTTP status code and message to respond with.
+type HTTPError struct {
+	Code    int
+	Message string
+	Err     error
+}
+
+// NewHTTPError creates a new HTTPError.
+func NewHTTPError(code int, msg string) *HTTPError {
+	return &HTTPError{
+		Code:    code,
+		Message: msg,
+		Err:     nil,
+	}
+}
+
+func (e *HTTPError) Error() string {
+	if e.Err == nil {
+		return e.Message
+	}
+
+	return fmt.Sprintf("%s: %v", e.Message, e.Err)
+}
+
+func (e *HTTPError) Unwrap() error {
+	return e.Err
+}
+
+// ErrorHandlerFunc is an HTTP handler that can return an error.
+//
+// An HTTPError anywhere in the returned error chain sets the response code and message. Any other
+// error responds with a 500 Internal Server Error.
+type ErrorHandlerFunc func(writer http.ResponseWriter, request *http.Request) error
+
+func (fn ErrorHandlerFunc) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
+	err := fn(writer, request)
+	if err == nil {
+		return
+	}
+
+	code := http.StatusInternalServerError
+	message := ""
+
+	var httpErr *HTTPError
+	if errors.As(err, &httpErr) {
+		code = httpErr.Code
+		message = httpErr.Message
+	}
+
+	if message == "" {
+		message = http.StatusText(code)
+	}
+
+	if code >= http.StatusInternalServerError {
+		zerolog.Ctx(request.Context()).Error().Err(err).Msg("handler error")
+	}
+
+	http.Error(writer, message, code)
+}
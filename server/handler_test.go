@@ -0,0 +1,109 @@
+package server_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/b-sea/go-server/server"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestErrorHandler(t *testing.T) {
+	type testCase struct {
+		err        error
+		result     string
+		statusCode int
+	}
+
+	tests := map[string]testCase{
+		"no error": {
+			err:        nil,
+			result:     "ok",
+			statusCode: http.StatusOK,
+		},
+		"plain error": {
+			err:        errors.New("something bad"),
+			result:     "Internal Server Error\n",
+			statusCode: http.StatusInternalServerError,
+		},
+		"bad request": {
+			err:        server.NewHTTPError(http.StatusBadRequest, "missing name"),
+			result:     "missing name\n",
+			statusCode: http.StatusBadRequest,
+		},
+		"not found": {
+			err:        server.NewHTTPError(http.StatusNotFound, "no such thing"),
+			result:     "no such thing\n",
+			statusCode: http.StatusNotFound,
+		},
+		"conflict no message": {
+			err:        server.NewHTTPError(http.StatusConflict, ""),
+			result:     "Conflict\n",
+			statusCode: http.StatusConflict,
+		},
+		"wrapped": {
+			err:        fmt.Errorf("lookup: %w", server.NewHTTPError(http.StatusNotFound, "no such thing")),
+			result:     "no such thing\n",
+			statusCode: http.StatusNotFound,
+		},
+		"with inner error": {
+			err: &server.HTTPError{
+				Code:    http.StatusServiceUnavailable,
+				Message: "try again later",
+				Err:     errors.New("database down"),
+			},
+			result:     "try again later\n",
+			statusCode: http.StatusServiceUnavailable,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			svr := server.New(context.Background(), &server.NoOpRecorder{})
+			svr.Router().Handle(
+				"/test",
+				server.ErrorHandlerFunc(func(writer http.ResponseWriter, _ *http.Request) error {
+					if test.err != nil {
+						return test.err
+					}
+
+					_, _ = writer.Write([]byte(`ok`))
+
+					return nil
+				}),
+			).Methods(http.MethodGet)
+
+			testServer := httptest.NewServer(svr)
+
+			request, _ := http.NewRequestWithContext(context.Background(), http.MethodGet, testServer.URL+"/test", nil)
+			request.Close = true
+
+			response, err := http.DefaultClient.Do(request)
+			assert.NoError(t, err)
+
+			body, err := io.ReadAll(response.Body)
+			assert.NoError(t, err)
+
+			assert.NoError(t, response.Body.Close())
+
+			assert.Equal(t, test.statusCode, response.StatusCode)
+			assert.Equal(t, test.result, string(body))
+
+			testServer.Close()
+		})
+	}
+}
+
+func TestHTTPError(t *testing.T) {
+	inner := errors.New("database down")
+	err := &server.HTTPError{Code: http.StatusServiceUnavailable, Message: "try again later", Err: inner}
+
+	assert.Equal(t, "try again later: database down", err.Error())
+	assert.ErrorIs(t, err, inner)
+	assert.Equal(t, "missing name", server.NewHTTPError(http.StatusBadRequest, "missing name").Error())
+}
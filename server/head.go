@@ -0,0 +1,84 @@
+package server
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+type autoHeadKey struct{}
+
+// headWriter discards the response body of a HEAD request.
+type headWriter struct {
+	http.ResponseWriter
+}
+
+func (w *headWriter) Write(p []byte) (int, error) {
+	return len(p), nil
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (w *headWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// handler returns the root handler of the Server.
+func (s *Server) handler() http.Handler {
+	if s.autoHead {
+		return s.autoHeadHandler(s.router)
+	}
+
+	return s.router
+}
+
+// autoHeadHandler routes HEAD requests that match no route as GET requests. They go through the router like any other
+// request, so route matchers and router middleware still apply.
+func (s *Server) autoHeadHandler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		if request.Method != http.MethodHead || s.matches(request) {
+			next.ServeHTTP(writer, request)
+
+			return
+		}
+
+		get := request.WithContext(context.WithValue(request.Context(), autoHeadKey{}, true))
+		get.Method = http.MethodGet
+
+		if !s.matches(get) {
+			next.ServeHTTP(writer, request)
+
+			return
+		}
+
+		next.ServeHTTP(writer, get)
+	})
+}
+
+// matches reports whether the request matches a route on the server router. Like Routes, routes without a path
+// template are ignored, which also skips the catch-all not found route.
+func (s *Server) matches(request *http.Request) bool {
+	var match mux.RouteMatch
+
+	if !s.router.Match(request, &match) || match.MatchErr != nil || match.Route == nil {
+		return false
+	}
+
+	_, err := match.Route.GetPathTemplate()
+
+	return err == nil
+}
+
+// restoreHead gives a HEAD request routed as GET by autoHeadHandler its HEAD method back once it has matched a route,
+// so handlers, logs, and metrics see the method the client sent. It reports whether the response body must be
+// discarded.
+func restoreHead(request *http.Request) (*http.Request, bool) {
+	if head, _ := request.Context().Value(autoHeadKey{}).(bool); !head {
+		return request, false
+	}
+
+	head := request.WithContext(request.Context())
+	head.Method = http.MethodHead
+
+	return head, true
+}
@@ -0,0 +1,132 @@
+package server_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/b-sea/go-server/server"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAutoHead(t *testing.T) {
+	type testCase struct {
+		option     server.Option
+		path       string
+		statusCode int
+		header     string
+	}
+
+	tests := map[string]testCase{
+		"disabled": {
+			option:     nil,
+			path:       "/things/1",
+			statusCode: http.StatusNotFound,
+			header:     "",
+		},
+		"get route": {
+			option:     server.WithAutoHead(),
+			path:       "/things/1",
+			statusCode: http.StatusOK,
+			header:     "1",
+		},
+		"built-in route": {
+			option:     server.WithAutoHead(),
+			path:       "/ping",
+			statusCode: http.StatusOK,
+			header:     "",
+		},
+		"explicit head route": {
+			option:     server.WithAutoHead(),
+			path:       "/explicit",
+			statusCode: http.StatusNoContent,
+			header:     "explicit",
+		},
+		"subrouter middleware": {
+			option:     server.WithAutoHead(),
+			path:       "/api/secret",
+			statusCode: http.StatusUnauthorized,
+			header:     "",
+		},
+		"disabled feature flag": {
+			option:     server.WithAutoHead(),
+			path:       "/beta",
+			statusCode: http.StatusNotFound,
+			header:     "",
+		},
+		"post route": {
+			option:     server.WithAutoHead(),
+			path:       "/submit",
+			statusCode: http.StatusNotFound,
+			header:     "",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			recorder := &SpyRecorder{}
+
+			options := []server.Option{}
+			if test.option != nil {
+				options = append(options, test.option)
+			}
+
+			svr := server.New(context.Background(), recorder, options...)
+			svr.Router().HandleFunc("/things/{id}", func(writer http.ResponseWriter, request *http.Request) {
+				writer.Header().Set("X-Thing", mux.Vars(request)["id"])
+				_, _ = writer.Write([]byte(`a thing`))
+			}).Methods(http.MethodGet)
+			svr.Router().HandleFunc("/explicit", func(writer http.ResponseWriter, _ *http.Request) {
+				writer.Header().Set("X-Thing", "explicit")
+				writer.WriteHeader(http.StatusNoContent)
+			}).Methods(http.MethodGet, http.MethodHead)
+			svr.Router().HandleFunc("/submit", func(http.ResponseWriter, *http.Request) {}).Methods(http.MethodPost)
+
+			api := svr.Subrouter("/api")
+			api.Use(func(http.Handler) http.Handler {
+				return http.HandlerFunc(func(writer http.ResponseWriter, _ *http.Request) {
+					writer.WriteHeader(http.StatusUnauthorized)
+				})
+			})
+			api.HandleFunc("/secret", func(writer http.ResponseWriter, _ *http.Request) {
+				writer.Header().Set("X-Thing", "secret")
+			}).Methods(http.MethodGet)
+
+			svr.AddFeatureFlagHandler("/beta", http.HandlerFunc(func(writer http.ResponseWriter, _ *http.Request) {
+				writer.Header().Set("X-Thing", "beta")
+			}), &atomic.Bool{}, http.MethodGet)
+
+			testServer := httptest.NewServer(svr)
+
+			request, _ := http.NewRequestWithContext(context.Background(), http.MethodHead, testServer.URL+test.path, nil)
+			request.Close = true
+
+			response, err := http.DefaultClient.Do(request)
+			assert.NoError(t, err)
+
+			body, err := io.ReadAll(response.Body)
+			assert.NoError(t, err)
+
+			assert.NoError(t, response.Body.Close())
+
+			testServer.Close()
+
+			assert.Equal(t, test.statusCode, response.StatusCode)
+			assert.Equal(t, test.header, response.Header.Get("X-Thing"))
+			assert.Empty(t, body)
+
+			sizes := recorder.SizeObservations()
+			if assert.Len(t, sizes, 1) {
+				assert.Equal(t, http.MethodHead, sizes[0].Method)
+
+				if test.statusCode != http.StatusNotFound {
+					assert.Equal(t, float64(0), sizes[0].Value)
+				}
+			}
+		})
+	}
+}
@@ -1,9 +1,17 @@
 package server
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
 	"net/http"
+	"slices"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/rs/zerolog"
@@ -12,8 +20,25 @@ import (
 const (
 	healthyStatus   = "healthy"
 	unhealthyStatus = "unhealthy"
+	warmingUpStatus = "warming up"
+	drainingStatus  = "draining"
+	skippedStatus   = "skipped"
 
-	verboseParam = "verbose"
+	verboseParam      = "verbose"
+	failuresOnlyParam = "failures-only"
+	formatParam       = "format"
+	k8sFormat         = "k8s"
+
+	dependencyPollInterval = 100 * time.Millisecond
+
+	recorderDependency = "metrics"
+)
+
+var (
+	// ErrDependenciesUnhealthy is returned when health dependencies do not become healthy in time.
+	ErrDependenciesUnhealthy = errors.New("dependencies unhealthy")
+	// ErrHealthCheckTimeout is the error a health check fails with when it runs past its timeout.
+	ErrHealthCheckTimeout = errors.New("health check timed out")
 )
 
 // HealthChecker defines functions required to run health checks.
@@ -21,67 +46,520 @@ type HealthChecker interface {
 	HealthCheck(ctx context.Context) error
 }
 
+// cachedCheck reuses a dependency's last health check result until it is older than the ttl. Only one check runs at
+// a time, and concurrent probes wait for its result rather than queueing behind each other.
+type cachedCheck struct {
+	mu        sync.Mutex
+	checker   HealthChecker
+	ttl       time.Duration
+	now       func() time.Time
+	checkedAt time.Time
+	err       error
+	running   chan struct{}
+}
+
+func (c *cachedCheck) HealthCheck(ctx context.Context) error {
+	c.mu.Lock()
+
+	for {
+		if !c.checkedAt.IsZero() && c.now().Sub(c.checkedAt) < c.ttl {
+			err := c.err
+			c.mu.Unlock()
+
+			return err
+		}
+
+		if c.running == nil {
+			break
+		}
+
+		running := c.running
+		c.mu.Unlock()
+
+		select {
+		case <-running:
+		case <-ctx.Done():
+			return ctx.Err() //nolint: wrapcheck
+		}
+
+		c.mu.Lock()
+	}
+
+	done := make(chan struct{})
+	c.running = done
+	checkedAt := c.now()
+	c.mu.Unlock()
+
+	err := c.checker.HealthCheck(ctx)
+
+	c.mu.Lock()
+
+	// A check cut short by its caller says nothing about the dependency, so the next probe checks again
+	if ctx.Err() == nil {
+		c.err = err
+		c.checkedAt = checkedAt
+	}
+
+	c.running = nil
+	c.mu.Unlock()
+	close(done)
+
+	return err
+}
+
+// dependencyResults are dependency health results that marshal with their names in sorted order. Each result is
+// encoded with the server JSON encoder, while the order is kept here, so it holds for encoders that do not sort map
+// keys.
+type dependencyResults struct {
+	results map[string]any
+	encode  JSONEncoder
+}
+
+func (d dependencyResults) IsZero() bool {
+	return len(d.results) == 0
+}
+
+func (d dependencyResults) MarshalJSON() ([]byte, error) {
+	var buffer bytes.Buffer
+
+	buffer.WriteByte('{')
+
+	for i, name := range slices.Sorted(maps.Keys(d.results)) {
+		if i > 0 {
+			buffer.WriteByte(',')
+		}
+
+		key, err := json.Marshal(name)
+		if err != nil {
+			return nil, err //nolint: wrapcheck
+		}
+
+		buffer.Write(key)
+		buffer.WriteByte(':')
+
+		// Whitespace the encoder adds, such as a trailing newline, is still valid JSON between values
+		if err := d.encode(&buffer, d.results[name]); err != nil {
+			return nil, err
+		}
+	}
+
+	buffer.WriteByte('}')
+
+	return buffer.Bytes(), nil
+}
+
 type serviceHealth struct {
-	name string
-	err  error
+	name     string
+	err      error
+	duration time.Duration
+}
+
+// dependencyDetail is a dependency result that includes how long its health check took.
+type dependencyDetail struct {
+	Status     string    `json:"status"`
+	Error      any       `json:"error,omitempty"`
+	CheckedAt  time.Time `json:"checked_at,omitzero"`
+	DurationMS float64   `json:"duration_ms"`
+}
+
+// dependencyError returns a health check error as it appears in JSON output. Errors that do not marshal to JSON
+// themselves are shown as their message.
+func dependencyError(err error) any {
+	if data, jsonErr := json.Marshal(err); jsonErr != nil || string(data) == "{}" {
+		return err.Error()
+	}
+
+	return err
+}
+
+// startCheck runs a health check in its own goroutine, first waiting for a slot when the number of concurrent checks
+// is limited. A check whose context is done before it gets a slot fails with the context error.
+func (s *Server) startCheck(ctx context.Context, name string, checker HealthChecker, out chan<- serviceHealth) {
+	if s.healthSlots == nil {
+		go s.checkService(ctx, name, checker, out)
+
+		return
+	}
+
+	select {
+	case s.healthSlots <- struct{}{}:
+	case <-ctx.Done():
+		out <- serviceHealth{name: name, err: ctx.Err(), duration: 0}
+
+		return
+	}
+
+	go func() {
+		defer func() { <-s.healthSlots }()
+
+		s.checkService(ctx, name, checker, out)
+	}()
 }
 
 func (s *Server) checkService(ctx context.Context, name string, checker HealthChecker, out chan<- serviceHealth) {
+	start := time.Now()
+	err := s.runCheck(ctx, name, checker)
+
 	out <- serviceHealth{
-		name: name,
-		err:  checker.HealthCheck(ctx),
+		name:     name,
+		err:      err,
+		duration: time.Since(start),
+	}
+}
+
+// checkTimeout returns how long a dependency's health check may run, or 0 for no limit.
+func (s *Server) checkTimeout(name string) time.Duration {
+	if timeout, ok := s.healthTimeouts[name]; ok {
+		return timeout
+	}
+
+	return s.healthTimeout
+}
+
+// runCheck runs a dependency's health check, failing it with ErrHealthCheckTimeout once its timeout passes. A checker
+// that ignores its context runs on in the background until it returns, but no longer holds up the caller.
+func (s *Server) runCheck(ctx context.Context, name string, checker HealthChecker) error {
+	timeout := s.checkTimeout(name)
+	if timeout <= 0 {
+		return checker.HealthCheck(ctx)
+	}
+
+	ctx, cancel := context.WithTimeoutCause(ctx, timeout, fmt.Errorf("%w after %s", ErrHealthCheckTimeout, timeout))
+	defer cancel()
+
+	// Buffered so the check can still finish and exit after a timeout
+	result := make(chan error, 1)
+
+	go func() {
+		result <- checker.HealthCheck(ctx)
+	}()
+
+	select {
+	case err := <-result:
+		if err != nil && ctx.Err() != nil {
+			return context.Cause(ctx)
+		}
+
+		return err
+	case <-ctx.Done():
+		return context.Cause(ctx)
+	}
+}
+
+// WaitForDependencies blocks until every health dependency reports healthy or the context is done. It is intended
+// to be called before Start so the server does not take traffic before its dependencies are reachable. It returns
+// once the context is done even if a check ignores its context, reporting checks that never finished with the
+// context error.
+func (s *Server) WaitForDependencies(ctx context.Context) error {
+	pending := make(map[string]error, len(s.healthDependencies))
+	for name := range s.healthDependencies {
+		pending[name] = nil
+	}
+
+	ticker := time.NewTicker(dependencyPollInterval)
+	defer ticker.Stop()
+
+	for {
+		// Buffered so checks that finish after the context is done can still exit
+		serviceChan := make(chan serviceHealth, len(pending))
+		running := make(map[string]bool, len(pending))
+
+		for name := range pending {
+			running[name] = true
+			s.startCheck(ctx, name, s.healthDependencies[name], serviceChan)
+		}
+
+		for range len(pending) {
+			select {
+			case health := <-serviceChan:
+				delete(running, health.name)
+
+				if health.err == nil {
+					delete(pending, health.name)
+
+					continue
+				}
+
+				pending[health.name] = health.err
+			case <-ctx.Done():
+				// Checks that failed before keep their last error
+				for name := range running {
+					if pending[name] == nil {
+						pending[name] = context.Cause(ctx)
+					}
+				}
+
+				return dependenciesError(pending)
+			}
+		}
+
+		if len(pending) == 0 {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return dependenciesError(pending)
+		case <-ticker.C:
+		}
 	}
 }
 
-func (s *Server) healthCheckHandler() http.Handler {
+// dependenciesError describes the dependencies that did not become healthy, with their last error.
+func dependenciesError(pending map[string]error) error {
+	details := make([]string, 0, len(pending))
+	for _, name := range slices.Sorted(maps.Keys(pending)) {
+		details = append(details, fmt.Sprintf("%s (%v)", name, pending[name]))
+	}
+
+	return fmt.Errorf("%w: %s", ErrDependenciesUnhealthy, strings.Join(details, ", "))
+}
+
+// unhealthyCode returns the status code health checks respond with when a dependency is unhealthy.
+func (s *Server) unhealthyCode(name string) int {
+	if code, ok := s.unhealthyCodes[name]; ok {
+		return code
+	}
+
+	return http.StatusInternalServerError
+}
+
+// dependencyResult returns a dependency health check result as it appears in the verbose health output.
+func (s *Server) dependencyResult(health serviceHealth) any {
+	if !s.healthTimings {
+		if health.err == nil {
+			return healthyStatus
+		}
+
+		return dependencyError(health.err)
+	}
+
+	return newDependencyDetail(health)
+}
+
+func newDependencyDetail(health serviceHealth) dependencyDetail {
+	detail := dependencyDetail{
+		Status:     healthyStatus,
+		Error:      nil,
+		CheckedAt:  time.Time{},
+		DurationMS: float64(health.duration) / float64(time.Millisecond),
+	}
+
+	if health.err != nil {
+		detail.Status = unhealthyStatus
+		detail.Error = dependencyError(health.err)
+	}
+
+	return detail
+}
+
+// skippedResult returns the verbose health output for a dependency that was not checked.
+func (s *Server) skippedResult() any {
+	if !s.healthTimings {
+		return skippedStatus
+	}
+
+	return dependencyDetail{
+		Status:     skippedStatus,
+		Error:      nil,
+		CheckedAt:  time.Time{},
+		DurationMS: 0,
+	}
+}
+
+// readinessChecks returns the dependencies checked for readiness, which are the health dependencies along with any
+// readiness only dependencies.
+func (s *Server) readinessChecks() map[string]HealthChecker {
+	if len(s.readyDependencies) == 0 {
+		return s.healthDependencies
+	}
+
+	checks := maps.Clone(s.healthDependencies)
+	maps.Copy(checks, s.readyDependencies)
+
+	return checks
+}
+
+// writeK8sHealth writes health results in the plain text format of the Kubernetes health endpoints, with one line per
+// dependency followed by the overall result, such as "healthz check passed".
+func writeK8sHealth(
+	writer io.Writer,
+	endpoint string,
+	checks map[string]HealthChecker,
+	healthyNames []string,
+	pending map[string]bool,
+) {
+	var buffer bytes.Buffer
+
+	passed := true
+
+	for _, name := range slices.Sorted(maps.Keys(checks)) {
+		switch {
+		case pending[name]:
+			passed = false
+			fmt.Fprintf(&buffer, "[-]%s failed: %s\n", name, skippedStatus)
+		case slices.Contains(healthyNames, name):
+			fmt.Fprintf(&buffer, "[+]%s ok\n", name)
+		default:
+			passed = false
+			fmt.Fprintf(&buffer, "[-]%s failed: reason withheld\n", name)
+		}
+	}
+
+	if passed {
+		fmt.Fprintf(&buffer, "%s check passed\n", endpoint)
+	} else {
+		fmt.Fprintf(&buffer, "%s check failed\n", endpoint)
+	}
+
+	_, _ = writer.Write(buffer.Bytes())
+}
+
+func (s *Server) healthCheckHandler(endpoint string, dependencies func() map[string]HealthChecker) http.Handler {
 	return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
-		writer.Header().Add("Content-Type", "application/json")
+		checks := dependencies()
+		verbose := request.URL.Query().Has(verboseParam)
+		k8s := request.URL.Query().Get(formatParam) == k8sFormat
+		logged := logEnabled(request.Context(), zerolog.InfoLevel)
+
+		switch {
+		case k8s:
+			writer.Header().Add("Content-Type", "text/plain; charset=utf-8")
+			writer.Header().Add("X-Content-Type-Options", "nosniff")
+		case verbose:
+			writer.Header().Add("Content-Type", "application/json")
+		default:
+			writer.Header().Add("Content-Type", s.healthContentType)
+		}
 
 		result := struct {
-			Status       string         `json:"status"`
-			Uptime       time.Duration  `json:"uptime"`
-			Dependencies map[string]any `json:"dependencies,omitempty"`
+			Status       string            `json:"status"`
+			Uptime       time.Duration     `json:"uptime"`
+			Dependencies dependencyResults `json:"dependencies,omitzero"`
 		}{
 			Status:       healthyStatus,
 			Uptime:       s.Uptime(),
-			Dependencies: make(map[string]any, 0),
+			Dependencies: dependencyResults{results: make(map[string]any, len(checks)), encode: s.encodeJSON},
 		}
 
-		serviceChan := make(chan serviceHealth)
+		ctx, cancel := context.WithCancel(request.Context())
+		defer cancel()
+
+		// Buffered so checks still running after a fail fast can finish without blocking
+		serviceChan := make(chan serviceHealth, len(checks))
+		healthyNames := make([]string, 0, len(checks))
+		pending := make(map[string]bool, len(checks))
 
-		for name, checker := range s.healthDependencies {
-			go s.checkService(request.Context(), name, checker, serviceChan)
+		for name, checker := range checks {
+			pending[name] = true
+
+			s.startCheck(ctx, name, checker, serviceChan)
 		}
 
-		for range s.healthDependencies {
+		// The status is written once every check is in, so the most severe unhealthy code can be chosen
+		code := http.StatusOK
+
+		for len(pending) > 0 {
 			health := <-serviceChan
 
-			result.Dependencies[health.name] = healthyStatus
+			delete(pending, health.name)
+
+			// Dependency results are only built when something will read them, which keeps probes cheap
+			if verbose || logged {
+				result.Dependencies.results[health.name] = s.dependencyResult(health)
+			}
 
 			if health.err == nil {
+				healthyNames = append(healthyNames, health.name)
+
 				continue
 			}
 
-			result.Dependencies[health.name] = health.err
-			if data, err := json.Marshal(health.err); err != nil || string(data) == "{}" {
-				result.Dependencies[health.name] = health.err.Error()
+			result.Status = unhealthyStatus
+			code = max(code, s.unhealthyCode(health.name))
+
+			if s.failFastHealth {
+				cancel()
+
+				break
 			}
+		}
+
+		for name := range pending {
+			result.Dependencies.results[name] = s.skippedResult()
+		}
+
+		if code != http.StatusOK {
+			writer.WriteHeader(code)
+		}
+
+		if logged {
+			zerolog.Ctx(request.Context()).Info().Interface("health", result).Msg("health check")
+		}
 
-			// This extra check stops a "superfluous call to response.WriteHeader"
-			if result.Status == healthyStatus {
-				result.Status = unhealthyStatus
+		if k8s {
+			writeK8sHealth(writer, endpoint, checks, healthyNames, pending)
 
-				writer.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		if !verbose {
+			if result.Status == healthyStatus && s.healthOKBody != "" {
+				_, _ = writer.Write([]byte(s.healthOKBody))
 			}
+
+			return
 		}
 
-		zerolog.Ctx(request.Context()).Info().Interface("health", result).Msg("health check")
+		if request.URL.Query().Has(failuresOnlyParam) {
+			for _, name := range healthyNames {
+				delete(result.Dependencies.results, name)
+			}
+		}
+
+		_ = s.encodeJSON(writer, &result)
+	})
+}
+
+func (s *Server) readinessHandler() http.Handler {
+	health := s.healthCheckHandler("readyz", s.readinessChecks)
+
+	return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		status := ""
+
+		switch {
+		case s.isDraining():
+			status = drainingStatus
+		case s.warmingUp():
+			status = warmingUpStatus
+		}
+
+		if status == "" {
+			health.ServeHTTP(writer, request)
+
+			return
+		}
+
+		writer.Header().Add("Content-Type", "application/json")
+		writer.WriteHeader(http.StatusServiceUnavailable)
+
+		result := struct {
+			Status string        `json:"status"`
+			Uptime time.Duration `json:"uptime"`
+		}{
+			Status: status,
+			Uptime: s.Uptime(),
+		}
+
+		if logEnabled(request.Context(), zerolog.InfoLevel) {
+			zerolog.Ctx(request.Context()).Info().Interface("health", result).Msg("readiness check")
+		}
 
 		if !request.URL.Query().Has(verboseParam) {
 			return
 		}
 
-		_ = json.NewEncoder(writer).Encode(&result)
+		_ = s.encodeJSON(writer, &result)
 	})
 }
 
@@ -96,23 +574,34 @@ func (s *Server) dependencyHealthCheckHandler(name string) http.Handler {
 
 		writer.Header().Add("Content-Type", "application/json")
 
+		checkedAt := s.now()
+		start := time.Now()
+		err := s.runCheck(request.Context(), name, checker)
+		health := serviceHealth{name: name, err: err, duration: time.Since(start)}
+
 		result := map[string]any{name: healthyStatus}
 
-		if err := checker.HealthCheck(request.Context()); err != nil {
-			writer.WriteHeader(http.StatusInternalServerError)
+		if err != nil {
+			writer.WriteHeader(s.unhealthyCode(name))
 
-			result[name] = err
-			if data, jsonErr := json.Marshal(err); jsonErr != nil || string(data) == "{}" {
-				result[name] = err.Error()
-			}
+			result[name] = dependencyError(err)
 		}
 
-		zerolog.Ctx(request.Context()).Info().Interface("health", result).Msg("health check")
+		if s.dependencyDetails {
+			detail := newDependencyDetail(health)
+			detail.CheckedAt = checkedAt.UTC()
+
+			result[name] = detail
+		}
+
+		if logEnabled(request.Context(), zerolog.InfoLevel) {
+			zerolog.Ctx(request.Context()).Info().Interface("health", result).Msg("health check")
+		}
 
 		if !request.URL.Query().Has(verboseParam) {
 			return
 		}
 
-		_ = json.NewEncoder(writer).Encode(result[name])
+		_ = s.encodeJSON(writer, result[name])
 	})
 }
@@ -8,7 +8,10 @@ import (
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/b-sea/go-server/server"
 	"github.com/stretchr/testify/assert"
@@ -16,6 +19,7 @@ import (
 
 var (
 	_ server.HealthChecker = (*HealthCheck)(nil)
+	_ server.HealthChecker = (*DelayedHealthCheck)(nil)
 )
 
 type JSONError struct {
@@ -44,6 +48,18 @@ func (m *HealthCheck) HealthCheck(context.Context) error {
 	return m.Err
 }
 
+type DelayedHealthCheck struct {
+	HealthyAt time.Time
+}
+
+func (m *DelayedHealthCheck) HealthCheck(context.Context) error {
+	if time.Now().Before(m.HealthyAt) {
+		return errors.New("not yet")
+	}
+
+	return nil
+}
+
 func TestServerHealth(t *testing.T) {
 	type testCase struct {
 		url        string
@@ -89,6 +105,12 @@ func TestServerHealth(t *testing.T) {
 			result:     "{\"status\":\"unhealthy\",\"uptime\":0,\"dependencies\":{\"sub-system\":\"something bad\"}}\n",
 			statusCode: http.StatusInternalServerError,
 		},
+		"unhealthy verbose special characters": {
+			url:        "/health?verbose",
+			option:     server.WithHealthDependency("sub-system", &HealthCheck{Err: errors.New("connection refused: a<b & c>d")}),
+			result:     "{\"status\":\"unhealthy\",\"uptime\":0,\"dependencies\":{\"sub-system\":\"connection refused: a<b & c>d\"}}\n",
+			statusCode: http.StatusInternalServerError,
+		},
 		"unhealthy verbose with dependencies marshal": {
 			url:        "/health?verbose",
 			option:     server.WithHealthDependency("sub-system", &HealthCheck{Err: &JSONError{Inner: "extra details"}}),
@@ -165,6 +187,13 @@ func TestDependencyHealth(t *testing.T) {
 			statusCode:  http.StatusInternalServerError,
 			contentType: "application/json",
 		},
+		"unhealthy verbose special characters": {
+			url:         "/health/sub-system?verbose",
+			option:      server.WithHealthDependency("sub-system", &HealthCheck{Err: errors.New("connection refused: a<b & c>d")}),
+			result:      "\"connection refused: a<b & c>d\"\n",
+			statusCode:  http.StatusInternalServerError,
+			contentType: "application/json",
+		},
 		"unhealthy verbose marshal": {
 			url:         "/health/sub-system?verbose",
 			option:      server.WithHealthDependency("sub-system", &HealthCheck{Err: &JSONError{Inner: "extra details"}}),
@@ -205,3 +234,1239 @@ func TestDependencyHealth(t *testing.T) {
 		})
 	}
 }
+
+func TestServerHealthOrdering(t *testing.T) {
+	type testCase struct {
+		encoder server.JSONEncoder
+		encoded int
+	}
+
+	var encoded atomic.Int32
+
+	// The counting encoder shows each dependency result is encoded with the server encoder, not only the response
+	counting := func(writer io.Writer, value any) error {
+		encoded.Add(1)
+
+		return json.NewEncoder(writer).Encode(value)
+	}
+
+	tests := map[string]testCase{
+		"default encoder": {
+			encoder: nil,
+			encoded: 0,
+		},
+		"custom encoder": {
+			encoder: counting,
+			encoded: 6,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			encoded.Store(0)
+
+			testServer := httptest.NewServer(
+				server.New(
+					context.Background(),
+					&server.NoOpRecorder{},
+					server.WithJSONEncoder(test.encoder),
+					server.WithHealthDependency("zeta", &HealthCheck{}),
+					server.WithHealthDependency("alpha", &HealthCheck{Err: errors.New("something bad")}),
+					server.WithHealthDependency("mid", &HealthCheck{}),
+					server.WithHealthDependency("beta", &HealthCheck{Err: &JSONError{Inner: "extra details"}}),
+					server.WithHealthDependency("omega", &HealthCheck{}),
+				),
+			)
+			defer testServer.Close()
+
+			expected := "{\"status\":\"unhealthy\",\"uptime\":0,\"dependencies\":{" +
+				"\"alpha\":\"something bad\"," +
+				"\"beta\":{\"details\":\"extra details\"}," +
+				"\"mid\":\"healthy\"," +
+				"\"omega\":\"healthy\"," +
+				"\"zeta\":\"healthy\"}}\n"
+
+			code, body, err := fetch(t, testServer.URL+"/health?verbose")
+			assert.NoError(t, err)
+			assert.Equal(t, http.StatusInternalServerError, code)
+			assert.Equal(t, expected, body)
+			assert.Equal(t, int32(test.encoded), encoded.Load())
+
+			for range 10 {
+				_, body, err := fetch(t, testServer.URL+"/health?verbose")
+				assert.NoError(t, err)
+				assert.Equal(t, expected, body)
+			}
+		})
+	}
+}
+
+func TestServerReadinessDependency(t *testing.T) {
+	type testCase struct {
+		options        []server.Option
+		healthCode     int
+		readinessCode  int
+		readinessCheck string
+	}
+
+	tests := map[string]testCase{
+		"readiness dependency unhealthy": {
+			options: []server.Option{
+				server.WithHealthDependency("database", &HealthCheck{}),
+				server.WithReadinessDependency("cache", &HealthCheck{Err: errors.New("cache down")}),
+			},
+			healthCode:     http.StatusOK,
+			readinessCode:  http.StatusInternalServerError,
+			readinessCheck: `"cache":"cache down"`,
+		},
+		"readiness dependency healthy": {
+			options: []server.Option{
+				server.WithHealthDependency("database", &HealthCheck{}),
+				server.WithReadinessDependency("cache", &HealthCheck{}),
+			},
+			healthCode:     http.StatusOK,
+			readinessCode:  http.StatusOK,
+			readinessCheck: `"cache":"healthy"`,
+		},
+		"health dependency unhealthy": {
+			options: []server.Option{
+				server.WithHealthDependency("database", &HealthCheck{Err: errors.New("database down")}),
+				server.WithReadinessDependency("cache", &HealthCheck{}),
+			},
+			healthCode:     http.StatusInternalServerError,
+			readinessCode:  http.StatusInternalServerError,
+			readinessCheck: `"database":"database down"`,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			testServer := httptest.NewServer(server.New(context.Background(), &server.NoOpRecorder{}, test.options...))
+			defer testServer.Close()
+
+			code, body, err := fetch(t, testServer.URL+"/health?verbose")
+			assert.NoError(t, err)
+			assert.Equal(t, test.healthCode, code)
+			assert.NotContains(t, body, `"cache"`)
+
+			code, body, err = fetch(t, testServer.URL+"/ready?verbose")
+			assert.NoError(t, err)
+			assert.Equal(t, test.readinessCode, code)
+			assert.Contains(t, body, test.readinessCheck)
+		})
+	}
+}
+
+func TestServerHealthK8sFormat(t *testing.T) {
+	type testCase struct {
+		options      []server.Option
+		path         string
+		expectedCode int
+		expectedBody string
+	}
+
+	tests := map[string]testCase{
+		"healthy": {
+			options: []server.Option{
+				server.WithHealthDependency("database", &HealthCheck{}),
+				server.WithHealthDependency("cache", &HealthCheck{}),
+			},
+			path:         "/health?format=k8s",
+			expectedCode: http.StatusOK,
+			expectedBody: "[+]cache ok\n[+]database ok\nhealthz check passed\n",
+		},
+		"unhealthy": {
+			options: []server.Option{
+				server.WithHealthDependency("database", &HealthCheck{Err: errors.New("connection refused")}),
+				server.WithHealthDependency("cache", &HealthCheck{}),
+			},
+			path:         "/health?format=k8s&verbose",
+			expectedCode: http.StatusInternalServerError,
+			expectedBody: "[+]cache ok\n[-]database failed: reason withheld\nhealthz check failed\n",
+		},
+		"no dependencies": {
+			options:      nil,
+			path:         "/health?format=k8s",
+			expectedCode: http.StatusOK,
+			expectedBody: "healthz check passed\n",
+		},
+		"readiness": {
+			options: []server.Option{
+				server.WithHealthDependency("database", &HealthCheck{}),
+				server.WithReadinessDependency("cache", &HealthCheck{Err: errors.New("cache down")}),
+			},
+			path:         "/ready?format=k8s",
+			expectedCode: http.StatusInternalServerError,
+			expectedBody: "[-]cache failed: reason withheld\n[+]database ok\nreadyz check failed\n",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			testServer := httptest.NewServer(server.New(context.Background(), &server.NoOpRecorder{}, test.options...))
+			defer testServer.Close()
+
+			request, _ := http.NewRequestWithContext(context.Background(), http.MethodGet, testServer.URL+test.path, nil)
+			request.Close = true
+
+			response, err := http.DefaultClient.Do(request)
+			assert.NoError(t, err)
+
+			body, err := io.ReadAll(response.Body)
+			assert.NoError(t, err)
+			assert.NoError(t, response.Body.Close())
+
+			assert.Equal(t, test.expectedCode, response.StatusCode)
+			assert.Equal(t, test.expectedBody, string(body))
+			assert.Equal(t, "text/plain; charset=utf-8", response.Header.Get("Content-Type"))
+		})
+	}
+}
+
+func TestServerHealthDependencyCode(t *testing.T) {
+	type testCase struct {
+		options      []server.Option
+		path         string
+		expectedCode int
+	}
+
+	upstreamDown := server.WithHealthDependencyCode(
+		"upstream", &HealthCheck{Err: errors.New("upstream down")}, http.StatusBadGateway,
+	)
+	queueDown := server.WithHealthDependencyCode(
+		"queue", &HealthCheck{Err: errors.New("queue full")}, http.StatusServiceUnavailable,
+	)
+
+	tests := map[string]testCase{
+		"one custom code": {
+			options:      []server.Option{upstreamDown, server.WithHealthDependency("database", &HealthCheck{})},
+			path:         "/health",
+			expectedCode: http.StatusBadGateway,
+		},
+		"highest code wins": {
+			options:      []server.Option{upstreamDown, queueDown},
+			path:         "/health",
+			expectedCode: http.StatusServiceUnavailable,
+		},
+		"custom code over default": {
+			options: []server.Option{
+				upstreamDown,
+				server.WithHealthDependency("database", &HealthCheck{Err: errors.New("database down")}),
+			},
+			path:         "/health",
+			expectedCode: http.StatusBadGateway,
+		},
+		"healthy": {
+			options: []server.Option{
+				server.WithHealthDependencyCode("upstream", &HealthCheck{}, http.StatusBadGateway),
+			},
+			path:         "/health",
+			expectedCode: http.StatusOK,
+		},
+		"dependency endpoint": {
+			options:      []server.Option{upstreamDown, queueDown},
+			path:         "/health/upstream",
+			expectedCode: http.StatusBadGateway,
+		},
+		"readiness": {
+			options:      []server.Option{upstreamDown, queueDown},
+			path:         "/ready",
+			expectedCode: http.StatusServiceUnavailable,
+		},
+		"invalid code": {
+			options: []server.Option{
+				server.WithHealthDependencyCode("upstream", &HealthCheck{Err: errors.New("upstream down")}, http.StatusOK),
+			},
+			path:         "/health",
+			expectedCode: http.StatusInternalServerError,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			testServer := httptest.NewServer(server.New(context.Background(), &server.NoOpRecorder{}, test.options...))
+			defer testServer.Close()
+
+			code, _, err := fetch(t, testServer.URL+test.path)
+			assert.NoError(t, err)
+			assert.Equal(t, test.expectedCode, code)
+		})
+	}
+}
+
+func TestServerUptimeFromCreation(t *testing.T) {
+	type testCase struct {
+		options        []server.Option
+		expectedUptime string
+	}
+
+	tests := map[string]testCase{
+		"from start": {
+			options:        nil,
+			expectedUptime: `"uptime":0`,
+		},
+		"from creation": {
+			options:        []server.Option{server.WithUptimeFromCreation()},
+			expectedUptime: `"uptime":30000000000`,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			clock := NewFakeClock()
+
+			svr := server.New(
+				context.Background(),
+				&server.NoOpRecorder{},
+				append([]server.Option{server.WithClock(clock.Now)}, test.options...)...,
+			)
+
+			testServer := httptest.NewServer(svr)
+			defer testServer.Close()
+
+			clock.Advance(30 * time.Second)
+
+			code, body, err := fetch(t, testServer.URL+"/health?verbose")
+			assert.NoError(t, err)
+			assert.Equal(t, http.StatusOK, code)
+			assert.Contains(t, body, test.expectedUptime)
+		})
+	}
+}
+
+func TestServerReadinessWarmup(t *testing.T) {
+	port := findOpenPort(t)
+	clock := NewFakeClock()
+
+	svr := server.New(
+		context.Background(),
+		&server.NoOpRecorder{},
+		server.WithPort(port),
+		server.WithClock(clock.Now),
+		server.WithWarmupPeriod(time.Minute),
+	)
+
+	done := make(chan struct{})
+
+	go func() {
+		assert.NoError(t, svr.Start(context.Background()))
+		close(done)
+	}()
+
+	url := fmt.Sprintf("http://localhost:%d", port)
+	waitForServer(t, url)
+
+	ready := func() (int, string) {
+		request, _ := http.NewRequestWithContext(context.Background(), http.MethodGet, url+"/ready?verbose", nil)
+		request.Close = true
+
+		response, err := http.DefaultClient.Do(request)
+		assert.NoError(t, err)
+
+		body, err := io.ReadAll(response.Body)
+		assert.NoError(t, err)
+
+		assert.NoError(t, response.Body.Close())
+
+		return response.StatusCode, string(body)
+	}
+
+	statusCode, body := ready()
+	assert.Equal(t, http.StatusServiceUnavailable, statusCode)
+	assert.Equal(t, "{\"status\":\"warming up\",\"uptime\":0}\n", body)
+
+	clock.Advance(59 * time.Second)
+
+	statusCode, body = ready()
+	assert.Equal(t, http.StatusServiceUnavailable, statusCode)
+	assert.Equal(t, "{\"status\":\"warming up\",\"uptime\":59000000000}\n", body)
+
+	clock.Advance(time.Second)
+
+	statusCode, body = ready()
+	assert.Equal(t, http.StatusOK, statusCode)
+	assert.Equal(t, "{\"status\":\"healthy\",\"uptime\":60000000000}\n", body)
+
+	assert.NoError(t, svr.Stop(context.Background()))
+	<-done
+}
+
+func TestServerReadinessNotStarted(t *testing.T) {
+	testServer := httptest.NewServer(
+		server.New(
+			context.Background(),
+			&server.NoOpRecorder{},
+			server.WithWarmupPeriod(time.Minute),
+			server.WithHealthDependency("sub-system", &HealthCheck{Err: errors.New("something bad")}),
+		),
+	)
+
+	request, _ := http.NewRequestWithContext(context.Background(), http.MethodGet, testServer.URL+"/ready?verbose", nil)
+	request.Close = true
+
+	response, err := http.DefaultClient.Do(request)
+	assert.NoError(t, err)
+
+	body, err := io.ReadAll(response.Body)
+	assert.NoError(t, err)
+
+	assert.NoError(t, response.Body.Close())
+
+	assert.Equal(t, http.StatusInternalServerError, response.StatusCode)
+	assert.Equal(t, "{\"status\":\"unhealthy\",\"uptime\":0,\"dependencies\":{\"sub-system\":\"something bad\"}}\n", string(body))
+
+	testServer.Close()
+}
+
+func TestWaitForDependencies(t *testing.T) {
+	type testCase struct {
+		options []server.Option
+		err     string
+	}
+
+	tests := map[string]testCase{
+		"no dependencies": {
+			options: nil,
+			err:     "",
+		},
+		"becomes healthy": {
+			options: []server.Option{
+				server.WithHealthDependency("healthy", &HealthCheck{}),
+				server.WithHealthDependency("delayed", &DelayedHealthCheck{HealthyAt: time.Now().Add(250 * time.Millisecond)}),
+			},
+			err: "",
+		},
+		"never healthy": {
+			options: []server.Option{
+				server.WithHealthDependency("healthy", &HealthCheck{}),
+				server.WithHealthDependency("never", &HealthCheck{Err: errors.New("something bad")}),
+				server.WithHealthDependency("also-never", &DelayedHealthCheck{HealthyAt: time.Now().Add(time.Hour)}),
+			},
+			err: "dependencies unhealthy: also-never (not yet), never (something bad)",
+		},
+		"never returns": {
+			options: []server.Option{
+				server.WithHealthDependency("healthy", &HealthCheck{}),
+				server.WithHealthDependency("stuck", &StuckHealthCheck{Release: make(chan struct{})}),
+			},
+			err: "dependencies unhealthy: stuck (context deadline exceeded)",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			svr := server.New(context.Background(), &server.NoOpRecorder{}, test.options...)
+
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			defer cancel()
+
+			start := time.Now()
+
+			err := svr.WaitForDependencies(ctx)
+			assert.Less(t, time.Since(start), 2*time.Second)
+
+			if test.err == "" {
+				assert.NoError(t, err)
+
+				return
+			}
+
+			assert.ErrorIs(t, err, server.ErrDependenciesUnhealthy)
+			assert.EqualError(t, err, test.err)
+		})
+	}
+}
+
+func TestHealthDependencies(t *testing.T) {
+	testServer := httptest.NewServer(
+		server.New(
+			context.Background(),
+			&server.NoOpRecorder{},
+			server.WithHealthDependencies(map[string]server.HealthChecker{
+				"database": &HealthCheck{},
+				"cache":    &HealthCheck{Err: errors.New("something bad")},
+				"queue":    &HealthCheck{},
+			}),
+		),
+	)
+
+	get := func(path string) (int, string) {
+		request, _ := http.NewRequestWithContext(context.Background(), http.MethodGet, testServer.URL+path, nil)
+		request.Close = true
+
+		response, err := http.DefaultClient.Do(request)
+		assert.NoError(t, err)
+
+		body, err := io.ReadAll(response.Body)
+		assert.NoError(t, err)
+
+		assert.NoError(t, response.Body.Close())
+
+		return response.StatusCode, string(body)
+	}
+
+	statusCode, body := get("/health?verbose")
+	assert.Equal(t, http.StatusInternalServerError, statusCode)
+	assert.Equal(
+		t,
+		"{\"status\":\"unhealthy\",\"uptime\":0,\"dependencies\":"+
+			"{\"cache\":\"something bad\",\"database\":\"healthy\",\"queue\":\"healthy\"}}\n",
+		body,
+	)
+
+	for path, expected := range map[string]int{
+		"/health/database": http.StatusOK,
+		"/health/cache":    http.StatusInternalServerError,
+		"/health/queue":    http.StatusOK,
+	} {
+		statusCode, _ := get(path)
+		assert.Equal(t, expected, statusCode, path)
+	}
+
+	testServer.Close()
+}
+
+func TestServerHealthFailuresOnly(t *testing.T) {
+	type testCase struct {
+		dependencies map[string]server.HealthChecker
+		statusCode   int
+		result       string
+	}
+
+	tests := map[string]testCase{
+		"mixed": {
+			dependencies: map[string]server.HealthChecker{
+				"database": &HealthCheck{},
+				"cache":    &HealthCheck{Err: errors.New("something bad")},
+				"queue":    &HealthCheck{Err: errors.New("connection refused")},
+				"search":   &HealthCheck{},
+			},
+			statusCode: http.StatusInternalServerError,
+			result: "{\"status\":\"unhealthy\",\"uptime\":0,\"dependencies\":" +
+				"{\"cache\":\"something bad\",\"queue\":\"connection refused\"}}\n",
+		},
+		"all healthy": {
+			dependencies: map[string]server.HealthChecker{
+				"database": &HealthCheck{},
+				"cache":    &HealthCheck{},
+			},
+			statusCode: http.StatusOK,
+			result:     "{\"status\":\"healthy\",\"uptime\":0}\n",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			testServer := httptest.NewServer(
+				server.New(context.Background(), &server.NoOpRecorder{}, server.WithHealthDependencies(test.dependencies)),
+			)
+
+			request, _ := http.NewRequestWithContext(
+				context.Background(),
+				http.MethodGet,
+				testServer.URL+"/health?verbose&failures-only",
+				nil,
+			)
+			request.Close = true
+
+			response, err := http.DefaultClient.Do(request)
+			assert.NoError(t, err)
+
+			body, err := io.ReadAll(response.Body)
+			assert.NoError(t, err)
+
+			assert.NoError(t, response.Body.Close())
+
+			assert.Equal(t, test.statusCode, response.StatusCode)
+			assert.Equal(t, test.result, string(body))
+
+			testServer.Close()
+		})
+	}
+}
+
+func TestServerHealthOKBody(t *testing.T) {
+	type testCase struct {
+		options     []server.Option
+		url         string
+		err         error
+		statusCode  int
+		contentType string
+		result      string
+	}
+
+	tests := map[string]testCase{
+		"default": {
+			options:     nil,
+			url:         "/health",
+			err:         nil,
+			statusCode:  http.StatusOK,
+			contentType: "application/json",
+			result:      "",
+		},
+		"custom body": {
+			options:     []server.Option{server.WithHealthOKBody("OK"), server.WithHealthContentType("text/plain")},
+			url:         "/health",
+			err:         nil,
+			statusCode:  http.StatusOK,
+			contentType: "text/plain",
+			result:      "OK",
+		},
+		"custom body unhealthy": {
+			options:     []server.Option{server.WithHealthOKBody("OK"), server.WithHealthContentType("text/plain")},
+			url:         "/health",
+			err:         errors.New("something bad"),
+			statusCode:  http.StatusInternalServerError,
+			contentType: "text/plain",
+			result:      "",
+		},
+		"custom body verbose": {
+			options:     []server.Option{server.WithHealthOKBody("OK"), server.WithHealthContentType("text/plain")},
+			url:         "/health?verbose",
+			err:         nil,
+			statusCode:  http.StatusOK,
+			contentType: "application/json",
+			result:      "{\"status\":\"healthy\",\"uptime\":0,\"dependencies\":{\"database\":\"healthy\"}}\n",
+		},
+		"custom body ready": {
+			options:     []server.Option{server.WithHealthOKBody("OK")},
+			url:         "/ready",
+			err:         nil,
+			statusCode:  http.StatusOK,
+			contentType: "application/json",
+			result:      "OK",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			options := append(
+				[]server.Option{server.WithHealthDependency("database", &HealthCheck{Err: test.err})},
+				test.options...,
+			)
+
+			testServer := httptest.NewServer(server.New(context.Background(), &server.NoOpRecorder{}, options...))
+
+			request, _ := http.NewRequestWithContext(context.Background(), http.MethodGet, testServer.URL+test.url, nil)
+			request.Close = true
+
+			response, err := http.DefaultClient.Do(request)
+			assert.NoError(t, err)
+
+			body, err := io.ReadAll(response.Body)
+			assert.NoError(t, err)
+
+			assert.NoError(t, response.Body.Close())
+
+			assert.Equal(t, test.statusCode, response.StatusCode)
+			assert.Equal(t, test.contentType, response.Header.Get("Content-Type"))
+			assert.Equal(t, test.result, string(body))
+
+			testServer.Close()
+		})
+	}
+}
+
+type CountingHealthCheck struct {
+	calls atomic.Int32
+}
+
+func (m *CountingHealthCheck) HealthCheck(context.Context) error {
+	m.calls.Add(1)
+
+	return nil
+}
+
+func TestCachedHealthDependency(t *testing.T) {
+	clock := NewFakeClock()
+	cached := &CountingHealthCheck{}
+	uncached := &CountingHealthCheck{}
+
+	testServer := httptest.NewServer(
+		server.New(
+			context.Background(),
+			&server.NoOpRecorder{},
+			server.WithClock(clock.Now),
+			server.WithCachedHealthDependency("database", cached, 10*time.Second),
+			server.WithHealthDependency("memory", uncached),
+		),
+	)
+
+	check := func() {
+		request, _ := http.NewRequestWithContext(context.Background(), http.MethodGet, testServer.URL+"/health", nil)
+		request.Close = true
+
+		response, err := http.DefaultClient.Do(request)
+		assert.NoError(t, err)
+		assert.NoError(t, response.Body.Close())
+		assert.Equal(t, http.StatusOK, response.StatusCode)
+	}
+
+	for range 3 {
+		check()
+		clock.Advance(time.Second)
+	}
+
+	assert.Equal(t, int32(1), cached.calls.Load())
+	assert.Equal(t, int32(3), uncached.calls.Load())
+
+	clock.Advance(7 * time.Second)
+	check()
+
+	assert.Equal(t, int32(2), cached.calls.Load())
+	assert.Equal(t, int32(4), uncached.calls.Load())
+
+	testServer.Close()
+}
+
+// FirstCallBlocksHealthCheck blocks its first check until the context is done, and passes every check after it.
+type FirstCallBlocksHealthCheck struct {
+	calls atomic.Int32
+}
+
+func (m *FirstCallBlocksHealthCheck) HealthCheck(ctx context.Context) error {
+	if m.calls.Add(1) > 1 {
+		return nil
+	}
+
+	<-ctx.Done()
+
+	return ctx.Err()
+}
+
+func TestCachedHealthDependencyCancelled(t *testing.T) {
+	checker := &FirstCallBlocksHealthCheck{}
+
+	testServer := httptest.NewServer(
+		server.New(
+			context.Background(),
+			&server.NoOpRecorder{},
+			server.WithHealthCheckTimeout(50*time.Millisecond),
+			server.WithCachedHealthDependency("database", checker, time.Minute),
+		),
+	)
+
+	for _, expected := range []int{http.StatusInternalServerError, http.StatusOK, http.StatusOK} {
+		request, _ := http.NewRequestWithContext(context.Background(), http.MethodGet, testServer.URL+"/health", nil)
+		request.Close = true
+
+		response, err := http.DefaultClient.Do(request)
+		assert.NoError(t, err)
+		assert.NoError(t, response.Body.Close())
+		assert.Equal(t, expected, response.StatusCode)
+	}
+
+	assert.Equal(t, int32(2), checker.calls.Load())
+
+	testServer.Close()
+}
+
+// GatedHealthCheck counts its checks, which pass once released.
+type GatedHealthCheck struct {
+	Release chan struct{}
+	calls   atomic.Int32
+}
+
+func (m *GatedHealthCheck) HealthCheck(context.Context) error {
+	m.calls.Add(1)
+	<-m.Release
+
+	return nil
+}
+
+func TestCachedHealthDependencyConcurrent(t *testing.T) {
+	checker := &GatedHealthCheck{Release: make(chan struct{})}
+
+	testServer := httptest.NewServer(
+		server.New(
+			context.Background(),
+			&server.NoOpRecorder{},
+			server.WithCachedHealthDependency("database", checker, time.Minute),
+		),
+	)
+
+	wg := sync.WaitGroup{}
+
+	for range 3 {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			request, _ := http.NewRequestWithContext(context.Background(), http.MethodGet, testServer.URL+"/health", nil)
+			request.Close = true
+
+			response, err := http.DefaultClient.Do(request)
+			if !assert.NoError(t, err) {
+				return
+			}
+
+			assert.NoError(t, response.Body.Close())
+			assert.Equal(t, http.StatusOK, response.StatusCode)
+		}()
+	}
+
+	assert.Eventually(t, func() bool { return checker.calls.Load() == 1 }, time.Second, time.Millisecond)
+	close(checker.Release)
+	wg.Wait()
+
+	assert.Equal(t, int32(1), checker.calls.Load())
+
+	testServer.Close()
+}
+
+type SlowHealthCheck struct {
+	Delay time.Duration
+	Err   error
+}
+
+func (m *SlowHealthCheck) HealthCheck(context.Context) error {
+	time.Sleep(m.Delay)
+
+	return m.Err
+}
+
+func TestServerHealthTimings(t *testing.T) {
+	testServer := httptest.NewServer(
+		server.New(
+			context.Background(),
+			&server.NoOpRecorder{},
+			server.WithHealthTimings(),
+			server.WithHealthDependencies(map[string]server.HealthChecker{
+				"database": &SlowHealthCheck{Delay: 50 * time.Millisecond, Err: nil},
+				"cache":    &SlowHealthCheck{Delay: 0, Err: errors.New("something bad")},
+			}),
+		),
+	)
+
+	request, _ := http.NewRequestWithContext(context.Background(), http.MethodGet, testServer.URL+"/health?verbose", nil)
+	request.Close = true
+
+	response, err := http.DefaultClient.Do(request)
+	assert.NoError(t, err)
+
+	var result struct {
+		Status       string `json:"status"`
+		Dependencies map[string]struct {
+			Status     string   `json:"status"`
+			Error      string   `json:"error"`
+			DurationMS *float64 `json:"duration_ms"`
+		} `json:"dependencies"`
+	}
+
+	assert.NoError(t, json.NewDecoder(response.Body).Decode(&result))
+	assert.NoError(t, response.Body.Close())
+
+	assert.Equal(t, http.StatusInternalServerError, response.StatusCode)
+	assert.Equal(t, "unhealthy", result.Status)
+
+	database := result.Dependencies["database"]
+	assert.Equal(t, "healthy", database.Status)
+	assert.Empty(t, database.Error)
+
+	if assert.NotNil(t, database.DurationMS) {
+		assert.GreaterOrEqual(t, *database.DurationMS, 50.0)
+	}
+
+	cache := result.Dependencies["cache"]
+	assert.Equal(t, "unhealthy", cache.Status)
+	assert.Equal(t, "something bad", cache.Error)
+	assert.NotNil(t, cache.DurationMS)
+
+	testServer.Close()
+}
+
+func TestDependencyHealthDetails(t *testing.T) {
+	type testCase struct {
+		checker    server.HealthChecker
+		statusCode int
+		status     string
+		err        string
+	}
+
+	tests := map[string]testCase{
+		"healthy": {
+			checker:    &SlowHealthCheck{Delay: 20 * time.Millisecond, Err: nil},
+			statusCode: http.StatusOK,
+			status:     "healthy",
+			err:        "",
+		},
+		"unhealthy": {
+			checker:    &SlowHealthCheck{Delay: 20 * time.Millisecond, Err: errors.New("something bad")},
+			statusCode: http.StatusInternalServerError,
+			status:     "unhealthy",
+			err:        "something bad",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			clock := NewFakeClock()
+
+			testServer := httptest.NewServer(
+				server.New(
+					context.Background(),
+					&server.NoOpRecorder{},
+					server.WithClock(clock.Now),
+					server.WithDependencyDetails(),
+					server.WithHealthDependency("database", test.checker),
+				),
+			)
+
+			endpoint := testServer.URL + "/health/database?verbose"
+			request, _ := http.NewRequestWithContext(context.Background(), http.MethodGet, endpoint, nil)
+			request.Close = true
+
+			response, err := http.DefaultClient.Do(request)
+			assert.NoError(t, err)
+
+			var result struct {
+				Status     string    `json:"status"`
+				Error      string    `json:"error"`
+				CheckedAt  time.Time `json:"checked_at"`
+				DurationMS *float64  `json:"duration_ms"`
+			}
+
+			assert.NoError(t, json.NewDecoder(response.Body).Decode(&result))
+			assert.NoError(t, response.Body.Close())
+
+			assert.Equal(t, test.statusCode, response.StatusCode)
+			assert.Equal(t, test.status, result.Status)
+			assert.Equal(t, test.err, result.Error)
+			assert.Equal(t, clock.Now(), result.CheckedAt)
+
+			if assert.NotNil(t, result.DurationMS) {
+				assert.GreaterOrEqual(t, *result.DurationMS, 20.0)
+			}
+
+			testServer.Close()
+		})
+	}
+}
+
+type ConcurrencyHealthCheck struct {
+	current atomic.Int32
+	peak    atomic.Int32
+}
+
+func (m *ConcurrencyHealthCheck) HealthCheck(context.Context) error {
+	current := m.current.Add(1)
+	defer m.current.Add(-1)
+
+	for {
+		peak := m.peak.Load()
+		if current <= peak || m.peak.CompareAndSwap(peak, current) {
+			break
+		}
+	}
+
+	time.Sleep(5 * time.Millisecond)
+
+	return nil
+}
+
+func TestServerHealthGoroutineLimit(t *testing.T) {
+	checker := &ConcurrencyHealthCheck{}
+
+	testServer := httptest.NewServer(
+		server.New(
+			context.Background(),
+			&server.NoOpRecorder{},
+			server.WithHealthGoroutineLimit(3),
+			server.WithHealthDependencies(map[string]server.HealthChecker{
+				"database": checker,
+				"cache":    checker,
+				"queue":    checker,
+				"search":   checker,
+			}),
+		),
+	)
+
+	var wg sync.WaitGroup
+
+	for range 20 {
+		wg.Go(func() {
+			request, _ := http.NewRequestWithContext(context.Background(), http.MethodGet, testServer.URL+"/health", nil)
+			request.Close = true
+
+			response, err := http.DefaultClient.Do(request)
+			if !assert.NoError(t, err) {
+				return
+			}
+
+			assert.NoError(t, response.Body.Close())
+			assert.Equal(t, http.StatusOK, response.StatusCode)
+		})
+	}
+
+	wg.Wait()
+	testServer.Close()
+
+	assert.LessOrEqual(t, checker.peak.Load(), int32(3))
+	assert.Positive(t, checker.peak.Load())
+}
+
+type BlockingHealthCheck struct {
+	Cancelled chan struct{}
+}
+
+func (m *BlockingHealthCheck) HealthCheck(ctx context.Context) error {
+	select {
+	case <-ctx.Done():
+		close(m.Cancelled)
+
+		return ctx.Err()
+	case <-time.After(5 * time.Second):
+		return nil
+	}
+}
+
+func TestServerFailFastHealth(t *testing.T) {
+	slow := &BlockingHealthCheck{Cancelled: make(chan struct{})}
+
+	testServer := httptest.NewServer(
+		server.New(
+			context.Background(),
+			&server.NoOpRecorder{},
+			server.WithFailFastHealth(),
+			server.WithHealthDependencies(map[string]server.HealthChecker{
+				"database": &HealthCheck{Err: errors.New("something bad")},
+				"search":   slow,
+			}),
+		),
+	)
+
+	request, _ := http.NewRequestWithContext(context.Background(), http.MethodGet, testServer.URL+"/health?verbose", nil)
+	request.Close = true
+
+	start := time.Now()
+
+	response, err := http.DefaultClient.Do(request)
+	assert.NoError(t, err)
+
+	body, err := io.ReadAll(response.Body)
+	assert.NoError(t, err)
+
+	assert.NoError(t, response.Body.Close())
+
+	assert.Less(t, time.Since(start), time.Second)
+	assert.Equal(t, http.StatusInternalServerError, response.StatusCode)
+	assert.Equal(
+		t,
+		"{\"status\":\"unhealthy\",\"uptime\":0,\"dependencies\":"+
+			"{\"database\":\"something bad\",\"search\":\"skipped\"}}\n",
+		string(body),
+	)
+
+	select {
+	case <-slow.Cancelled:
+	case <-time.After(time.Second):
+		t.Error("slow check was not cancelled")
+	}
+
+	testServer.Close()
+}
+
+// StuckHealthCheck ignores its context and only returns once released.
+type StuckHealthCheck struct {
+	Release  chan struct{}
+	Finished atomic.Bool
+}
+
+func (m *StuckHealthCheck) HealthCheck(context.Context) error {
+	<-m.Release
+	m.Finished.Store(true)
+
+	return nil
+}
+
+func TestServerHealthCheckTimeout(t *testing.T) {
+	type testCase struct {
+		options    func(slow server.HealthChecker) []server.Option
+		path       string
+		statusCode int
+		result     string
+		stuck      bool
+	}
+
+	tests := map[string]testCase{
+		"no timeout": {
+			options: func(server.HealthChecker) []server.Option {
+				return []server.Option{server.WithHealthDependency("database", &HealthCheck{})}
+			},
+			path:       "/health?verbose",
+			statusCode: http.StatusOK,
+			result:     "{\"status\":\"healthy\",\"uptime\":0,\"dependencies\":{\"database\":\"healthy\"}}\n",
+			stuck:      false,
+		},
+		"default timeout": {
+			options: func(slow server.HealthChecker) []server.Option {
+				return []server.Option{
+					server.WithHealthCheckTimeout(20 * time.Millisecond),
+					server.WithHealthDependency("database", &HealthCheck{}),
+					server.WithHealthDependency("search", slow),
+				}
+			},
+			path:       "/health?verbose",
+			statusCode: http.StatusInternalServerError,
+			result: "{\"status\":\"unhealthy\",\"uptime\":0,\"dependencies\":" +
+				"{\"database\":\"healthy\",\"search\":\"health check timed out after 20ms\"}}\n",
+			stuck: true,
+		},
+		"dependency timeout": {
+			options: func(slow server.HealthChecker) []server.Option {
+				return []server.Option{
+					server.WithHealthDependency("database", &HealthCheck{}),
+					server.WithHealthDependencyTimeout("search", slow, 30*time.Millisecond),
+				}
+			},
+			path:       "/health?verbose",
+			statusCode: http.StatusInternalServerError,
+			result: "{\"status\":\"unhealthy\",\"uptime\":0,\"dependencies\":" +
+				"{\"database\":\"healthy\",\"search\":\"health check timed out after 30ms\"}}\n",
+			stuck: true,
+		},
+		"dependency timeout overrides default": {
+			options: func(slow server.HealthChecker) []server.Option {
+				return []server.Option{
+					server.WithHealthCheckTimeout(time.Minute),
+					server.WithHealthDependencyTimeout("search", slow, 20*time.Millisecond),
+				}
+			},
+			path:       "/health?verbose",
+			statusCode: http.StatusInternalServerError,
+			result: "{\"status\":\"unhealthy\",\"uptime\":0,\"dependencies\":" +
+				"{\"search\":\"health check timed out after 20ms\"}}\n",
+			stuck: true,
+		},
+		"invalid dependency timeout": {
+			options: func(server.HealthChecker) []server.Option {
+				return []server.Option{server.WithHealthDependencyTimeout("database", &HealthCheck{}, -time.Second)}
+			},
+			path:       "/health?verbose",
+			statusCode: http.StatusOK,
+			result:     "{\"status\":\"healthy\",\"uptime\":0,\"dependencies\":{\"database\":\"healthy\"}}\n",
+			stuck:      false,
+		},
+		"dependency endpoint": {
+			options: func(slow server.HealthChecker) []server.Option {
+				return []server.Option{server.WithHealthDependencyTimeout("search", slow, 20*time.Millisecond)}
+			},
+			path:       "/health/search?verbose",
+			statusCode: http.StatusInternalServerError,
+			result:     "\"health check timed out after 20ms\"\n",
+			stuck:      true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			slow := &StuckHealthCheck{Release: make(chan struct{}), Finished: atomic.Bool{}}
+
+			testServer := httptest.NewServer(
+				server.New(context.Background(), &server.NoOpRecorder{}, test.options(slow)...),
+			)
+
+			request, _ := http.NewRequestWithContext(context.Background(), http.MethodGet, testServer.URL+test.path, nil)
+			request.Close = true
+
+			start := time.Now()
+
+			response, err := http.DefaultClient.Do(request)
+			assert.NoError(t, err)
+
+			body, err := io.ReadAll(response.Body)
+			assert.NoError(t, err)
+			assert.NoError(t, response.Body.Close())
+
+			testServer.Close()
+
+			assert.Less(t, time.Since(start), time.Second)
+			assert.Equal(t, test.statusCode, response.StatusCode)
+			assert.Equal(t, test.result, string(body))
+
+			close(slow.Release)
+
+			// The abandoned check still finishes once its checker returns
+			if test.stuck {
+				assert.Eventually(t, slow.Finished.Load, time.Second, time.Millisecond)
+			}
+		})
+	}
+}
+
+func TestServerHealthCheckTimeoutCancels(t *testing.T) {
+	slow := &BlockingHealthCheck{Cancelled: make(chan struct{})}
+
+	svr := server.New(
+		context.Background(),
+		&server.NoOpRecorder{},
+		server.WithHealthDependencyTimeout("search", slow, 20*time.Millisecond),
+	)
+
+	response := httptest.NewRecorder()
+	svr.ServeHTTP(response, httptest.NewRequest(http.MethodGet, "/health", nil))
+
+	assert.Equal(t, http.StatusInternalServerError, response.Code)
+
+	select {
+	case <-slow.Cancelled:
+	case <-time.After(time.Second):
+		t.Error("timed out check was not cancelled")
+	}
+}
+
+func TestServerHealthCheckClientDisconnect(t *testing.T) {
+	type testCase struct {
+		path    string
+		options func(check server.HealthChecker) []server.Option
+	}
+
+	tests := map[string]testCase{
+		"health": {
+			path: "/health",
+			options: func(check server.HealthChecker) []server.Option {
+				return []server.Option{server.WithHealthDependency("database", check)}
+			},
+		},
+		"dependency endpoint": {
+			path: "/health/database",
+			options: func(check server.HealthChecker) []server.Option {
+				return []server.Option{server.WithHealthDependency("database", check)}
+			},
+		},
+		"with timeout": {
+			path: "/health",
+			options: func(check server.HealthChecker) []server.Option {
+				return []server.Option{server.WithHealthDependencyTimeout("database", check, time.Minute)}
+			},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			check := &BlockingHealthCheck{Cancelled: make(chan struct{})}
+
+			testServer := httptest.NewServer(
+				server.New(context.Background(), &server.NoOpRecorder{}, test.options(check)...),
+			)
+			defer testServer.Close()
+
+			ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+			defer cancel()
+
+			request, _ := http.NewRequestWithContext(ctx, http.MethodGet, testServer.URL+test.path, nil)
+			request.Close = true
+
+			_, err := http.DefaultClient.Do(request) //nolint: bodyclose
+			assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+			// The check sees the request context cancelled once the client goes away
+			select {
+			case <-check.Cancelled:
+			case <-time.After(time.Second):
+				t.Error("check was not cancelled when the client disconnected")
+			}
+		})
+	}
+}
@@ -0,0 +1,266 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"maps"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	idempotencyHeader       = "Idempotency-Key"
+	idempotentReplayHeader  = "Idempotent-Replayed"
+	idempotencySweepEntries = 1024
+)
+
+// unsavedHeaders are set per request by the server middleware rather than by the handler, so they are not saved
+// with a response. Replaying them would label a plain body with the first request's encoding, or reuse its
+// correlation ID and trace.
+var unsavedHeaders = []string{ //nolint: gochecknoglobals
+	contentEncodingHeader,
+	"Content-Length",
+	"Vary",
+	correlationHeader,
+	traceparentHeader,
+	serverTimingHeader,
+}
+
+// CachedResponse is a response saved for an idempotency key.
+type CachedResponse struct {
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+}
+
+// IdempotencyStore saves responses by idempotency key so retried requests can be answered without running the
+// handler again.
+type IdempotencyStore interface {
+	Get(ctx context.Context, key string) (CachedResponse, bool)
+	Set(ctx context.Context, key string, response CachedResponse)
+}
+
+// IdempotencyScope returns the caller a request belongs to, such as an authenticated user or tenant. Idempotency keys
+// are scoped to it, so two callers that send the same key never receive each other's responses.
+type IdempotencyScope func(request *http.Request) string
+
+// clientAddressScope is the default IdempotencyScope, which scopes keys to the client address without its port, so
+// retries over a new connection still match.
+func clientAddressScope(request *http.Request) string {
+	host, _, err := net.SplitHostPort(request.RemoteAddr)
+	if err != nil {
+		return request.RemoteAddr
+	}
+
+	return host
+}
+
+var _ IdempotencyStore = (*MemoryIdempotencyStore)(nil)
+
+type idempotencyEntry struct {
+	response  CachedResponse
+	expiresAt time.Time
+}
+
+// MemoryIdempotencyStore is an in-memory IdempotencyStore whose responses expire after a ttl. It only deduplicates
+// requests handled by the same process.
+type MemoryIdempotencyStore struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	now     func() time.Time
+	entries map[string]idempotencyEntry
+}
+
+// NewMemoryIdempotencyStore creates a new MemoryIdempotencyStore.
+func NewMemoryIdempotencyStore(ttl time.Duration) *MemoryIdempotencyStore {
+	return &MemoryIdempotencyStore{
+		mu:      sync.Mutex{},
+		ttl:     ttl,
+		now:     time.Now,
+		entries: make(map[string]idempotencyEntry),
+	}
+}
+
+// Get returns the response saved for a key, if it has not expired.
+func (m *MemoryIdempotencyStore) Get(_ context.Context, key string) (CachedResponse, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	entry, ok := m.entries[key]
+	if !ok {
+		return CachedResponse{StatusCode: 0, Header: nil, Body: nil}, false
+	}
+
+	if !m.now().Before(entry.expiresAt) {
+		delete(m.entries, key)
+
+		return CachedResponse{StatusCode: 0, Header: nil, Body: nil}, false
+	}
+
+	return entry.response, true
+}
+
+// Set saves the response for a key until the ttl passes.
+func (m *MemoryIdempotencyStore) Set(_ context.Context, key string, response CachedResponse) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.now()
+
+	// Expired entries are swept as the store grows, so keys that are never retried do not pile up
+	if len(m.entries) >= idempotencySweepEntries {
+		maps.DeleteFunc(m.entries, func(_ string, entry idempotencyEntry) bool {
+			return !now.Before(entry.expiresAt)
+		})
+	}
+
+	m.entries[key] = idempotencyEntry{response: response, expiresAt: now.Add(m.ttl)}
+}
+
+// captureWriter copies a response as it is written, so it can be saved for its idempotency key.
+type captureWriter struct {
+	http.ResponseWriter
+
+	statusCode  int
+	wroteHeader bool
+	body        bytes.Buffer
+}
+
+func (w *captureWriter) WriteHeader(statusCode int) {
+	if !w.wroteHeader && statusCode >= http.StatusOK {
+		w.wroteHeader = true
+		w.statusCode = statusCode
+	}
+
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (w *captureWriter) Write(p []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+
+	w.body.Write(p)
+
+	return w.ResponseWriter.Write(p) //nolint: wrapcheck
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (w *captureWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// idempotency replays saved responses for requests that repeat an idempotency key, and saves the response of the
+// first request with each key. Requests with the same key wait for the one in flight instead of running together.
+type idempotency struct {
+	store  IdempotencyStore
+	header string
+	scope  IdempotencyScope
+
+	mu       sync.Mutex
+	inFlight map[string]chan struct{}
+}
+
+func newIdempotency(store IdempotencyStore, header string) *idempotency {
+	return &idempotency{
+		store:    store,
+		header:   header,
+		scope:    clientAddressScope,
+		mu:       sync.Mutex{},
+		inFlight: make(map[string]chan struct{}),
+	}
+}
+
+// acquire waits until no other request with the key is in flight, and returns the function that releases the key.
+func (i *idempotency) acquire(ctx context.Context, key string) (func(), error) {
+	for {
+		i.mu.Lock()
+
+		wait, ok := i.inFlight[key]
+		if !ok {
+			done := make(chan struct{})
+			i.inFlight[key] = done
+			i.mu.Unlock()
+
+			return func() {
+				i.mu.Lock()
+				delete(i.inFlight, key)
+				i.mu.Unlock()
+				close(done)
+			}, nil
+		}
+
+		i.mu.Unlock()
+
+		select {
+		case <-wait:
+		case <-ctx.Done():
+			return nil, ctx.Err() //nolint: wrapcheck
+		}
+	}
+}
+
+func (i *idempotency) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		value := request.Header.Get(i.header)
+		if value == "" {
+			next.ServeHTTP(writer, request)
+
+			return
+		}
+
+		// Keys are scoped to the caller and the endpoint, so the same key sent by two callers or to two endpoints does
+		// not collide
+		key := i.scope(request) + " " + request.Method + " " + request.URL.Path + " " + value
+
+		release, err := i.acquire(request.Context(), key)
+		if err != nil {
+			http.Error(writer, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+
+			return
+		}
+		defer release()
+
+		if cached, ok := i.store.Get(request.Context(), key); ok {
+			maps.Copy(writer.Header(), cached.Header)
+			writer.Header().Set(idempotentReplayHeader, "true")
+			writer.WriteHeader(cached.StatusCode)
+			_, _ = writer.Write(cached.Body)
+
+			return
+		}
+
+		capture := &captureWriter{
+			ResponseWriter: writer,
+			statusCode:     http.StatusOK,
+			wroteHeader:    false,
+			body:           bytes.Buffer{},
+		}
+
+		next.ServeHTTP(capture, request)
+
+		// Server errors are not saved so the client can retry them
+		if capture.statusCode >= http.StatusInternalServerError {
+			return
+		}
+
+		i.store.Set(request.Context(), key, CachedResponse{
+			StatusCode: capture.statusCode,
+			Header:     savedHeader(writer.Header()),
+			Body:       capture.body.Bytes(),
+		})
+	})
+}
+
+// savedHeader returns the response headers to save for an idempotency key.
+func savedHeader(header http.Header) http.Header {
+	saved := header.Clone()
+
+	for _, name := range unsavedHeaders {
+		saved.Del(name)
+	}
+
+	return saved
+}
@@ -0,0 +1,306 @@
+package server_test
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/b-sea/go-server/server"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIdempotency(t *testing.T) {
+	type testCase struct {
+		code          int
+		keys          []string
+		expectedCalls int32
+		expectedCodes []int
+		expectedBody  []string
+		replayed      []string
+	}
+
+	tests := map[string]testCase{
+		"replayed key": {
+			code:          http.StatusCreated,
+			keys:          []string{"abc", "abc"},
+			expectedCalls: 1,
+			expectedCodes: []int{http.StatusCreated, http.StatusCreated},
+			expectedBody:  []string{"call 1", "call 1"},
+			replayed:      []string{"", "true"},
+		},
+		"different keys": {
+			code:          http.StatusCreated,
+			keys:          []string{"abc", "def"},
+			expectedCalls: 2,
+			expectedCodes: []int{http.StatusCreated, http.StatusCreated},
+			expectedBody:  []string{"call 1", "call 2"},
+			replayed:      []string{"", ""},
+		},
+		"no key": {
+			code:          http.StatusCreated,
+			keys:          []string{"", ""},
+			expectedCalls: 2,
+			expectedCodes: []int{http.StatusCreated, http.StatusCreated},
+			expectedBody:  []string{"call 1", "call 2"},
+			replayed:      []string{"", ""},
+		},
+		"server error": {
+			code:          http.StatusInternalServerError,
+			keys:          []string{"abc", "abc"},
+			expectedCalls: 2,
+			expectedCodes: []int{http.StatusInternalServerError, http.StatusInternalServerError},
+			expectedBody:  []string{"call 1", "call 2"},
+			replayed:      []string{"", ""},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			calls := atomic.Int32{}
+
+			svr := server.New(
+				context.Background(),
+				&server.NoOpRecorder{},
+				server.WithIdempotency(server.NewMemoryIdempotencyStore(time.Minute), ""),
+			)
+
+			svr.Router().Handle("/pay", http.HandlerFunc(func(writer http.ResponseWriter, _ *http.Request) {
+				call := calls.Add(1)
+
+				writer.Header().Set("X-Call", fmt.Sprint(call))
+				writer.WriteHeader(test.code)
+				_, _ = fmt.Fprintf(writer, "call %d", call)
+			})).Methods(http.MethodPost)
+
+			testServer := httptest.NewServer(svr)
+			defer testServer.Close()
+
+			for i, key := range test.keys {
+				request, _ := http.NewRequestWithContext(context.Background(), http.MethodPost, testServer.URL+"/pay", nil)
+				request.Close = true
+
+				if key != "" {
+					request.Header.Set("Idempotency-Key", key)
+				}
+
+				response, err := http.DefaultClient.Do(request)
+				assert.NoError(t, err)
+
+				body, err := io.ReadAll(response.Body)
+				assert.NoError(t, err)
+				assert.NoError(t, response.Body.Close())
+
+				assert.Equal(t, test.expectedCodes[i], response.StatusCode)
+				assert.Equal(t, test.expectedBody[i], string(body))
+				assert.Equal(t, test.replayed[i], response.Header.Get("Idempotent-Replayed"))
+			}
+
+			assert.Equal(t, test.expectedCalls, calls.Load())
+		})
+	}
+}
+
+func TestIdempotencyScope(t *testing.T) {
+	type testCase struct {
+		options       []server.Option
+		remoteAddrs   []string
+		tenants       []string
+		expectedCalls int32
+		replayed      []string
+	}
+
+	tenantScope := func(request *http.Request) string {
+		return request.Header.Get("X-Tenant-Id")
+	}
+
+	tests := map[string]testCase{
+		"different clients": {
+			options:       nil,
+			remoteAddrs:   []string{"192.0.2.1:1234", "192.0.2.2:1234"},
+			tenants:       []string{"", ""},
+			expectedCalls: 2,
+			replayed:      []string{"", ""},
+		},
+		"same client on a new connection": {
+			options:       nil,
+			remoteAddrs:   []string{"192.0.2.1:1234", "192.0.2.1:5678"},
+			tenants:       []string{"", ""},
+			expectedCalls: 1,
+			replayed:      []string{"", "true"},
+		},
+		"different tenants behind one proxy": {
+			options:       []server.Option{server.WithIdempotencyScope(tenantScope)},
+			remoteAddrs:   []string{"192.0.2.1:1234", "192.0.2.1:1234"},
+			tenants:       []string{"acme", "globex"},
+			expectedCalls: 2,
+			replayed:      []string{"", ""},
+		},
+		"same tenant from different clients": {
+			options:       []server.Option{server.WithIdempotencyScope(tenantScope)},
+			remoteAddrs:   []string{"192.0.2.1:1234", "192.0.2.2:1234"},
+			tenants:       []string{"acme", "acme"},
+			expectedCalls: 1,
+			replayed:      []string{"", "true"},
+		},
+		"nil scope": {
+			options:       []server.Option{server.WithIdempotencyScope(nil)},
+			remoteAddrs:   []string{"192.0.2.1:1234", "192.0.2.2:1234"},
+			tenants:       []string{"", ""},
+			expectedCalls: 2,
+			replayed:      []string{"", ""},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			calls := atomic.Int32{}
+
+			options := append(
+				[]server.Option{server.WithIdempotency(server.NewMemoryIdempotencyStore(time.Minute), "")},
+				test.options...,
+			)
+
+			svr := server.New(context.Background(), &server.NoOpRecorder{}, options...)
+			svr.Router().Handle("/pay", http.HandlerFunc(func(writer http.ResponseWriter, _ *http.Request) {
+				_, _ = fmt.Fprintf(writer, "call %d", calls.Add(1))
+			})).Methods(http.MethodPost)
+
+			for i, remoteAddr := range test.remoteAddrs {
+				request := httptest.NewRequest(http.MethodPost, "/pay", nil)
+				request.RemoteAddr = remoteAddr
+				request.Header.Set("Idempotency-Key", "abc")
+				request.Header.Set("X-Tenant-Id", test.tenants[i])
+
+				response := httptest.NewRecorder()
+				svr.ServeHTTP(response, request)
+
+				assert.Equal(t, http.StatusOK, response.Code)
+				assert.Equal(t, test.replayed[i], response.Header().Get("Idempotent-Replayed"))
+			}
+
+			assert.Equal(t, test.expectedCalls, calls.Load())
+		})
+	}
+}
+
+func TestIdempotencyReplayHeaders(t *testing.T) {
+	svr := server.New(
+		context.Background(),
+		&server.NoOpRecorder{},
+		server.WithCompression(),
+		server.WithTraceparent(),
+		server.WithIdempotency(server.NewMemoryIdempotencyStore(time.Minute), ""),
+	)
+
+	svr.Router().Handle("/pay", http.HandlerFunc(func(writer http.ResponseWriter, _ *http.Request) {
+		writer.Header().Set("Content-Type", "text/plain")
+		writer.Header().Set("X-Receipt", "r1")
+		_, _ = writer.Write([]byte(strings.Repeat("paid ", 100)))
+	})).Methods(http.MethodPost)
+
+	testServer := httptest.NewServer(svr)
+	defer testServer.Close()
+
+	correlationIDs := make([]string, 0)
+	traceparents := make([]string, 0)
+
+	for range 2 {
+		request, _ := http.NewRequestWithContext(context.Background(), http.MethodPost, testServer.URL+"/pay", nil)
+		request.Close = true
+		request.Header.Set("Idempotency-Key", "abc")
+
+		// The transport asks for gzip and transparently decompresses the response
+		response, err := http.DefaultClient.Do(request)
+		assert.NoError(t, err)
+
+		body, err := io.ReadAll(response.Body)
+		assert.NoError(t, err)
+		assert.NoError(t, response.Body.Close())
+
+		assert.True(t, response.Uncompressed)
+		assert.Equal(t, strings.Repeat("paid ", 100), string(body))
+		assert.Equal(t, "r1", response.Header.Get("X-Receipt"))
+
+		correlationIDs = append(correlationIDs, response.Header.Get("Correlation-Id"))
+		traceparents = append(traceparents, response.Header.Get("Traceparent"))
+	}
+
+	assert.NotEqual(t, correlationIDs[0], correlationIDs[1])
+	assert.NotEqual(t, traceparents[0], traceparents[1])
+}
+
+func TestIdempotencyConcurrentKey(t *testing.T) {
+	calls := atomic.Int32{}
+	release := make(chan struct{})
+
+	svr := server.New(
+		context.Background(),
+		&server.NoOpRecorder{},
+		server.WithIdempotency(server.NewMemoryIdempotencyStore(time.Minute), "X-Request-Key"),
+	)
+
+	svr.Router().Handle("/pay", http.HandlerFunc(func(writer http.ResponseWriter, _ *http.Request) {
+		calls.Add(1)
+		<-release
+
+		_, _ = writer.Write([]byte(`paid`))
+	})).Methods(http.MethodPost)
+
+	testServer := httptest.NewServer(svr)
+	defer testServer.Close()
+
+	wg := sync.WaitGroup{}
+
+	for range 3 {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			request, _ := http.NewRequestWithContext(context.Background(), http.MethodPost, testServer.URL+"/pay", nil)
+			request.Close = true
+			request.Header.Set("X-Request-Key", "abc")
+
+			response, err := http.DefaultClient.Do(request)
+			if !assert.NoError(t, err) {
+				return
+			}
+
+			body, err := io.ReadAll(response.Body)
+			assert.NoError(t, err)
+			assert.NoError(t, response.Body.Close())
+
+			assert.Equal(t, "paid", string(body))
+		}()
+	}
+
+	assert.Eventually(t, func() bool { return calls.Load() == 1 }, time.Second, time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	assert.Equal(t, int32(1), calls.Load())
+}
+
+func TestMemoryIdempotencyStoreTTL(t *testing.T) {
+	store := server.NewMemoryIdempotencyStore(50 * time.Millisecond)
+	response := server.CachedResponse{StatusCode: http.StatusOK, Header: http.Header{}, Body: []byte(`ok`)}
+
+	store.Set(context.Background(), "key", response)
+
+	cached, ok := store.Get(context.Background(), "key")
+	assert.True(t, ok)
+	assert.Equal(t, response, cached)
+
+	assert.Eventually(t, func() bool {
+		_, ok := store.Get(context.Background(), "key")
+
+		return !ok
+	}, time.Second, 10*time.Millisecond)
+}
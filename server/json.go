@@ -0,0 +1,105 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+)
+
+// ErrNotJSONArray is returned by DecodeJSONStream when the request body is not a JSON array.
+var ErrNotJSONArray = errors.New("request body is not a JSON array")
+
+// JSONEncoder writes a value to a writer as JSON.
+type JSONEncoder func(writer io.Writer, value any) error
+
+// encodeJSON is the default JSONEncoder. Responses are meant for machines rather than HTML pages, so characters
+// such as <, >, and & are not escaped.
+func encodeJSON(writer io.Writer, value any) error {
+	encoder := json.NewEncoder(writer)
+	encoder.SetEscapeHTML(false)
+
+	return encoder.Encode(value) //nolint: wrapcheck
+}
+
+// WriteJSON writes a JSON response with the given status code using the server JSON encoder.
+func (s *Server) WriteJSON(writer http.ResponseWriter, code int, value any) error {
+	writer.Header().Set("Content-Type", "application/json")
+	writer.WriteHeader(code)
+
+	return s.encodeJSON(writer, value)
+}
+
+// JSONHandler sets the response Content-Type to application/json before calling the handler, for handlers that
+// write JSON themselves. The handler can still set a different Content-Type.
+func JSONHandler(handler http.HandlerFunc) http.Handler {
+	return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		writer.Header().Set("Content-Type", "application/json")
+		handler(writer, request)
+	})
+}
+
+// DecodeJSONStream decodes a request body holding a JSON array one element at a time and calls fn with each element,
+// so large uploads are processed without holding the whole array in memory. Decoding stops at the first error,
+// including any returned by fn.
+func DecodeJSONStream(request *http.Request, fn func(json.RawMessage) error) error {
+	decoder := json.NewDecoder(request.Body)
+
+	token, err := decoder.Token()
+	if err != nil {
+		return err //nolint: wrapcheck
+	}
+
+	if delim, ok := token.(json.Delim); !ok || delim != '[' {
+		return ErrNotJSONArray
+	}
+
+	for decoder.More() {
+		var element json.RawMessage
+
+		if err := decoder.Decode(&element); err != nil {
+			return err //nolint: wrapcheck
+		}
+
+		if err := fn(element); err != nil {
+			return err
+		}
+	}
+
+	// Reading the closing bracket reports a body that was cut off mid array
+	if _, err := decoder.Token(); err != nil {
+		return err //nolint: wrapcheck
+	}
+
+	return nil
+}
+
+// NDJSONWriter streams values as newline delimited JSON, flushing each one to the client as it is written.
+type NDJSONWriter struct {
+	writer     http.ResponseWriter
+	controller *http.ResponseController
+}
+
+// NewNDJSONWriter creates a new NDJSONWriter and sets the response Content-Type to application/x-ndjson.
+func NewNDJSONWriter(writer http.ResponseWriter) *NDJSONWriter {
+	writer.Header().Set("Content-Type", "application/x-ndjson")
+
+	return &NDJSONWriter{
+		writer:     writer,
+		controller: http.NewResponseController(writer),
+	}
+}
+
+// Write encodes a value as a single JSON line and flushes it. Writers that cannot flush, such as those buffered by
+// a request timeout, still receive every line.
+func (w *NDJSONWriter) Write(value any) error {
+	if err := encodeJSON(w.writer, value); err != nil {
+		return err
+	}
+
+	if err := w.controller.Flush(); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		return err //nolint: wrapcheck
+	}
+
+	return nil
+}
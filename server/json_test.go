@@ -0,0 +1,341 @@
+package server_test
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/b-sea/go-server/server"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriteJSON(t *testing.T) {
+	type testCase struct {
+		option server.Option
+		url    string
+		result string
+	}
+
+	indent := func(writer io.Writer, value any) error {
+		encoder := json.NewEncoder(writer)
+		encoder.SetIndent("", " ")
+
+		return encoder.Encode(value)
+	}
+
+	tests := map[string]testCase{
+		"default handler": {
+			option: nil,
+			url:    "/test",
+			result: "{\"name\":\"thing\"}\n",
+		},
+		"custom handler": {
+			option: server.WithJSONEncoder(indent),
+			url:    "/test",
+			result: "{\n \"name\": \"thing\"\n}\n",
+		},
+		"custom health": {
+			option: server.WithJSONEncoder(indent),
+			url:    "/health?verbose",
+			result: "{\n \"status\": \"healthy\",\n \"uptime\": 0\n}\n",
+		},
+		"nil encoder": {
+			option: server.WithJSONEncoder(nil),
+			url:    "/test",
+			result: "{\"name\":\"thing\"}\n",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			options := []server.Option{}
+			if test.option != nil {
+				options = append(options, test.option)
+			}
+
+			svr := server.New(context.Background(), &server.NoOpRecorder{}, options...)
+			svr.Router().Handle(
+				"/test",
+				func() http.HandlerFunc {
+					return func(writer http.ResponseWriter, _ *http.Request) {
+						_ = svr.WriteJSON(writer, http.StatusOK, map[string]string{"name": "thing"})
+					}
+				}(),
+			).Methods(http.MethodGet)
+
+			testServer := httptest.NewServer(svr)
+
+			request, _ := http.NewRequestWithContext(context.Background(), http.MethodGet, testServer.URL+test.url, nil)
+			request.Close = true
+
+			response, err := http.DefaultClient.Do(request)
+			assert.NoError(t, err)
+
+			body, err := io.ReadAll(response.Body)
+			assert.NoError(t, err)
+
+			assert.NoError(t, response.Body.Close())
+
+			assert.Equal(t, http.StatusOK, response.StatusCode)
+			assert.Equal(t, "application/json", response.Header.Get("Content-Type"))
+			assert.Equal(t, test.result, string(body))
+
+			testServer.Close()
+		})
+	}
+}
+
+func TestJSONHandler(t *testing.T) {
+	type testCase struct {
+		handler     http.HandlerFunc
+		contentType string
+	}
+
+	tests := map[string]testCase{
+		"json": {
+			handler: func(writer http.ResponseWriter, _ *http.Request) {
+				_, _ = writer.Write([]byte(`{"name":"thing"}`))
+			},
+			contentType: "application/json",
+		},
+		"overridden": {
+			handler: func(writer http.ResponseWriter, _ *http.Request) {
+				writer.Header().Set("Content-Type", "application/problem+json")
+				_, _ = writer.Write([]byte(`{"title":"bad"}`))
+			},
+			contentType: "application/problem+json",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			svr := server.New(context.Background(), &server.NoOpRecorder{})
+			svr.Router().Handle("/test", server.JSONHandler(test.handler)).Methods(http.MethodGet)
+
+			testServer := httptest.NewServer(svr)
+
+			request, _ := http.NewRequestWithContext(context.Background(), http.MethodGet, testServer.URL+"/test", nil)
+			request.Close = true
+
+			response, err := http.DefaultClient.Do(request)
+			assert.NoError(t, err)
+			assert.NoError(t, response.Body.Close())
+
+			assert.Equal(t, http.StatusOK, response.StatusCode)
+			assert.Equal(t, test.contentType, response.Header.Get("Content-Type"))
+
+			testServer.Close()
+		})
+	}
+}
+
+func TestNDJSONWriter(t *testing.T) {
+	type testCase struct {
+		options        []server.Option
+		acceptEncoding string
+	}
+
+	tests := map[string]testCase{
+		"plain": {
+			options:        nil,
+			acceptEncoding: "identity",
+		},
+		"compressed": {
+			options:        []server.Option{server.WithCompression()},
+			acceptEncoding: "gzip",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			received := make(chan struct{})
+
+			svr := server.New(context.Background(), &server.NoOpRecorder{}, test.options...)
+			svr.Router().Handle("/stream", http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+				stream := server.NewNDJSONWriter(writer)
+
+				assert.NoError(t, stream.Write(map[string]int{"id": 1}))
+
+				// The first line must reach the client before the handler finishes
+				select {
+				case <-received:
+				case <-request.Context().Done():
+					return
+				case <-time.After(5 * time.Second):
+					t.Error("first line was not flushed")
+
+					return
+				}
+
+				assert.NoError(t, stream.Write(map[string]int{"id": 2}))
+				assert.NoError(t, stream.Write(map[string]int{"id": 3}))
+			})).Methods(http.MethodGet)
+
+			testServer := httptest.NewServer(svr)
+
+			request, _ := http.NewRequestWithContext(context.Background(), http.MethodGet, testServer.URL+"/stream", nil)
+			request.Header.Set("Accept-Encoding", test.acceptEncoding)
+			request.Close = true
+
+			response, err := http.DefaultClient.Do(request)
+			assert.NoError(t, err)
+
+			assert.Equal(t, "application/x-ndjson", response.Header.Get("Content-Type"))
+
+			var body io.Reader = response.Body
+
+			if response.Header.Get("Content-Encoding") == "gzip" {
+				body, err = gzip.NewReader(response.Body)
+				assert.NoError(t, err)
+			}
+
+			ids := make([]int, 0)
+			decoder := json.NewDecoder(body)
+
+			for {
+				var record struct {
+					ID int `json:"id"`
+				}
+
+				if err := decoder.Decode(&record); err != nil {
+					assert.ErrorIs(t, err, io.EOF)
+
+					break
+				}
+
+				ids = append(ids, record.ID)
+
+				if record.ID == 1 {
+					close(received)
+				}
+			}
+
+			assert.NoError(t, response.Body.Close())
+			assert.Equal(t, []int{1, 2, 3}, ids)
+
+			testServer.Close()
+		})
+	}
+}
+
+func TestDecodeJSONStream(t *testing.T) {
+	const elements = 100000
+
+	body, writer := io.Pipe()
+
+	// The array is generated as it is read, so it is never held in memory whole
+	go func() {
+		_, _ = writer.Write([]byte(`[`))
+
+		for i := range elements {
+			if i > 0 {
+				_, _ = writer.Write([]byte(`,`))
+			}
+
+			_, _ = fmt.Fprintf(writer, `{"id":%d}`, i)
+		}
+
+		_, _ = writer.Write([]byte(`]`))
+		_ = writer.Close()
+	}()
+
+	count, sum := 0, 0
+
+	request := httptest.NewRequest(http.MethodPost, "/upload", body)
+
+	err := server.DecodeJSONStream(request, func(element json.RawMessage) error {
+		var item struct {
+			ID int `json:"id"`
+		}
+
+		if err := json.Unmarshal(element, &item); err != nil {
+			return err
+		}
+
+		count++
+		sum += item.ID
+
+		return nil
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, elements, count)
+	assert.Equal(t, elements*(elements-1)/2, sum)
+}
+
+func TestDecodeJSONStreamErrors(t *testing.T) {
+	errStop := errors.New("stop")
+
+	type testCase struct {
+		body     string
+		fn       func(json.RawMessage) error
+		elements []string
+		err      string
+	}
+
+	tests := map[string]testCase{
+		"empty array": {
+			body:     `[]`,
+			fn:       nil,
+			elements: []string{},
+			err:      "",
+		},
+		"not an array": {
+			body:     `{"id":1}`,
+			fn:       nil,
+			elements: []string{},
+			err:      server.ErrNotJSONArray.Error(),
+		},
+		"empty body": {
+			body:     ``,
+			fn:       nil,
+			elements: []string{},
+			err:      io.EOF.Error(),
+		},
+		"truncated": {
+			body:     `[{"id":1},{"id":2}`,
+			fn:       nil,
+			elements: []string{`{"id":1}`, `{"id":2}`},
+			err:      "unexpected end of JSON input",
+		},
+		"callback error": {
+			body:     `[1,2,3]`,
+			fn:       func(json.RawMessage) error { return errStop },
+			elements: []string{`1`},
+			err:      errStop.Error(),
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			elements := make([]string, 0)
+
+			request := httptest.NewRequest(http.MethodPost, "/upload", strings.NewReader(test.body))
+
+			err := server.DecodeJSONStream(request, func(element json.RawMessage) error {
+				elements = append(elements, string(element))
+
+				if test.fn != nil {
+					return test.fn(element)
+				}
+
+				return nil
+			})
+
+			if test.err == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, test.err)
+			}
+			assert.Equal(t, test.elements, elements)
+		})
+	}
+}
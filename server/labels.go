@@ -0,0 +1,30 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+)
+
+type metricLabelsKey struct{}
+
+// RecordMetricLabels marks the request context as the one metric labels are read from, such as by
+// WithMetricLabelsFromContext. The server only sees the context a request arrives with, so middleware and handlers
+// that add label values to the context call it once they have. It does nothing outside the server.
+func RecordMetricLabels(request *http.Request) {
+	if labels, ok := request.Context().Value(metricLabelsKey{}).(*atomic.Pointer[context.Context]); ok {
+		ctx := request.Context()
+		labels.Store(&ctx)
+	}
+}
+
+// metricContext returns the context recorded with RecordMetricLabels, or ctx if none was recorded.
+func metricContext(ctx context.Context) context.Context {
+	if labels, ok := ctx.Value(metricLabelsKey{}).(*atomic.Pointer[context.Context]); ok {
+		if recorded := labels.Load(); recorded != nil {
+			return *recorded
+		}
+	}
+
+	return ctx
+}
@@ -0,0 +1,132 @@
+package server
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"time"
+)
+
+// minReadRateWindow is how often a request body's read rate is checked.
+const minReadRateWindow = time.Second
+
+var (
+	// ErrReadTooSlow is returned when reading a request body whose client sends data slower than the minimum read
+	// rate.
+	ErrReadTooSlow = errors.New("request body read too slow")
+
+	// ErrResponseTooLarge is returned when writing a response past the maximum response size.
+	ErrResponseTooLarge = errors.New("response too large")
+)
+
+func (s *Server) headerCountMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		if len(request.Header) > s.maxHeaderCount {
+			http.Error(
+				writer,
+				http.StatusText(http.StatusRequestHeaderFieldsTooLarge),
+				http.StatusRequestHeaderFieldsTooLarge,
+			)
+
+			return
+		}
+
+		next.ServeHTTP(writer, request)
+	})
+}
+
+// minRateReader fails reads once a window passes with fewer bytes than the minimum rate allows. Each read is bounded
+// by a connection read deadline, so a client that stops sending entirely fails the check too instead of blocking the
+// read forever.
+type minRateReader struct {
+	io.ReadCloser
+
+	controller  *http.ResponseController
+	bytesPerSec int
+	now         func() time.Time
+	windowStart time.Time
+	windowBytes int
+	err         error
+}
+
+func (r *minRateReader) Read(p []byte) (int, error) {
+	if r.err != nil {
+		return 0, r.err
+	}
+
+	if r.windowStart.IsZero() {
+		r.windowStart = r.now()
+	}
+
+	r.setDeadline()
+
+	n, err := r.ReadCloser.Read(p)
+	r.windowBytes += n
+
+	// The deadline only passes once the rate can no longer be met. A timed out read also cancels the request, so it
+	// cannot be retried.
+	if errors.Is(err, os.ErrDeadlineExceeded) {
+		r.err = ErrReadTooSlow
+
+		return n, r.err
+	}
+
+	if errors.Is(err, io.EOF) {
+		// The server keeps reading the connection in the background once the body is done
+		_ = r.controller.SetReadDeadline(time.Time{})
+	}
+
+	if elapsed := r.now().Sub(r.windowStart); elapsed >= minReadRateWindow {
+		if float64(r.windowBytes)/elapsed.Seconds() < float64(r.bytesPerSec) {
+			r.err = ErrReadTooSlow
+
+			return n, r.err
+		}
+
+		r.windowStart = r.now()
+		r.windowBytes = 0
+	}
+
+	return n, err //nolint: wrapcheck
+}
+
+// setDeadline sets the connection read deadline to when the current window fails if no more bytes arrive: the end of
+// the window, or the end of the next one once the current window already has enough bytes. Writers that do not
+// support deadlines keep checking the rate between reads only.
+func (r *minRateReader) setDeadline() {
+	windowEnd := r.windowStart.Add(minReadRateWindow)
+	if float64(r.windowBytes) >= float64(r.bytesPerSec)*minReadRateWindow.Seconds() {
+		windowEnd = windowEnd.Add(minReadRateWindow)
+	}
+
+	_ = r.controller.SetReadDeadline(time.Now().Add(windowEnd.Sub(r.now())))
+}
+
+func (s *Server) minReadRateMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		if request.Body != nil && request.Body != http.NoBody {
+			request.Body = &minRateReader{
+				ReadCloser:  request.Body,
+				controller:  http.NewResponseController(writer),
+				bytesPerSec: s.minReadRate,
+				now:         s.now,
+				windowStart: time.Time{},
+				windowBytes: 0,
+				err:         nil,
+			}
+		}
+
+		next.ServeHTTP(writer, request)
+	})
+}
+
+func (s *Server) maxBodySizeMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		if request.Body != nil && request.Body != http.NoBody {
+			request.Body = http.MaxBytesReader(writer, request.Body, s.maxRequestBodySize)
+		}
+
+		next.ServeHTTP(writer, request)
+	})
+}
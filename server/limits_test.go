@@ -0,0 +1,394 @@
+package server_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/b-sea/go-server/server"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMaxHeaderCount(t *testing.T) {
+	type testCase struct {
+		option     server.Option
+		headers    int
+		statusCode int
+	}
+
+	tests := map[string]testCase{
+		"no limit": {
+			option:     nil,
+			headers:    50,
+			statusCode: http.StatusOK,
+		},
+		"under limit": {
+			option:     server.WithMaxHeaderCount(20),
+			headers:    5,
+			statusCode: http.StatusOK,
+		},
+		"over limit": {
+			option:     server.WithMaxHeaderCount(20),
+			headers:    50,
+			statusCode: http.StatusRequestHeaderFieldsTooLarge,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			options := []server.Option{}
+			if test.option != nil {
+				options = append(options, test.option)
+			}
+
+			testServer := httptest.NewServer(server.New(context.Background(), &server.NoOpRecorder{}, options...))
+
+			request, _ := http.NewRequestWithContext(context.Background(), http.MethodGet, testServer.URL+"/ping", nil)
+			request.Close = true
+
+			for i := range test.headers {
+				request.Header.Set(fmt.Sprintf("X-Test-%d", i), "value")
+			}
+
+			response, err := http.DefaultClient.Do(request)
+			assert.NoError(t, err)
+			assert.NoError(t, response.Body.Close())
+
+			assert.Equal(t, test.statusCode, response.StatusCode)
+
+			testServer.Close()
+		})
+	}
+}
+
+type SlowReader struct {
+	remaining int
+	delay     time.Duration
+}
+
+func (r *SlowReader) Read(p []byte) (int, error) {
+	if r.remaining == 0 {
+		return 0, io.EOF
+	}
+
+	time.Sleep(r.delay)
+
+	r.remaining--
+	p[0] = 'x'
+
+	return 1, nil
+}
+
+// StalledReader sends its data, then blocks until released.
+type StalledReader struct {
+	data    []byte
+	Release chan struct{}
+}
+
+func (r *StalledReader) Read(p []byte) (int, error) {
+	if len(r.data) > 0 {
+		n := copy(p, r.data)
+		r.data = r.data[n:]
+
+		return n, nil
+	}
+
+	<-r.Release
+
+	return 0, io.EOF
+}
+
+func TestMinReadRate(t *testing.T) {
+	type testCase struct {
+		body       func() io.Reader
+		statusCode int
+	}
+
+	release := make(chan struct{})
+	defer close(release)
+
+	tests := map[string]testCase{
+		"fast body": {
+			body:       func() io.Reader { return strings.NewReader(strings.Repeat("x", 4096)) },
+			statusCode: http.StatusOK,
+		},
+		"slow body": {
+			body:       func() io.Reader { return &SlowReader{remaining: 100, delay: 20 * time.Millisecond} },
+			statusCode: http.StatusRequestTimeout,
+		},
+		"stalled body": {
+			body:       func() io.Reader { return &StalledReader{data: []byte("x"), Release: release} },
+			statusCode: http.StatusRequestTimeout,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			svr := server.New(context.Background(), &server.NoOpRecorder{}, server.WithMinReadRate(1024))
+			svr.Router().Handle("/upload", server.ErrorHandlerFunc(func(_ http.ResponseWriter, request *http.Request) error {
+				if _, err := io.ReadAll(request.Body); errors.Is(err, server.ErrReadTooSlow) {
+					return server.NewHTTPError(http.StatusRequestTimeout, "")
+				}
+
+				return nil
+			})).Methods(http.MethodPost)
+
+			testServer := httptest.NewServer(svr)
+
+			request, _ := http.NewRequestWithContext(
+				context.Background(),
+				http.MethodPost,
+				testServer.URL+"/upload",
+				test.body(),
+			)
+			request.Close = true
+
+			response, err := http.DefaultClient.Do(request)
+			if assert.NoError(t, err) {
+				assert.NoError(t, response.Body.Close())
+				assert.Equal(t, test.statusCode, response.StatusCode)
+			}
+
+			testServer.Close()
+		})
+	}
+}
+
+// ClockedReader sends a chunk of its data on every read, advancing the clock by a fixed step each time.
+type ClockedReader struct {
+	remaining int
+	chunk     int
+	step      time.Duration
+	clock     *FakeClock
+}
+
+func (r *ClockedReader) Read(p []byte) (int, error) {
+	if r.remaining == 0 {
+		return 0, io.EOF
+	}
+
+	r.clock.Advance(r.step)
+
+	n := min(r.chunk, r.remaining, len(p))
+	for i := range n {
+		p[i] = 'x'
+	}
+
+	r.remaining -= n
+
+	return n, nil
+}
+
+func TestMinReadRateWindow(t *testing.T) {
+	type testCase struct {
+		chunk      int
+		statusCode int
+	}
+
+	tests := map[string]testCase{
+		"steady body over several windows": {
+			chunk:      512,
+			statusCode: http.StatusOK,
+		},
+		"body below the rate": {
+			chunk:      100,
+			statusCode: http.StatusRequestTimeout,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			clock := NewFakeClock()
+
+			svr := server.New(
+				context.Background(),
+				&server.NoOpRecorder{},
+				server.WithClock(clock.Now),
+				server.WithMinReadRate(1024),
+			)
+			svr.Router().Handle("/upload", server.ErrorHandlerFunc(func(_ http.ResponseWriter, request *http.Request) error {
+				if _, err := io.ReadAll(request.Body); errors.Is(err, server.ErrReadTooSlow) {
+					return server.NewHTTPError(http.StatusRequestTimeout, "")
+				}
+
+				return nil
+			})).Methods(http.MethodPost)
+
+			// Each read takes a quarter of a second on the server clock, so the rate is checked once every four reads
+			body := &ClockedReader{remaining: 8192, chunk: test.chunk, step: 250 * time.Millisecond, clock: clock}
+
+			response := httptest.NewRecorder()
+			svr.ServeHTTP(response, httptest.NewRequest(http.MethodPost, "/upload", body))
+
+			assert.Equal(t, test.statusCode, response.Code)
+		})
+	}
+}
+
+func TestMaxResponseSize(t *testing.T) {
+	type testCase struct {
+		chunks   int
+		body     string
+		err      error
+		failures int
+	}
+
+	tests := map[string]testCase{
+		"under limit": {
+			chunks:   2,
+			body:     "0123456789" + "0123456789",
+			err:      nil,
+			failures: 0,
+		},
+		"over limit": {
+			chunks:   3,
+			body:     "0123456789" + "0123456789" + "01234",
+			err:      server.ErrResponseTooLarge,
+			failures: 1,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			recorder := &SpyRecorder{}
+
+			var writeErr error
+
+			svr := server.New(context.Background(), recorder, server.WithMaxResponseSize(25))
+			svr.Router().Handle("/test", http.HandlerFunc(func(writer http.ResponseWriter, _ *http.Request) {
+				for range test.chunks {
+					if _, writeErr = writer.Write([]byte("0123456789")); writeErr != nil {
+						return
+					}
+				}
+			})).Methods(http.MethodGet)
+
+			testServer := httptest.NewServer(svr)
+
+			request, _ := http.NewRequestWithContext(context.Background(), http.MethodGet, testServer.URL+"/test", nil)
+			request.Close = true
+
+			response, err := http.DefaultClient.Do(request)
+			assert.NoError(t, err)
+
+			body, err := io.ReadAll(response.Body)
+			assert.NoError(t, err)
+			assert.NoError(t, response.Body.Close())
+
+			testServer.Close()
+
+			assert.Equal(t, test.body, string(body))
+			assert.ErrorIs(t, writeErr, test.err)
+			assert.Len(t, recorder.FailureObservations(), test.failures)
+
+			sizes := recorder.SizeObservations()
+			if assert.Len(t, sizes, 1) {
+				assert.Equal(t, float64(len(test.body)), sizes[0].Value)
+			}
+		})
+	}
+}
+
+func TestMaxResponseSizeOperational(t *testing.T) {
+	recorder := &SpyRecorder{}
+
+	svr := server.New(
+		context.Background(),
+		recorder,
+		server.WithMaxResponseSize(16),
+		server.WithVersion("v1.2.3-rc.1+build.20261016"),
+		server.WithHealthDependency("database", &HealthCheck{Err: nil}),
+	)
+	svr.Router().Handle("/admin/export", http.HandlerFunc(func(writer http.ResponseWriter, _ *http.Request) {
+		_, _ = writer.Write([]byte(strings.Repeat("x", 40)))
+	})).Methods(http.MethodGet)
+
+	testServer := httptest.NewServer(svr)
+	defer testServer.Close()
+
+	_, body, err := fetch(t, testServer.URL+"/version")
+	assert.NoError(t, err)
+	assert.Contains(t, body, "v1.2.3-rc.1+build.20261016")
+
+	_, body, err = fetch(t, testServer.URL+"/health?verbose")
+	assert.NoError(t, err)
+	assert.True(t, json.Valid([]byte(body)), body)
+	assert.Contains(t, body, "database")
+
+	assert.Empty(t, recorder.FailureObservations())
+
+	// Application routes under /admin are not built-in endpoints, so they are still truncated
+	_, body, err = fetch(t, testServer.URL+"/admin/export")
+	assert.NoError(t, err)
+	assert.Equal(t, strings.Repeat("x", 16), body)
+	assert.Len(t, recorder.FailureObservations(), 1)
+}
+
+func TestResponseSizeWarning(t *testing.T) {
+	type testCase struct {
+		size    int
+		warning bool
+	}
+
+	tests := map[string]testCase{
+		"under threshold": {
+			size:    1024,
+			warning: false,
+		},
+		"at threshold": {
+			size:    2048,
+			warning: false,
+		},
+		"over threshold": {
+			size:    1 << 20,
+			warning: true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			var logs safeBuffer
+
+			body := strings.Repeat("a", test.size)
+
+			svr := server.New(context.Background(), &server.NoOpRecorder{}, server.WithResponseSizeWarning(2048))
+			svr.Router().Handle("/things/{id}", http.HandlerFunc(func(writer http.ResponseWriter, _ *http.Request) {
+				_, _ = writer.Write([]byte(body))
+			})).Methods(http.MethodGet)
+
+			testServer := httptest.NewServer(withLogger(svr, zerolog.New(&logs)))
+
+			code, received, err := fetch(t, testServer.URL+"/things/1")
+			assert.NoError(t, err)
+			assert.Equal(t, http.StatusOK, code)
+			assert.Len(t, received, test.size)
+
+			testServer.Close()
+
+			if !test.warning {
+				assert.NotContains(t, logs.String(), "large response")
+
+				return
+			}
+
+			assert.Contains(t, logs.String(), `{"level":"warn"`)
+			assert.Contains(
+				t,
+				logs.String(),
+				fmt.Sprintf(
+					`"method":"GET","url":"/things/1","route":"/things/{id}","response_bytes":%d,`+
+						`"warn_response_bytes":2048,"message":"large response"}`,
+					test.size,
+				),
+			)
+		})
+	}
+}
@@ -0,0 +1,35 @@
+package server
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// pathMiddleware is middleware that only applies to requests under a path prefix.
+type pathMiddleware struct {
+	prefix     string
+	middleware mux.MiddlewareFunc
+}
+
+// matches reports whether a path is the prefix or below it, so /admin does not match /administrator.
+func (m pathMiddleware) matches(path string) bool {
+	prefix := strings.TrimSuffix(m.prefix, "/")
+
+	return path == prefix || strings.HasPrefix(path, prefix+"/")
+}
+
+func (m pathMiddleware) handler(next http.Handler) http.Handler {
+	wrapped := m.middleware(next)
+
+	return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		if !m.matches(request.URL.Path) {
+			next.ServeHTTP(writer, request)
+
+			return
+		}
+
+		wrapped.ServeHTTP(writer, request)
+	})
+}
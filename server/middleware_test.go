@@ -0,0 +1,77 @@
+package server_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/b-sea/go-server/server"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPathMiddleware(t *testing.T) {
+	type testCase struct {
+		path       string
+		statusCode int
+	}
+
+	tests := map[string]testCase{
+		"under prefix": {
+			path:       "/admin/routes",
+			statusCode: http.StatusUnauthorized,
+		},
+		"prefix": {
+			path:       "/admin",
+			statusCode: http.StatusUnauthorized,
+		},
+		"similar path": {
+			path:       "/administrator",
+			statusCode: http.StatusOK,
+		},
+		"other path": {
+			path:       "/ping",
+			statusCode: http.StatusOK,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			recorder := &SpyRecorder{}
+
+			svr := server.New(
+				context.Background(),
+				recorder,
+				server.WithRoutesEndpoint(),
+				server.WithPathMiddleware("/admin/", func(http.Handler) http.Handler {
+					return http.HandlerFunc(func(writer http.ResponseWriter, _ *http.Request) {
+						writer.WriteHeader(http.StatusUnauthorized)
+					})
+				}),
+			)
+
+			for _, path := range []string{"/admin", "/administrator"} {
+				svr.Router().Handle(path, http.HandlerFunc(func(http.ResponseWriter, *http.Request) {})).
+					Methods(http.MethodGet)
+			}
+
+			testServer := httptest.NewServer(svr)
+
+			request, _ := http.NewRequestWithContext(context.Background(), http.MethodGet, testServer.URL+test.path, nil)
+			request.Close = true
+
+			response, err := http.DefaultClient.Do(request)
+			assert.NoError(t, err)
+			assert.NoError(t, response.Body.Close())
+
+			testServer.Close()
+
+			assert.Equal(t, test.statusCode, response.StatusCode)
+
+			observations := recorder.DurationObservations()
+			if assert.Len(t, observations, 1) {
+				assert.Equal(t, test.statusCode, observations[0].Code)
+			}
+		})
+	}
+}
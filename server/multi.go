@@ -0,0 +1,204 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rs/zerolog"
+)
+
+var (
+	_ ContextRecorder      = (*MultiRecorder)(nil)
+	_ QueueRecorder        = (*MultiRecorder)(nil)
+	_ FailureRecorder      = (*MultiRecorder)(nil)
+	_ ErrorRecorder        = (*MultiRecorder)(nil)
+	_ RegistrationRecorder = (*MultiRecorder)(nil)
+	_ TLSRecorder          = (*MultiRecorder)(nil)
+	_ HealthChecker        = (*MultiRecorder)(nil)
+	_ registererProvider   = (*MultiRecorder)(nil)
+)
+
+// MultiRecorder fans metrics out to several recorders, such as while migrating between metrics backends.
+type MultiRecorder struct {
+	recorders []Recorder
+	log       *zerolog.Logger
+}
+
+// NewMultiRecorder creates a new MultiRecorder. Recorder panics are logged with the default context logger, or with
+// the request logger once bound to a request context.
+func NewMultiRecorder(recorders ...Recorder) *MultiRecorder {
+	return &MultiRecorder{
+		recorders: recorders,
+		log:       zerolog.Ctx(context.Background()),
+	}
+}
+
+// Handler returns the handler of the first recorder that is not a NoOpRecorder and has one.
+func (r *MultiRecorder) Handler() http.Handler {
+	for _, recorder := range r.recorders {
+		if _, ok := recorder.(*NoOpRecorder); ok {
+			continue
+		}
+
+		if handler := recorder.Handler(); handler != nil {
+			return handler
+		}
+	}
+
+	return nil
+}
+
+// WithContext returns a MultiRecorder with every ContextRecorder bound to the request context.
+func (r *MultiRecorder) WithContext(ctx context.Context) Recorder {
+	bound := make([]Recorder, len(r.recorders))
+
+	for i, recorder := range r.recorders {
+		bound[i] = recorder
+
+		if contextual, ok := recorder.(ContextRecorder); ok {
+			bound[i] = contextual.WithContext(ctx)
+		}
+	}
+
+	return &MultiRecorder{
+		recorders: bound,
+		log:       zerolog.Ctx(ctx),
+	}
+}
+
+// Registerer returns the Prometheus registerer of the first recorder that has one, or nil if none do.
+func (r *MultiRecorder) Registerer() prometheus.Registerer {
+	for _, recorder := range r.recorders {
+		if provider, ok := recorder.(registererProvider); ok {
+			if registerer := provider.Registerer(); registerer != nil {
+				return registerer
+			}
+		}
+	}
+
+	return nil
+}
+
+// HealthCheck checks every recorder that implements HealthChecker, returning the first error.
+func (r *MultiRecorder) HealthCheck(ctx context.Context) error {
+	for _, recorder := range r.recorders {
+		checker, ok := recorder.(HealthChecker)
+		if !ok {
+			continue
+		}
+
+		if err := checker.HealthCheck(ctx); err != nil {
+			return err //nolint: wrapcheck
+		}
+	}
+
+	return nil
+}
+
+// ObserveHTTPRequestDuration records the duration of an HTTP request with every recorder.
+func (r *MultiRecorder) ObserveHTTPRequestDuration(method string, path string, code int, duration time.Duration) {
+	r.each(func(recorder Recorder) {
+		recorder.ObserveHTTPRequestDuration(method, path, code, duration)
+	})
+}
+
+// ObserveHTTPResponseSize records how large an HTTP response is with every recorder.
+func (r *MultiRecorder) ObserveHTTPResponseSize(method string, path string, code int, bytes int64) {
+	r.each(func(recorder Recorder) {
+		recorder.ObserveHTTPResponseSize(method, path, code, bytes)
+	})
+}
+
+// ObserveHTTPQueueTime records how long an HTTP request waited before being handled with every QueueRecorder.
+func (r *MultiRecorder) ObserveHTTPQueueTime(method string, path string, duration time.Duration) {
+	r.each(func(recorder Recorder) {
+		if queue, ok := recorder.(QueueRecorder); ok {
+			queue.ObserveHTTPQueueTime(method, path, duration)
+		}
+	})
+}
+
+// ObserveHTTPFailure records an HTTP request marked as failed with every FailureRecorder.
+func (r *MultiRecorder) ObserveHTTPFailure(method string, path string, code int) {
+	r.each(func(recorder Recorder) {
+		if failures, ok := recorder.(FailureRecorder); ok {
+			failures.ObserveHTTPFailure(method, path, code)
+		}
+	})
+}
+
+// ObserveHTTPError records an HTTP request that failed with a server error with every ErrorRecorder.
+func (r *MultiRecorder) ObserveHTTPError(method string, path string, code int) {
+	r.each(func(recorder Recorder) {
+		if errs, ok := recorder.(ErrorRecorder); ok {
+			errs.ObserveHTTPError(method, path, code)
+		}
+	})
+}
+
+// ObserveRoutesRegistered records how many routes the server has with every RegistrationRecorder.
+func (r *MultiRecorder) ObserveRoutesRegistered(count int) {
+	r.each(func(recorder Recorder) {
+		if registrations, ok := recorder.(RegistrationRecorder); ok {
+			registrations.ObserveRoutesRegistered(count)
+		}
+	})
+}
+
+// ObserveHealthDependencies records how many health dependencies the server has with every RegistrationRecorder.
+func (r *MultiRecorder) ObserveHealthDependencies(count int) {
+	r.each(func(recorder Recorder) {
+		if registrations, ok := recorder.(RegistrationRecorder); ok {
+			registrations.ObserveHealthDependencies(count)
+		}
+	})
+}
+
+// ObserveTLSHandshake records the TLS version and cipher suite negotiated for a connection with every TLSRecorder.
+func (r *MultiRecorder) ObserveTLSHandshake(version string, cipherSuite string) {
+	r.each(func(recorder Recorder) {
+		if tlsRecorder, ok := recorder.(TLSRecorder); ok {
+			tlsRecorder.ObserveTLSHandshake(version, cipherSuite)
+		}
+	})
+}
+
+// ObserveTLSHandshakeError records a connection that failed its TLS handshake with every TLSRecorder.
+func (r *MultiRecorder) ObserveTLSHandshakeError() {
+	r.each(func(recorder Recorder) {
+		if tlsRecorder, ok := recorder.(TLSRecorder); ok {
+			tlsRecorder.ObserveTLSHandshakeError()
+		}
+	})
+}
+
+// each calls fn for every recorder, so a panic in one recorder is logged and does not stop the others from recording.
+func (r *MultiRecorder) each(fn func(recorder Recorder)) {
+	for _, recorder := range r.recorders {
+		func() {
+			defer func() {
+				panicked := recover()
+				if panicked == nil {
+					return
+				}
+
+				err, ok := panicked.(error)
+				if !ok {
+					err = fmt.Errorf("%v", panicked) //nolint: err113
+				}
+
+				r.log.Error().
+					Stack().
+					Err(errors.Wrap(err, "recorder panic")).
+					Str("recorder", fmt.Sprintf("%T", recorder)).
+					Send()
+			}()
+
+			fn(recorder)
+		}()
+	}
+}
@@ -0,0 +1,205 @@
+package server_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/b-sea/go-server/server"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+)
+
+type PanicRecorder struct {
+	server.NoOpRecorder
+}
+
+func (r *PanicRecorder) ObserveHTTPRequestDuration(string, string, int, time.Duration) {
+	panic("recorder broke")
+}
+
+func (r *PanicRecorder) ObserveHTTPResponseSize(string, string, int, int64) {
+	panic("recorder broke")
+}
+
+func (r *PanicRecorder) ObserveHTTPQueueTime(string, string, time.Duration) {
+	panic("recorder broke")
+}
+
+func (r *PanicRecorder) ObserveHTTPFailure(string, string, int) {
+	panic("recorder broke")
+}
+
+func (r *PanicRecorder) ObserveHTTPError(string, string, int) {
+	panic("recorder broke")
+}
+
+func (r *PanicRecorder) ObserveRoutesRegistered(int) {
+	panic("recorder broke")
+}
+
+func (r *PanicRecorder) ObserveHealthDependencies(int) {
+	panic("recorder broke")
+}
+
+func (r *PanicRecorder) ObserveTLSHandshake(string, string) {
+	panic("recorder broke")
+}
+
+func (r *PanicRecorder) ObserveTLSHandshakeError() {
+	panic("recorder broke")
+}
+
+type HandlerRecorder struct {
+	server.NoOpRecorder
+
+	Body string
+}
+
+func (r *HandlerRecorder) Handler() http.Handler {
+	return http.HandlerFunc(func(writer http.ResponseWriter, _ *http.Request) {
+		_, _ = writer.Write([]byte(r.Body))
+	})
+}
+
+func TestMultiRecorder(t *testing.T) {
+	first := &SpyRecorder{}
+	second := &SpyRecorder{}
+
+	testServer := httptest.NewServer(
+		server.New(context.Background(), server.NewMultiRecorder(first, &PanicRecorder{}, second)),
+	)
+
+	request, _ := http.NewRequestWithContext(context.Background(), http.MethodGet, testServer.URL+"/ping", nil)
+	request.Close = true
+
+	response, err := http.DefaultClient.Do(request)
+	assert.NoError(t, err)
+	assert.NoError(t, response.Body.Close())
+
+	testServer.Close()
+
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+
+	for _, recorder := range []*SpyRecorder{first, second} {
+		assert.Equal(
+			t,
+			[]Observation{{Method: http.MethodGet, Path: "/ping", Code: http.StatusOK, Value: 4}},
+			recorder.SizeObservations(),
+		)
+		assert.Len(t, recorder.DurationObservations(), 1)
+	}
+}
+
+func TestMultiRecorderOptionalInterfaces(t *testing.T) {
+	first := &SpyRecorder{}
+	second := &SpyRecorder{}
+
+	// NoOpRecorder only implements Recorder, so it is skipped for every optional interface
+	recorder := server.NewMultiRecorder(first, &server.NoOpRecorder{}, &PanicRecorder{}, second)
+
+	assert.NotPanics(t, func() {
+		recorder.ObserveHTTPQueueTime(http.MethodGet, "/orders", 2*time.Second)
+		recorder.ObserveHTTPFailure(http.MethodGet, "/orders", http.StatusBadRequest)
+		recorder.ObserveHTTPError(http.MethodGet, "/orders", http.StatusBadGateway)
+		recorder.ObserveRoutesRegistered(3)
+		recorder.ObserveHealthDependencies(2)
+		recorder.ObserveTLSHandshake("TLS 1.3", "TLS_AES_128_GCM_SHA256")
+		recorder.ObserveTLSHandshakeError()
+	})
+
+	for _, spy := range []*SpyRecorder{first, second} {
+		assert.Equal(
+			t,
+			[]Observation{{Method: http.MethodGet, Path: "/orders", Code: 0, Value: 2}},
+			spy.QueueTimeObservations(),
+		)
+		assert.Equal(
+			t,
+			[]Observation{{Method: http.MethodGet, Path: "/orders", Code: http.StatusBadRequest, Value: 1}},
+			spy.FailureObservations(),
+		)
+		assert.Equal(
+			t,
+			[]Observation{{Method: http.MethodGet, Path: "/orders", Code: http.StatusBadGateway, Value: 1}},
+			spy.ErrorObservations(),
+		)
+		assert.Equal(t, 3, spy.Routes)
+		assert.Equal(t, 2, spy.Health)
+		assert.Equal(t, []string{"TLS 1.3 TLS_AES_128_GCM_SHA256"}, spy.TLS)
+		assert.Equal(t, 1, spy.TLSErrors)
+	}
+}
+
+func TestMultiRecorderPanicLog(t *testing.T) {
+	buffer := &safeBuffer{}
+
+	testServer := httptest.NewServer(
+		withLogger(
+			server.New(context.Background(), server.NewMultiRecorder(&SpyRecorder{}, &PanicRecorder{})),
+			zerolog.New(buffer),
+		),
+	)
+
+	request, _ := http.NewRequestWithContext(context.Background(), http.MethodGet, testServer.URL+"/ping", nil)
+	request.Close = true
+
+	response, err := http.DefaultClient.Do(request)
+	assert.NoError(t, err)
+	assert.NoError(t, response.Body.Close())
+
+	testServer.Close()
+
+	assert.Contains(t, buffer.String(), `"recorder":"*server_test.PanicRecorder"`)
+	assert.Contains(t, buffer.String(), `"error":"recorder panic: recorder broke"`)
+}
+
+func TestMultiRecorderHandler(t *testing.T) {
+	type testCase struct {
+		recorders []server.Recorder
+		result    string
+	}
+
+	tests := map[string]testCase{
+		"first real handler": {
+			recorders: []server.Recorder{
+				&server.NoOpRecorder{},
+				&NilHandlerRecorder{},
+				&HandlerRecorder{Body: "first"},
+				&HandlerRecorder{Body: "second"},
+			},
+			result: "first",
+		},
+		"no handlers": {
+			recorders: []server.Recorder{&server.NoOpRecorder{}, &NilHandlerRecorder{}},
+			result:    "",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			testServer := httptest.NewServer(
+				server.New(context.Background(), server.NewMultiRecorder(test.recorders...)),
+			)
+
+			request, _ := http.NewRequestWithContext(context.Background(), http.MethodGet, testServer.URL+"/metrics", nil)
+			request.Close = true
+
+			response, err := http.DefaultClient.Do(request)
+			assert.NoError(t, err)
+
+			body, err := io.ReadAll(response.Body)
+			assert.NoError(t, err)
+
+			assert.NoError(t, response.Body.Close())
+
+			assert.Equal(t, http.StatusOK, response.StatusCode)
+			assert.Equal(t, test.result, string(body))
+
+			testServer.Close()
+		})
+	}
+}
@@ -2,10 +2,18 @@ package server
 
 import (
 	"context"
+	"crypto/tls"
 	"fmt"
+	"io"
+	"maps"
+	"net"
 	"net/http"
+	"net/netip"
+	"slices"
+	"sync"
 	"time"
 
+	"github.com/gorilla/mux"
 	"github.com/rs/zerolog"
 )
 
@@ -34,6 +42,74 @@ func WithPort(port int) Option {
 	}
 }
 
+// WithAdminPort also serves the ping, version, metrics, health, readiness, routes, and config endpoints on a separate
+// port, which stays up while the main port drains on Stop.
+func WithAdminPort(port int) Option {
+	return func(_ context.Context, server *Server) {
+		server.adminAddr = fmt.Sprintf(":%d", port)
+	}
+}
+
+// WithBindRetry makes Start try binding the server port up to attempts times, waiting delay between tries, while
+// the address is still in use, such as by a listener that is shutting down during a fast restart. Other bind errors
+// fail right away.
+func WithBindRetry(attempts int, delay time.Duration) Option {
+	return func(_ context.Context, server *Server) {
+		if attempts < 1 {
+			return
+		}
+
+		server.bindAttempts = attempts
+		server.bindRetryDelay = delay
+	}
+}
+
+// WithTLS serves HTTPS using the certificate and key in the given PEM files, which are loaded when the Server starts.
+// Empty paths are ignored.
+func WithTLS(certFile string, keyFile string) Option {
+	return func(ctx context.Context, server *Server) {
+		if certFile == "" || keyFile == "" {
+			zerolog.Ctx(ctx).Warn().Msg("TLS requires a certificate and key file")
+
+			return
+		}
+
+		server.certFile = certFile
+		server.keyFile = keyFile
+	}
+}
+
+// WithTLSConfig serves HTTPS with the given TLS configuration, for certificates loaded from memory or mutual TLS. The
+// configuration must provide certificates unless WithTLS is also set. A nil configuration is ignored.
+func WithTLSConfig(config *tls.Config) Option {
+	return func(ctx context.Context, server *Server) {
+		if config == nil {
+			zerolog.Ctx(ctx).Warn().Msg("ignoring nil TLS config")
+
+			return
+		}
+
+		server.tlsConfig = config
+	}
+}
+
+// WithReusePort binds the server port with SO_REUSEPORT, so a new instance can bind the same port while the old one
+// is still running during a rolling restart. It is only supported on Linux, and Start fails with
+// ErrReusePortUnsupported elsewhere.
+func WithReusePort() Option {
+	return func(_ context.Context, server *Server) {
+		server.reusePort = true
+	}
+}
+
+// WithListener serves on an existing listener instead of listening on the server port, such as for systemd socket
+// activation.
+func WithListener(listener net.Listener) Option {
+	return func(_ context.Context, server *Server) {
+		server.listener = listener
+	}
+}
+
 // WithReadTimeout overrides the HTTP read and read header timeouts for the Server.
 func WithReadTimeout(duration time.Duration) Option {
 	return func(_ context.Context, server *Server) {
@@ -46,6 +122,19 @@ func WithReadTimeout(duration time.Duration) Option {
 	}
 }
 
+// WithShutdownTimeout sets how long Stop waits for in-flight requests to finish before giving up, such as to fit an
+// orchestrator's kill deadline or to let long-running streams finish. Timeouts that are not positive use the default
+// of one minute.
+func WithShutdownTimeout(duration time.Duration) Option {
+	return func(_ context.Context, server *Server) {
+		if duration <= 0 {
+			duration = defaultShutdownTimeout
+		}
+
+		server.shutdownTimeout = duration
+	}
+}
+
 // WithWriteTimeout overrides the HTTP write timeout for the Server.
 func WithWriteTimeout(duration time.Duration) Option {
 	return func(_ context.Context, server *Server) {
@@ -57,6 +146,208 @@ func WithWriteTimeout(duration time.Duration) Option {
 	}
 }
 
+// WithClock overrides the clock used to track server uptime.
+func WithClock(now func() time.Time) Option {
+	return func(_ context.Context, server *Server) {
+		if now == nil {
+			return
+		}
+
+		server.now = now
+	}
+}
+
+// WithUptimeFromCreation measures uptime from when the Server was created with New rather than from Start, so
+// servers used only through ServeHTTP, such as with httptest or when embedded in another server, report a
+// meaningful uptime. Uptime then keeps counting across Stop and Restart. The warmup period still begins at Start.
+func WithUptimeFromCreation() Option {
+	return func(_ context.Context, server *Server) {
+		server.uptimeFromCreation = true
+	}
+}
+
+// WithDrainingResponse sets the body and Content-Type of the 503 Service Unavailable sent to requests rejected while
+// the Server is draining, such as a JSON error for APIs. The default is a plain text message. An empty content type
+// is sent as text/plain.
+func WithDrainingResponse(body string, contentType string) Option {
+	return func(_ context.Context, server *Server) {
+		if contentType == "" {
+			contentType = defaultDrainingContentType
+		}
+
+		server.drainBody = body
+		server.drainContentType = contentType
+	}
+}
+
+// WithWarmupPeriod makes the readiness endpoint report unavailable for a period of time after the Server starts.
+func WithWarmupPeriod(duration time.Duration) Option {
+	return func(_ context.Context, server *Server) {
+		server.warmup = duration
+	}
+}
+
+// WithRequestTimeout limits how long a handler can run before the request fails with a 503 Service Unavailable.
+func WithRequestTimeout(duration time.Duration) Option {
+	return func(_ context.Context, server *Server) {
+		server.requestTimeout = duration
+	}
+}
+
+// WithWriteDeadlineContext gives each request context a deadline at 90% of the write timeout, so context aware
+// handlers can stop early and return a partial result before the connection is closed. It has no effect without a
+// write timeout.
+func WithWriteDeadlineContext() Option {
+	return func(_ context.Context, server *Server) {
+		server.writeDeadlineContext = true
+	}
+}
+
+// WithRequestTimeouts overrides the request timeout for specific route path templates, such as /export/{id}.
+func WithRequestTimeouts(timeouts map[string]time.Duration) Option {
+	return func(_ context.Context, server *Server) {
+		maps.Copy(server.requestTimeouts, timeouts)
+	}
+}
+
+// WithJSONTimeout answers requests that exceed their WithRequestTimeout or WithRequestTimeouts limit with a JSON
+// error and the given status code, rather than an HTML 503 Service Unavailable. The body includes the request
+// correlation ID. A code of 0 uses 504 Gateway Timeout, and codes that are not errors are ignored.
+func WithJSONTimeout(code int) Option {
+	return func(ctx context.Context, server *Server) {
+		if code == 0 {
+			code = http.StatusGatewayTimeout
+		}
+
+		if code < http.StatusBadRequest || code > http.StatusNetworkAuthenticationRequired {
+			zerolog.Ctx(ctx).Warn().Int("code", code).Msg("ignoring invalid timeout status code")
+
+			return
+		}
+
+		server.jsonTimeoutCode = code
+	}
+}
+
+// WithMaxHeaderCount rejects requests with more than the given number of header fields with a 431 Request Header
+// Fields Too Large.
+func WithMaxHeaderCount(count int) Option {
+	return func(_ context.Context, server *Server) {
+		server.maxHeaderCount = count
+	}
+}
+
+// WithMinReadRate fails request body reads with ErrReadTooSlow once a client sends fewer than bytesPerSec bytes over
+// a one second window, freeing handlers from clients that trickle their request bodies or stop sending altogether.
+func WithMinReadRate(bytesPerSec int) Option {
+	return func(_ context.Context, server *Server) {
+		server.minReadRate = bytesPerSec
+	}
+}
+
+// WithMaxRequestBodySize limits request bodies to the given number of bytes. Reading past the limit fails with an
+// *http.MaxBytesError. With WithRequestDecompression, the limit also applies to the decompressed body.
+func WithMaxRequestBodySize(bytes int64) Option {
+	return func(_ context.Context, server *Server) {
+		server.maxRequestBodySize = bytes
+	}
+}
+
+// WithMaxResponseSize truncates responses at the given number of bytes, as a safety valve against runaway handlers.
+// Writes past the limit fail with ErrResponseTooLarge, and truncated responses are logged and recorded as failures.
+// The limit applies to the bytes sent on the wire, after compression. The ping, version, metrics, health, readiness,
+// routes, and config endpoints are never truncated.
+func WithMaxResponseSize(bytes int64) Option {
+	return func(_ context.Context, server *Server) {
+		server.maxResponseSize = bytes
+	}
+}
+
+// WithResponseSizeWarning logs a warning with the route of any response larger than the given number of bytes, so
+// accidentally huge responses can be found. Unlike WithMaxResponseSize, responses are sent in full.
+func WithResponseSizeWarning(bytes int64) Option {
+	return func(_ context.Context, server *Server) {
+		server.responseSizeWarning = bytes
+	}
+}
+
+// WithRequestDecompression transparently decompresses request bodies sent with a gzip or deflate Content-Encoding.
+// Bodies that cannot be decompressed receive a 400 Bad Request.
+func WithRequestDecompression() Option {
+	return func(_ context.Context, server *Server) {
+		server.requestDecompression = true
+	}
+}
+
+// WithRateLimit limits how many requests the server handles across all routes without their own limit. Requests
+// over the limit receive a 429 Too Many Requests with a Retry-After header.
+func WithRateLimit(config RateLimitConfig) Option {
+	return func(_ context.Context, server *Server) {
+		server.rateLimit = config
+	}
+}
+
+// WithMaxConcurrentRequests limits how many requests are handled at once. Requests over the limit wait for a
+// running request to finish, and the wait is recorded with ObserveHTTPQueueTime.
+func WithMaxConcurrentRequests(limit int) Option {
+	return func(_ context.Context, server *Server) {
+		server.maxConcurrentRequests = limit
+	}
+}
+
+// WithRouteRateLimit sets rate limits for individual routes, keyed by route path template. Each route has its own
+// limit, separate from the WithRateLimit limit.
+func WithRouteRateLimit(limits map[string]RateLimitConfig) Option {
+	return func(_ context.Context, server *Server) {
+		maps.Copy(server.routeRateLimits, limits)
+	}
+}
+
+// WithUnmatchedPathLabel sets the metrics path label for requests that do not match a route template, such as
+// 404s. The default is "<unmatched>". An empty label is ignored.
+func WithUnmatchedPathLabel(label string) Option {
+	return func(_ context.Context, server *Server) {
+		if label == "" {
+			return
+		}
+
+		server.unmatchedPathLabel = label
+	}
+}
+
+// WithMetricRouteHeader lets handlers override their metrics path label by setting an X-Metric-Route response
+// header to one of the allowed routes. The header is never sent to the client, and values outside the allowed
+// routes are ignored to keep label cardinality bounded.
+func WithMetricRouteHeader(allowed ...string) Option {
+	return func(_ context.Context, server *Server) {
+		for _, route := range allowed {
+			server.metricRoutes[route] = true
+		}
+	}
+}
+
+// WithTrustedProxies trusts the X-Forwarded-Proto header from the given proxies, as IP addresses or CIDR ranges, so
+// RequestScheme reports the scheme the client used. Invalid entries are ignored.
+func WithTrustedProxies(proxies ...string) Option {
+	return func(ctx context.Context, server *Server) {
+		for _, proxy := range proxies {
+			prefix, err := netip.ParsePrefix(proxy)
+			if err != nil {
+				addr, addrErr := netip.ParseAddr(proxy)
+				if addrErr != nil {
+					zerolog.Ctx(ctx).Warn().Str("proxy", proxy).Msg("ignoring invalid trusted proxy")
+
+					continue
+				}
+
+				prefix = netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen())
+			}
+
+			server.trustedProxies = append(server.trustedProxies, prefix.Masked())
+		}
+	}
+}
+
 // WithReadCorrelationHeader will allow the service to read a correlation ID from a request header.
 func WithReadCorrelationHeader() Option {
 	return func(_ context.Context, server *Server) {
@@ -71,16 +362,490 @@ func WithCustomCorrelationID(fn func() string) Option {
 	}
 }
 
+// WithSortableCorrelationID generates time-sortable UUIDv7 correlation IDs instead of random UUIDv4 IDs.
+func WithSortableCorrelationID() Option {
+	return func(_ context.Context, server *Server) {
+		server.newCorrelationID = newSortableCorrelationID
+	}
+}
+
+// WithoutCorrelationID disables correlation ID generation and the Correlation-Id response header. An incoming
+// header is still read into the request logger when WithReadCorrelationHeader is set.
+func WithoutCorrelationID() Option {
+	return func(_ context.Context, server *Server) {
+		server.newCorrelationID = nil
+	}
+}
+
+// WithCorrelationResponsePolicy controls when the correlation ID is written to the Correlation-Id response header.
+// CorrelationOnlyGenerated avoids reflecting client-supplied IDs read with WithReadCorrelationHeader. Unknown
+// policies are ignored.
+func WithCorrelationResponsePolicy(policy CorrelationResponsePolicy) Option {
+	return func(ctx context.Context, server *Server) {
+		if policy < CorrelationAlways || policy > CorrelationNever {
+			zerolog.Ctx(ctx).Warn().Int("policy", int(policy)).Msg("ignoring unknown correlation response policy")
+
+			return
+		}
+
+		server.correlationResponse = policy
+	}
+}
+
+// WithTraceparent propagates a W3C traceparent header. Requests continue a valid incoming trace or start a new one,
+// and the request's traceparent is set on the response, added to the request logger, and available from
+// TraceparentFromContext.
+func WithTraceparent() Option {
+	return func(_ context.Context, server *Server) {
+		server.traceparent = true
+	}
+}
+
+// WithJSONEncoder overrides the encoder used for JSON responses written by the Server.
+func WithJSONEncoder(encoder JSONEncoder) Option {
+	return func(_ context.Context, server *Server) {
+		if encoder == nil {
+			return
+		}
+
+		server.encodeJSON = encoder
+	}
+}
+
+// WithDefaultHeaders sets headers on every response. Handlers can still override them.
+func WithDefaultHeaders(headers map[string]string) Option {
+	return func(_ context.Context, server *Server) {
+		maps.Copy(server.defaultHeaders, headers)
+	}
+}
+
+// WithPanicCircuitBreaker stops serving a route once it panics threshold times within the window. The route then
+// responds with a 503 Service Unavailable for the length of the window before it is tried again.
+func WithPanicCircuitBreaker(threshold int, window time.Duration) Option {
+	return func(_ context.Context, server *Server) {
+		if threshold <= 0 || window <= 0 {
+			return
+		}
+
+		server.panicBreaker = newPanicBreaker(threshold, window)
+	}
+}
+
+// WithCommonLogFormat writes an access log line for every request in the Common Log Format, independent of the
+// structured request logs.
+func WithCommonLogFormat(writer io.Writer) Option {
+	return func(_ context.Context, server *Server) {
+		if writer == nil {
+			return
+		}
+
+		server.commonLog = &commonLog{mu: sync.Mutex{}, writer: writer}
+	}
+}
+
+// WithServerTimingHeader adds a Server-Timing header with the time taken until the response headers were written.
+func WithServerTimingHeader() Option {
+	return func(_ context.Context, server *Server) {
+		server.serverTiming = true
+	}
+}
+
+// WithCompression compresses responses with brotli or gzip, as negotiated by the request Accept-Encoding header.
+func WithCompression() Option {
+	return func(_ context.Context, server *Server) {
+		server.compression = true
+	}
+}
+
+// WithPathMiddleware applies middleware only to requests whose path is under the given prefix, such as auth for
+// /admin. It runs after the built-in middleware, inside the request log and metrics. An empty prefix or nil
+// middleware is ignored.
+func WithPathMiddleware(prefix string, middleware mux.MiddlewareFunc) Option {
+	return func(ctx context.Context, server *Server) {
+		if prefix == "" || middleware == nil {
+			zerolog.Ctx(ctx).Warn().Str("prefix", prefix).Msg("path middleware requires a prefix and middleware")
+
+			return
+		}
+
+		server.pathMiddleware = append(server.pathMiddleware, pathMiddleware{prefix: prefix, middleware: middleware})
+	}
+}
+
+// WithLogTimeFormat sets the time.Format layout of the log_time field on request logs. The default is time.RFC3339.
+// An empty format is ignored.
+func WithLogTimeFormat(format string) Option {
+	return func(_ context.Context, server *Server) {
+		if format == "" {
+			return
+		}
+
+		server.logTimeFormat = format
+	}
+}
+
+// WithGCPLogFields shapes request logs for Google Cloud Logging. Request details are logged as an httpRequest object,
+// every request scoped log gets a severity, and when WithTraceparent is enabled the logging.googleapis.com/trace field
+// is set to the traceparent trace in the given project, as projects/<projectID>/traces/<traceID>. Without a project
+// ID the trace field is left out.
+func WithGCPLogFields(projectID string) Option {
+	return func(ctx context.Context, server *Server) {
+		if projectID == "" {
+			zerolog.Ctx(ctx).Warn().Msg("GCP log fields without a project ID do not include the trace")
+		}
+
+		server.gcpLogFields = true
+		server.gcpProjectID = projectID
+	}
+}
+
+// WithStartRequestLog logs a debug "request started" message when each request arrives, so requests that hang can
+// be found before they complete.
+func WithStartRequestLog() Option {
+	return func(_ context.Context, server *Server) {
+		server.startRequestLog = true
+	}
+}
+
+// WithAutoFlushInterval flushes responses on the given interval while their handler runs, so streaming responses
+// reach the client even if the handler never flushes. Flushed responses are sent untransformed by
+// WithResponseTransformer. An interval of 0 or less is ignored.
+func WithAutoFlushInterval(interval time.Duration) Option {
+	return func(_ context.Context, server *Server) {
+		if interval <= 0 {
+			return
+		}
+
+		server.autoFlushInterval = interval
+	}
+}
+
+// WithResponseTransformer buffers responses and rewrites their bodies with the given transformer before they are
+// sent. Streamed responses and responses over 1 MiB are sent untransformed. A nil transformer is ignored.
+func WithResponseTransformer(transform ResponseTransformer) Option {
+	return func(_ context.Context, server *Server) {
+		if transform == nil {
+			return
+		}
+
+		server.responseTransformer = transform
+	}
+}
+
+// WithIdempotency replays the saved response for requests that repeat an idempotency key instead of running the
+// handler again. Keys are read from the given header, or Idempotency-Key when empty, and are scoped to the caller, the
+// request method and the path. Callers are told apart by client address unless WithIdempotencyScope is set. Server
+// errors are not saved. A nil store is ignored.
+func WithIdempotency(store IdempotencyStore, header string) Option {
+	return func(ctx context.Context, server *Server) {
+		if store == nil {
+			zerolog.Ctx(ctx).Warn().Msg("ignoring nil idempotency store")
+
+			return
+		}
+
+		if header == "" {
+			header = idempotencyHeader
+		}
+
+		server.idempotency = newIdempotency(store, header)
+	}
+}
+
+// WithIdempotencyScope sets how WithIdempotency tells callers apart, such as by authenticated user or tenant, in place
+// of the client address. Set it when clients share an address, for example behind a proxy. A nil scope is ignored.
+func WithIdempotencyScope(scope IdempotencyScope) Option {
+	return func(ctx context.Context, server *Server) {
+		if scope == nil {
+			zerolog.Ctx(ctx).Warn().Msg("ignoring nil idempotency scope")
+
+			return
+		}
+
+		server.idempotencyScope = scope
+	}
+}
+
 // WithHealthDependency adds a sub system to include during server healthchecks.
 func WithHealthDependency(name string, checker HealthChecker) Option {
 	return func(ctx context.Context, server *Server) {
 		zerolog.Ctx(ctx).Debug().Str("name", name).Msg("register health dependency")
 
-		server.router.Handle(
-			healthEndpoint+"/"+name,
-			server.dependencyHealthCheckHandler(name),
-		).Methods(http.MethodGet)
-
 		server.healthDependencies[name] = checker
+		server.handleEndpoint(ctx, healthEndpoint+"/"+name, server.dependencyHealthCheckHandler(name))
+	}
+}
+
+// WithHealthDependencyCode adds a health dependency whose failure responds with the given status code instead of a
+// 500 Internal Server Error, such as a 502 Bad Gateway for an upstream service. When several dependencies fail, the
+// health check responds with the highest of their codes. Codes outside the 4xx and 5xx ranges are ignored, and the
+// dependency is added with the default code.
+func WithHealthDependencyCode(name string, checker HealthChecker, code int) Option {
+	return func(ctx context.Context, server *Server) {
+		WithHealthDependency(name, checker)(ctx, server)
+
+		if code < http.StatusBadRequest || code > http.StatusNetworkAuthenticationRequired {
+			zerolog.Ctx(ctx).Warn().Str("name", name).Int("code", code).Msg("ignoring invalid unhealthy status code")
+
+			return
+		}
+
+		server.unhealthyCodes[name] = code
+	}
+}
+
+// WithHealthDependencyTimeout adds a health dependency that is reported unhealthy once its check runs longer than the
+// timeout, overriding WithHealthCheckTimeout. Timeouts that are not positive are ignored, and the dependency is added
+// with the default timeout.
+func WithHealthDependencyTimeout(name string, checker HealthChecker, timeout time.Duration) Option {
+	return func(ctx context.Context, server *Server) {
+		WithHealthDependency(name, checker)(ctx, server)
+
+		if timeout <= 0 {
+			zerolog.Ctx(ctx).Warn().Str("name", name).Dur("timeout", timeout).Msg("ignoring invalid health check timeout")
+
+			return
+		}
+
+		server.healthTimeouts[name] = timeout
+	}
+}
+
+// WithHealthCheckTimeout sets how long each health check may run before its dependency is reported unhealthy, so a
+// hung dependency cannot hold up the health endpoints. Checks have no timeout by default.
+func WithHealthCheckTimeout(timeout time.Duration) Option {
+	return func(_ context.Context, server *Server) {
+		server.healthTimeout = timeout
+	}
+}
+
+// WithReadinessDependency adds a sub system that is only checked by the readiness endpoint, so its failures take the
+// Server out of traffic without failing the liveness health check and restarting it.
+func WithReadinessDependency(name string, checker HealthChecker) Option {
+	return func(ctx context.Context, server *Server) {
+		zerolog.Ctx(ctx).Debug().Str("name", name).Msg("register readiness dependency")
+
+		server.readyDependencies[name] = checker
+	}
+}
+
+// WithRecorderHealthCheck adds a "metrics" health dependency that checks the metrics recorder itself, such as
+// whether a push gateway is reachable. Recorders that do not implement HealthChecker are skipped with a warning.
+func WithRecorderHealthCheck() Option {
+	return func(ctx context.Context, server *Server) {
+		checker, ok := server.recorder.(HealthChecker)
+		if !ok {
+			zerolog.Ctx(ctx).Warn().Msg("recorder does not implement HealthChecker")
+
+			return
+		}
+
+		WithHealthDependency(recorderDependency, checker)(ctx, server)
+	}
+}
+
+// WithCachedHealthDependency adds a health dependency whose result is reused for ttl before checking it again, for
+// dependencies that are expensive to check.
+func WithCachedHealthDependency(name string, checker HealthChecker, ttl time.Duration) Option {
+	return func(ctx context.Context, server *Server) {
+		cached := &cachedCheck{
+			mu:        sync.Mutex{},
+			checker:   checker,
+			ttl:       ttl,
+			now:       func() time.Time { return server.now() },
+			checkedAt: time.Time{},
+			err:       nil,
+			running:   nil,
+		}
+
+		WithHealthDependency(name, cached)(ctx, server)
+	}
+}
+
+// WithHealthDependencies adds several sub systems to include during server healthchecks.
+func WithHealthDependencies(dependencies map[string]HealthChecker) Option {
+	return func(ctx context.Context, server *Server) {
+		for _, name := range slices.Sorted(maps.Keys(dependencies)) {
+			WithHealthDependency(name, dependencies[name])(ctx, server)
+		}
+	}
+}
+
+// WithHealthOKBody sets the body of a healthy, non-verbose /health response, for probes that require an exact
+// match such as OK. The body is empty by default.
+func WithHealthOKBody(body string) Option {
+	return func(_ context.Context, server *Server) {
+		server.healthOKBody = body
+	}
+}
+
+// WithHealthTimings reports each dependency in the verbose /health output as an object with its status and how long
+// its health check took, instead of a plain status.
+func WithHealthTimings() Option {
+	return func(_ context.Context, server *Server) {
+		server.healthTimings = true
+	}
+}
+
+// WithDependencyDetails reports the verbose /health/{name} output as an object with the dependency status, when it
+// was checked, and how long the check took, instead of a plain status.
+func WithDependencyDetails() Option {
+	return func(_ context.Context, server *Server) {
+		server.dependencyDetails = true
+	}
+}
+
+// WithHealthGoroutineLimit bounds how many dependency health checks run at once across all in-flight /health and
+// /ready requests, protecting the server from probe storms. Checks over the limit wait for a slot. A limit below 1
+// is ignored.
+func WithHealthGoroutineLimit(limit int) Option {
+	return func(_ context.Context, server *Server) {
+		if limit < 1 {
+			return
+		}
+
+		server.healthSlots = make(chan struct{}, limit)
+	}
+}
+
+// WithFailFastHealth reports /health as unhealthy as soon as any dependency fails, cancelling the context of the
+// checks still running. Dependencies that were not checked are shown as skipped in the verbose output.
+func WithFailFastHealth() Option {
+	return func(_ context.Context, server *Server) {
+		server.failFastHealth = true
+	}
+}
+
+// WithHealthContentType sets the Content-Type of non-verbose /health responses. The default is application/json.
+func WithHealthContentType(contentType string) Option {
+	return func(_ context.Context, server *Server) {
+		server.healthContentType = contentType
+	}
+}
+
+// WithGoroutineThresholdCheck adds a "goroutines" health dependency that is unhealthy while more than max goroutines
+// are running, as an early warning of goroutine leaks.
+func WithGoroutineThresholdCheck(maxGoroutines int) Option {
+	return WithHealthDependency(goroutinesDependency, &goroutineCheck{max: maxGoroutines})
+}
+
+// WithHeartbeatLiveness adds a "heartbeat" health dependency that is unhealthy while requests keep arriving but none
+// has completed within the window, so orchestrators can restart a server that is up but wedged, such as when every
+// handler is deadlocked. Long-lived requests, such as streams, are not a stall on their own: a request must arrive more
+// than a window after the first one left waiting. The operational endpoints are not counted. Windows that are not
+// positive are ignored.
+func WithHeartbeatLiveness(window time.Duration) Option {
+	return func(ctx context.Context, server *Server) {
+		if window <= 0 {
+			zerolog.Ctx(ctx).Warn().Dur("window", window).Msg("ignoring invalid heartbeat window")
+
+			return
+		}
+
+		server.heartbeat = &heartbeatCheck{
+			mu:           sync.Mutex{},
+			window:       window,
+			now:          func() time.Time { return server.now() },
+			inFlight:     0,
+			waiting:      0,
+			waitingSince: time.Time{},
+		}
+
+		WithHealthDependency(heartbeatDependency, server.heartbeat)(ctx, server)
+	}
+}
+
+// WithMemoryThresholdCheck adds a "memory" health dependency that is unhealthy while heap in-use bytes exceed
+// maxBytes, so orchestrators can restart a leaking service before it runs out of memory.
+func WithMemoryThresholdCheck(maxBytes uint64) Option {
+	return func(ctx context.Context, server *Server) {
+		check := &memoryCheck{
+			mu:        sync.Mutex{},
+			maxBytes:  maxBytes,
+			now:       func() time.Time { return server.now() },
+			readAt:    time.Time{},
+			heapInUse: 0,
+		}
+
+		WithHealthDependency(memoryDependency, check)(ctx, server)
+	}
+}
+
+// WithAutoHead serves HEAD requests for every GET route that does not already allow HEAD, running the GET route
+// without sending its body. The route's matchers and middleware apply as they would for a GET request.
+func WithAutoHead() Option {
+	return func(_ context.Context, server *Server) {
+		server.autoHead = true
+	}
+}
+
+// WithRoutesEndpoint exposes the registered server routes at GET /admin/routes.
+func WithRoutesEndpoint() Option {
+	return func(ctx context.Context, server *Server) {
+		server.routesEndpoint = true
+		server.handleEndpoint(ctx, routesEndpoint, server.routesHandler())
+	}
+}
+
+// WithConfigEndpoint exposes the effective server configuration at GET /admin/config. Requests must pass the given
+// authenticator or receive a 401 Unauthorized. A nil authenticator leaves the endpoint disabled.
+func WithConfigEndpoint(authenticate Authenticator) Option {
+	return func(ctx context.Context, server *Server) {
+		if authenticate == nil {
+			zerolog.Ctx(ctx).Warn().Msg("config endpoint requires an authenticator")
+
+			return
+		}
+
+		server.configAuth = authenticate
+		server.handleEndpoint(ctx, configEndpoint, server.configEndpointHandler())
+	}
+}
+
+// WithAuthenticator sets the authenticator that routes added with AddAuthenticatedHandler must pass, including routes
+// added before it was set. A nil authenticator is ignored.
+func WithAuthenticator(authenticate Authenticator) Option {
+	return func(ctx context.Context, server *Server) {
+		if authenticate == nil {
+			zerolog.Ctx(ctx).Warn().Msg("ignoring nil authenticator")
+
+			return
+		}
+
+		server.authenticator = authenticate
+	}
+}
+
+// WithFavicon serves the given icon at GET /favicon.ico to silence browser requests for it. An empty icon responds
+// with a 204 No Content instead.
+func WithFavicon(data []byte, contentType string) Option {
+	return func(ctx context.Context, server *Server) {
+		server.favicon = http.HandlerFunc(func(writer http.ResponseWriter, _ *http.Request) {
+			if len(data) == 0 {
+				writer.WriteHeader(http.StatusNoContent)
+
+				return
+			}
+
+			writer.Header().Set("Content-Type", contentType)
+			_, _ = writer.Write(data)
+		})
+		server.handleEndpoint(ctx, faviconEndpoint, server.faviconHandler())
+	}
+}
+
+// WithRouter builds the server on an existing router instead of a new one. Routes and middleware already on the
+// router are kept, and the built-in routes and middleware are added to it. The router runs middleware in the order it
+// was added, so middleware already on the router runs outside the server middleware: its panics are not recovered and
+// it is not logged or metered. Add middleware after New to run it inside. A nil router is ignored.
+func WithRouter(router *mux.Router) Option {
+	return func(ctx context.Context, server *Server) {
+		if router == nil {
+			zerolog.Ctx(ctx).Warn().Msg("ignoring nil router")
+
+			return
+		}
+
+		server.router = router
 	}
 }
@@ -10,6 +10,7 @@ import (
 	"time"
 
 	"github.com/b-sea/go-server/server"
+	"github.com/google/uuid"
 	"github.com/rs/zerolog"
 	"github.com/stretchr/testify/assert"
 )
@@ -52,6 +53,20 @@ func TestSetWriteTimeout(t *testing.T) {
 	assert.Equal(t, 5*time.Second, testServer.WriteTimeout())
 }
 
+func TestSetShutdownTimeout(t *testing.T) {
+	testServer := server.New(context.Background(), &server.NoOpRecorder{})
+	assert.Equal(t, time.Minute, testServer.ShutdownTimeout())
+
+	server.WithShutdownTimeout(10*time.Second)(context.Background(), testServer)
+	assert.Equal(t, 10*time.Second, testServer.ShutdownTimeout())
+
+	server.WithShutdownTimeout(0)(context.Background(), testServer)
+	assert.Equal(t, time.Minute, testServer.ShutdownTimeout())
+
+	server.WithShutdownTimeout(-time.Second)(context.Background(), testServer)
+	assert.Equal(t, time.Minute, testServer.ShutdownTimeout())
+}
+
 func TestWithCustomCorrelationID(t *testing.T) {
 	var buffer bytes.Buffer
 	testServer := httptest.NewServer(
@@ -81,32 +96,291 @@ func TestWithCustomCorrelationID(t *testing.T) {
 }
 
 func TestReadCorrelationHeader(t *testing.T) {
-	var buffer bytes.Buffer
+	type testCase struct {
+		options  []server.Option
+		header   string
+		response string
+	}
+
+	tests := map[string]testCase{
+		"header present and option on": {
+			options:  []server.Option{server.WithReadCorrelationHeader()},
+			header:   "i-come-from-a-header-123",
+			response: "i-come-from-a-header-123",
+		},
+		"header present and option off": {
+			options:  nil,
+			header:   "i-come-from-a-header-123",
+			response: "generated-id",
+		},
+		"header absent and option on": {
+			options:  []server.Option{server.WithReadCorrelationHeader()},
+			header:   "",
+			response: "generated-id",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			var buffer bytes.Buffer
+
+			options := append(
+				[]server.Option{server.WithCustomCorrelationID(func() string { return "generated-id" })},
+				test.options...,
+			)
+
+			testServer := httptest.NewServer(
+				server.New(zerolog.New(&buffer).WithContext(context.Background()), &server.NoOpRecorder{}, options...),
+			)
+
+			request, _ := http.NewRequestWithContext(
+				context.Background(),
+				http.MethodGet,
+				fmt.Sprintf("%s/ping", testServer.URL),
+				nil,
+			)
+
+			if test.header != "" {
+				request.Header.Set("Correlation-ID", test.header)
+			}
+
+			request.Close = true
+
+			response, err := http.DefaultClient.Do(request)
+			assert.NoError(t, err)
+			assert.NoError(t, response.Body.Close())
+
+			testServer.Close()
+
+			assert.Equal(t, http.StatusOK, response.StatusCode)
+			assert.Equal(t, []string{test.response}, response.Header.Values("Correlation-ID"))
+		})
+	}
+}
+
+func TestWithoutCorrelationID(t *testing.T) {
+	type testCase struct {
+		header string
+		logged string
+	}
+
+	tests := map[string]testCase{
+		"no incoming header": {
+			header: "",
+			logged: "",
+		},
+		"incoming header": {
+			header: "i-come-from-a-header-123",
+			logged: `"correlation_id":"i-come-from-a-header-123"`,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			var buffer bytes.Buffer
+
+			svr := server.New(
+				context.Background(),
+				&server.NoOpRecorder{},
+				server.WithoutCorrelationID(),
+				server.WithReadCorrelationHeader(),
+			)
+
+			testServer := httptest.NewServer(withLogger(svr, zerolog.New(&buffer)))
+
+			request, _ := http.NewRequestWithContext(
+				context.Background(),
+				http.MethodGet,
+				fmt.Sprintf("%s/ping", testServer.URL),
+				nil,
+			)
+
+			if test.header != "" {
+				request.Header.Set("Correlation-ID", test.header)
+			}
+
+			request.Close = true
 
+			response, err := http.DefaultClient.Do(request)
+			assert.NoError(t, err)
+			assert.NoError(t, response.Body.Close())
+
+			testServer.Close()
+
+			assert.Equal(t, http.StatusOK, response.StatusCode)
+			assert.Empty(t, response.Header.Values("Correlation-ID"))
+
+			if test.logged == "" {
+				assert.NotContains(t, buffer.String(), "correlation_id")
+			} else {
+				assert.Contains(t, buffer.String(), test.logged)
+			}
+		})
+	}
+}
+
+func TestWithSortableCorrelationID(t *testing.T) {
 	testServer := httptest.NewServer(
-		server.New(
-			zerolog.New(&buffer).WithContext(context.Background()),
-			&server.NoOpRecorder{},
-			server.WithReadCorrelationHeader(),
-		),
+		server.New(context.Background(), &server.NoOpRecorder{}, server.WithSortableCorrelationID()),
 	)
 
-	request, _ := http.NewRequestWithContext(
+	ids := make([]string, 0)
+
+	for range 20 {
+		request, _ := http.NewRequestWithContext(
+			context.Background(),
+			http.MethodGet,
+			fmt.Sprintf("%s/ping", testServer.URL),
+			nil,
+		)
+
+		request.Close = true
+
+		response, err := http.DefaultClient.Do(request)
+		assert.NoError(t, err)
+		assert.NoError(t, response.Body.Close())
+
+		id := response.Header.Get("Correlation-ID")
+		assert.Equal(t, uuid.Version(7), uuid.MustParse(id).Version())
+
+		ids = append(ids, id)
+	}
+
+	for i := 1; i < len(ids); i++ {
+		assert.Less(t, ids[i-1], ids[i])
+	}
+
+	testServer.Close()
+}
+
+func TestWithCorrelationResponsePolicy(t *testing.T) {
+	type testCase struct {
+		policy   server.CorrelationResponsePolicy
+		header   string
+		response string
+	}
+
+	tests := map[string]testCase{
+		"always without header": {
+			policy:   server.CorrelationAlways,
+			header:   "",
+			response: "generated-id",
+		},
+		"always with header": {
+			policy:   server.CorrelationAlways,
+			header:   "client-id",
+			response: "client-id",
+		},
+		"only generated without header": {
+			policy:   server.CorrelationOnlyGenerated,
+			header:   "",
+			response: "generated-id",
+		},
+		"only generated with header": {
+			policy:   server.CorrelationOnlyGenerated,
+			header:   "client-id",
+			response: "",
+		},
+		"never without header": {
+			policy:   server.CorrelationNever,
+			header:   "",
+			response: "",
+		},
+		"never with header": {
+			policy:   server.CorrelationNever,
+			header:   "client-id",
+			response: "",
+		},
+		"unknown policy": {
+			policy:   server.CorrelationResponsePolicy(42),
+			header:   "client-id",
+			response: "client-id",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			var buffer bytes.Buffer
+
+			svr := server.New(
+				context.Background(),
+				&server.NoOpRecorder{},
+				server.WithReadCorrelationHeader(),
+				server.WithCustomCorrelationID(func() string { return "generated-id" }),
+				server.WithCorrelationResponsePolicy(test.policy),
+			)
+
+			testServer := httptest.NewServer(withLogger(svr, zerolog.New(&buffer)))
+
+			request, _ := http.NewRequestWithContext(
+				context.Background(),
+				http.MethodGet,
+				fmt.Sprintf("%s/ping", testServer.URL),
+				nil,
+			)
+
+			if test.header != "" {
+				request.Header.Set("Correlation-ID", test.header)
+			}
+
+			request.Close = true
+
+			response, err := http.DefaultClient.Do(request)
+			assert.NoError(t, err)
+			assert.NoError(t, response.Body.Close())
+
+			testServer.Close()
+
+			assert.Equal(t, http.StatusOK, response.StatusCode)
+			assert.Equal(t, test.response, response.Header.Get("Correlation-ID"))
+
+			// The ID is always logged, whether or not it is written to the response
+			expected := test.header
+			if expected == "" {
+				expected = "generated-id"
+			}
+
+			assert.Contains(t, buffer.String(), fmt.Sprintf(`"correlation_id":"%s"`, expected))
+		})
+	}
+}
+
+func TestWithDefaultHeaders(t *testing.T) {
+	svr := server.New(
 		context.Background(),
-		http.MethodGet,
-		fmt.Sprintf("%s/ping", testServer.URL),
-		nil,
+		&server.NoOpRecorder{},
+		server.WithDefaultHeaders(map[string]string{
+			"X-Content-Type-Options": "nosniff",
+			"Cache-Control":          "no-store",
+		}),
+		server.WithDefaultHeaders(map[string]string{"Server": "test-server"}),
 	)
 
-	request.Header.Set("Correlation-ID", "i-come-from-a-header-123")
+	svr.Router().Handle(
+		"/test",
+		func() http.HandlerFunc {
+			return func(writer http.ResponseWriter, _ *http.Request) {
+				writer.Header().Set("Cache-Control", "max-age=60")
+				_, _ = writer.Write([]byte(`ok`))
+			}
+		}(),
+	).Methods(http.MethodGet)
 
-	request.Close = true
+	testServer := httptest.NewServer(svr)
 
-	response, err := http.DefaultClient.Do(request)
-	assert.NoError(t, err)
+	for path, cacheControl := range map[string]string{"/ping": "no-store", "/test": "max-age=60"} {
+		request, _ := http.NewRequestWithContext(context.Background(), http.MethodGet, testServer.URL+path, nil)
+		request.Close = true
 
-	assert.Equal(t, http.StatusOK, response.StatusCode)
-	assert.Equal(t, "i-come-from-a-header-123", response.Header.Get("Correlation-ID"))
+		response, err := http.DefaultClient.Do(request)
+		assert.NoError(t, err)
+		assert.NoError(t, response.Body.Close())
+
+		assert.Equal(t, http.StatusOK, response.StatusCode)
+		assert.Equal(t, "nosniff", response.Header.Get("X-Content-Type-Options"))
+		assert.Equal(t, "test-server", response.Header.Get("Server"))
+		assert.Equal(t, cacheControl, response.Header.Get("Cache-Control"))
+	}
 
 	testServer.Close()
 }
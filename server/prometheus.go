@@ -1,16 +1,29 @@
 package server
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 	"strconv"
+	"sync"
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/rs/zerolog"
 )
 
-const subsystem = "http"
+const (
+	subsystem       = "http"
+	serverSubsystem = "server"
+	tlsSubsystem    = "tls"
+
+	nativeBucketFactor = 1.1
+	nativeMaxBuckets   = 160
+
+	successOutcome = "success"
+	failureOutcome = "failure"
+)
 
 // PrometheusOption is a creation option for PrometheusRecorder.
 type PrometheusOption func(p *PrometheusRecorder)
@@ -22,6 +35,35 @@ func WithGroupedCodes() PrometheusOption {
 	}
 }
 
+// WithSizeByStatusClass records response sizes with their status class (2xx/4xx/5xx) as the code label, whatever
+// the WithGroupedCodes setting. Successful payloads and error bodies differ in size, so keeping the class apart
+// keeps the size histogram meaningful without a series per status code.
+func WithSizeByStatusClass() PrometheusOption {
+	return func(p *PrometheusRecorder) {
+		p.sizeByStatusClass = true
+	}
+}
+
+// WithHealthCheck sets how a PrometheusRecorder checks its own health, such as whether a push gateway is reachable.
+// Without it, the recorder is always healthy.
+func WithHealthCheck(check func(ctx context.Context) error) PrometheusOption {
+	return func(p *PrometheusRecorder) {
+		p.healthCheck = check
+	}
+}
+
+// WithSuccessPredicate sets which status codes count as a success in the http_requests_total metric. By default,
+// every code below 500 is a success. A nil predicate is ignored.
+func WithSuccessPredicate(predicate func(code int) bool) PrometheusOption {
+	return func(p *PrometheusRecorder) {
+		if predicate == nil {
+			return
+		}
+
+		p.isSuccess = predicate
+	}
+}
+
 // WithRegisterer sets a custom PrometheusRecorder registerer.
 func WithRegisterer(registerer prometheus.Registerer) PrometheusOption {
 	return func(p *PrometheusRecorder) {
@@ -29,68 +71,344 @@ func WithRegisterer(registerer prometheus.Registerer) PrometheusOption {
 	}
 }
 
-var _ Recorder = (*PrometheusRecorder)(nil)
+// WithNativeHistograms records histograms as Prometheus native histograms in addition to the classic buckets, which
+// are kept for Prometheus servers that do not support native histograms.
+func WithNativeHistograms() PrometheusOption {
+	return func(p *PrometheusRecorder) {
+		p.nativeHistograms = true
+	}
+}
+
+// WithMetricLabelsFromContext adds labels to the HTTP metrics with values extracted from the request context, as
+// recorded with RecordMetricLabels by middleware or handlers that set the values. The extractor must return exactly
+// one value per label. Otherwise the labels are recorded as empty, and the first mismatch is logged.
+func WithMetricLabelsFromContext(labels []string, extractor func(ctx context.Context) []string) PrometheusOption {
+	return func(p *PrometheusRecorder) {
+		p.contextLabels = labels
+		p.extractLabels = extractor
+	}
+}
+
+// registererProvider is a Recorder backed by a Prometheus registerer.
+type registererProvider interface {
+	Registerer() prometheus.Registerer
+}
+
+// Registerer returns the Prometheus registerer behind the server recorder, so application metrics can be registered
+// alongside the server metrics and served from the same /metrics endpoint. It returns nil for recorders that are not
+// backed by Prometheus.
+func (s *Server) Registerer() prometheus.Registerer {
+	provider, ok := s.recorder.(registererProvider)
+	if !ok {
+		return nil
+	}
+
+	return provider.Registerer()
+}
+
+var (
+	_ ContextRecorder      = (*PrometheusRecorder)(nil)
+	_ QueueRecorder        = (*PrometheusRecorder)(nil)
+	_ FailureRecorder      = (*PrometheusRecorder)(nil)
+	_ ErrorRecorder        = (*PrometheusRecorder)(nil)
+	_ RegistrationRecorder = (*PrometheusRecorder)(nil)
+	_ TLSRecorder          = (*PrometheusRecorder)(nil)
+	_ HealthChecker        = (*PrometheusRecorder)(nil)
+	_ registererProvider   = (*PrometheusRecorder)(nil)
+)
 
 // PrometheusRecorder records metrics with PrometheusRecorder.
 type PrometheusRecorder struct {
 	groupCodes          bool
+	sizeByStatusClass   bool
+	nativeHistograms    bool
+	contextLabels       []string
+	extractLabels       func(ctx context.Context) []string
+	contextValues       []string
+	failed              bool
+	labelMismatch       *sync.Once
 	registerer          prometheus.Registerer
+	healthCheck         func(ctx context.Context) error
+	isSuccess           func(code int) bool
+	httpRequests        *prometheus.CounterVec
 	httpRequestDuration *prometheus.HistogramVec
 	httpResponseSize    *prometheus.HistogramVec
+	httpRequestQueue    *prometheus.HistogramVec
+	httpRequestFailures *prometheus.CounterVec
+	httpErrors          *prometheus.CounterVec
+	routesRegistered    prometheus.Gauge
+	healthDependencies  prometheus.Gauge
+	tlsHandshakes       *prometheus.CounterVec
+	tlsHandshakeErrors  prometheus.Counter
 }
 
 // NewPrometheus creates a new PrometheusRecorder.
 func NewPrometheus(namespace string, options ...PrometheusOption) *PrometheusRecorder {
 	recorder := &PrometheusRecorder{
-		groupCodes: false,
-		registerer: prometheus.DefaultRegisterer,
-		httpRequestDuration: prometheus.NewHistogramVec(
-			prometheus.HistogramOpts{
-				Namespace: namespace,
-				Subsystem: subsystem,
-				Name:      "request_duration_seconds",
-				Help:      "HTTP Request Duration in Seconds",
-			},
-			[]string{"method", "path", "code"},
-		),
-		httpResponseSize: prometheus.NewHistogramVec(
-			prometheus.HistogramOpts{
-				Namespace: namespace,
-				Subsystem: subsystem,
-				Name:      "response_size_bytes",
-				Help:      "HTTP Response Size in Bytes",
-			},
-			[]string{"method", "path", "code"},
-		),
+		groupCodes:          false,
+		sizeByStatusClass:   false,
+		nativeHistograms:    false,
+		contextLabels:       nil,
+		extractLabels:       nil,
+		contextValues:       nil,
+		failed:              false,
+		labelMismatch:       &sync.Once{},
+		registerer:          prometheus.DefaultRegisterer,
+		healthCheck:         nil,
+		isSuccess:           func(code int) bool { return code < http.StatusInternalServerError },
+		httpRequests:        nil,
+		httpRequestDuration: nil,
+		httpResponseSize:    nil,
+		httpRequestQueue:    nil,
+		httpRequestFailures: nil,
+		httpErrors:          nil,
+		routesRegistered:    nil,
+		healthDependencies:  nil,
+		tlsHandshakes:       nil,
+		tlsHandshakeErrors:  nil,
 	}
 
 	for _, option := range options {
 		option(recorder)
 	}
 
+	routeLabels := append([]string{"method", "path"}, recorder.contextLabels...)
+	labels := append([]string{"method", "path", "code"}, recorder.contextLabels...)
+	outcomeLabels := append([]string{"method", "path", "outcome"}, recorder.contextLabels...)
+
+	// Split by outcome alone, so error budget burn is a simple ratio without summing over every status code
+	recorder.httpRequests = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "requests_total",
+			Help:      "HTTP Requests by Success or Failure Outcome",
+		},
+		outcomeLabels,
+	)
+
+	recorder.httpRequestDuration = prometheus.NewHistogramVec(
+		recorder.histogramOpts(namespace, "request_duration_seconds", "HTTP Request Duration in Seconds"),
+		labels,
+	)
+	recorder.httpResponseSize = prometheus.NewHistogramVec(
+		recorder.histogramOpts(namespace, "response_size_bytes", "HTTP Response Size in Bytes"),
+		labels,
+	)
+
+	recorder.httpRequestQueue = prometheus.NewHistogramVec(
+		recorder.histogramOpts(namespace, "request_queue_seconds", "HTTP Request Queue Time in Seconds"),
+		routeLabels,
+	)
+
+	recorder.httpRequestFailures = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "request_failures_total",
+			Help:      "HTTP Requests Marked as Failed",
+		},
+		labels,
+	)
+
+	recorder.httpErrors = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "errors_total",
+			Help:      "HTTP Requests that Failed with a Server Error",
+		},
+		labels,
+	)
+
+	recorder.routesRegistered = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: serverSubsystem,
+		Name:      "routes_registered",
+		Help:      "Number of Routes Registered with the Server",
+	})
+	recorder.healthDependencies = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: serverSubsystem,
+		Name:      "health_dependencies",
+		Help:      "Number of Health Dependencies Registered with the Server",
+	})
+
+	recorder.tlsHandshakes = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: tlsSubsystem,
+			Name:      "handshakes_total",
+			Help:      "TLS Handshakes by Negotiated Version and Cipher Suite",
+		},
+		[]string{"version", "cipher_suite"},
+	)
+	recorder.tlsHandshakeErrors = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: tlsSubsystem,
+		Name:      "handshake_errors_total",
+		Help:      "Connections that Failed their TLS Handshake",
+	})
+
+	_ = recorder.registerer.Register(recorder.httpRequests)
 	_ = recorder.registerer.Register(recorder.httpRequestDuration)
 	_ = recorder.registerer.Register(recorder.httpResponseSize)
+	_ = recorder.registerer.Register(recorder.httpRequestQueue)
+	_ = recorder.registerer.Register(recorder.httpRequestFailures)
+	_ = recorder.registerer.Register(recorder.httpErrors)
+	_ = recorder.registerer.Register(recorder.routesRegistered)
+	_ = recorder.registerer.Register(recorder.healthDependencies)
+	_ = recorder.registerer.Register(recorder.tlsHandshakes)
+	_ = recorder.registerer.Register(recorder.tlsHandshakeErrors)
 
 	return recorder
 }
 
-// Handler returns an http handler for a PrometheusRecorder.
+func (p *PrometheusRecorder) histogramOpts(namespace string, name string, help string) prometheus.HistogramOpts {
+	opts := prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      name,
+		Help:      help,
+	}
+
+	if p.nativeHistograms {
+		opts.Buckets = prometheus.DefBuckets
+		opts.NativeHistogramBucketFactor = nativeBucketFactor
+		opts.NativeHistogramMaxBucketNumber = nativeMaxBuckets
+		opts.NativeHistogramMinResetDuration = time.Hour
+	}
+
+	return opts
+}
+
+// Handler returns an http handler for a PrometheusRecorder. A custom registerer that can also gather, such as a
+// prometheus.Registry, is served instead of the default registry, so metrics registered through Registerer show up
+// at /metrics.
 func (p *PrometheusRecorder) Handler() http.Handler {
+	if gatherer, ok := p.registerer.(prometheus.Gatherer); ok && p.registerer != prometheus.DefaultRegisterer {
+		return promhttp.HandlerFor(gatherer, promhttp.HandlerOpts{}) //nolint: exhaustruct
+	}
+
 	return promhttp.Handler()
 }
 
-// ObserveHTTPRequestDuration updates the HTTP request duration metric.
+// Registerer returns the registerer the PrometheusRecorder registers its metrics with, so application metrics can
+// share the same registry and /metrics endpoint.
+func (p *PrometheusRecorder) Registerer() prometheus.Registerer {
+	return p.registerer
+}
+
+// HealthCheck reports the health of the PrometheusRecorder, as set by WithHealthCheck.
+func (p *PrometheusRecorder) HealthCheck(ctx context.Context) error {
+	if p.healthCheck == nil {
+		return nil
+	}
+
+	return p.healthCheck(ctx)
+}
+
+// WithContext returns a copy of the PrometheusRecorder that labels metrics with values from the request context and
+// counts requests marked with RecordFailure as failures.
+func (p *PrometheusRecorder) WithContext(ctx context.Context) Recorder {
+	failed := requestFailed(ctx)
+	if p.extractLabels == nil && !failed {
+		return p
+	}
+
+	bound := *p
+	bound.failed = failed
+
+	if p.extractLabels == nil {
+		return &bound
+	}
+
+	values := p.extractLabels(ctx)
+	if len(values) != len(p.contextLabels) {
+		p.labelMismatch.Do(func() {
+			zerolog.Ctx(ctx).Error().
+				Strs("labels", p.contextLabels).
+				Strs("values", values).
+				Msg("metric label extractor returned the wrong number of values")
+		})
+
+		values = make([]string, len(p.contextLabels))
+	}
+
+	bound.contextValues = values
+
+	return &bound
+}
+
+// ObserveHTTPRequestDuration updates the HTTP request duration and request outcome metrics.
 func (p *PrometheusRecorder) ObserveHTTPRequestDuration(method string, path string, code int, duration time.Duration) {
-	p.httpRequestDuration.WithLabelValues(method, path, p.formatStatusCode(code)).Observe(duration.Seconds())
+	p.httpRequestDuration.WithLabelValues(p.labelValues(method, path, code)...).Observe(duration.Seconds())
+
+	// Requests marked with RecordFailure fail whatever their status code
+	outcome := failureOutcome
+	if !p.failed && p.isSuccess(code) {
+		outcome = successOutcome
+	}
+
+	p.httpRequests.WithLabelValues(p.withContextValues(method, path, outcome)...).Inc()
 }
 
 // ObserveHTTPResponseSize updates the HTTP response size metric.
 func (p *PrometheusRecorder) ObserveHTTPResponseSize(method string, path string, code int, bytes int64) {
-	p.httpResponseSize.WithLabelValues(method, path, p.formatStatusCode(code)).Observe(float64(bytes))
+	p.httpResponseSize.WithLabelValues(
+		p.withContextValues(method, path, p.formatStatusCode(code, p.groupCodes || p.sizeByStatusClass))...,
+	).Observe(float64(bytes))
+}
+
+// ObserveHTTPQueueTime updates the HTTP request queue time metric.
+func (p *PrometheusRecorder) ObserveHTTPQueueTime(method string, path string, duration time.Duration) {
+	p.httpRequestQueue.WithLabelValues(p.withContextValues(method, path)...).Observe(duration.Seconds())
+}
+
+// ObserveHTTPFailure updates the HTTP request failure metric.
+func (p *PrometheusRecorder) ObserveHTTPFailure(method string, path string, code int) {
+	p.httpRequestFailures.WithLabelValues(p.labelValues(method, path, code)...).Inc()
+}
+
+// ObserveHTTPError updates the HTTP error metric.
+func (p *PrometheusRecorder) ObserveHTTPError(method string, path string, code int) {
+	p.httpErrors.WithLabelValues(p.labelValues(method, path, code)...).Inc()
+}
+
+// ObserveRoutesRegistered updates the registered routes metric.
+func (p *PrometheusRecorder) ObserveRoutesRegistered(count int) {
+	p.routesRegistered.Set(float64(count))
+}
+
+// ObserveHealthDependencies updates the health dependencies metric.
+func (p *PrometheusRecorder) ObserveHealthDependencies(count int) {
+	p.healthDependencies.Set(float64(count))
+}
+
+// ObserveTLSHandshake updates the TLS handshake metric.
+func (p *PrometheusRecorder) ObserveTLSHandshake(version string, cipherSuite string) {
+	p.tlsHandshakes.WithLabelValues(version, cipherSuite).Inc()
+}
+
+// ObserveTLSHandshakeError updates the TLS handshake error metric.
+func (p *PrometheusRecorder) ObserveTLSHandshakeError() {
+	p.tlsHandshakeErrors.Inc()
+}
+
+func (p *PrometheusRecorder) labelValues(method string, path string, code int) []string {
+	return p.withContextValues(method, path, p.formatStatusCode(code, p.groupCodes))
+}
+
+func (p *PrometheusRecorder) withContextValues(values ...string) []string {
+	if len(p.contextValues) != len(p.contextLabels) {
+		return append(values, make([]string, len(p.contextLabels))...)
+	}
+
+	return append(values, p.contextValues...)
 }
 
-func (p *PrometheusRecorder) formatStatusCode(code int) string {
-	if !p.groupCodes {
+func (p *PrometheusRecorder) formatStatusCode(code int, group bool) string {
+	if !group {
 		return strconv.Itoa(code)
 	}
 
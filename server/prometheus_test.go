@@ -0,0 +1,598 @@
+package server_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/b-sea/go-server/server"
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+)
+
+// scrape returns the metrics in the registry in the text format served at /metrics.
+func scrape(t *testing.T, registry *prometheus.Registry) string {
+	t.Helper()
+
+	recorder := httptest.NewRecorder()
+	request := httptest.NewRequestWithContext(context.Background(), http.MethodGet, "/metrics", nil)
+
+	promhttp.HandlerFor(registry, promhttp.HandlerOpts{}).ServeHTTP(recorder, request) //nolint: exhaustruct
+
+	return recorder.Body.String()
+}
+
+func TestPrometheusNativeHistograms(t *testing.T) {
+	type testCase struct {
+		options []server.PrometheusOption
+		native  bool
+	}
+
+	tests := map[string]testCase{
+		"classic": {
+			options: nil,
+			native:  false,
+		},
+		"native": {
+			options: []server.PrometheusOption{server.WithNativeHistograms()},
+			native:  true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			registry := prometheus.NewRegistry()
+			recorder := server.NewPrometheus("test", append(test.options, server.WithRegisterer(registry))...)
+
+			recorder.ObserveHTTPRequestDuration(http.MethodGet, "/test", http.StatusOK, 42*time.Millisecond)
+			recorder.ObserveHTTPResponseSize(http.MethodGet, "/test", http.StatusOK, 1024)
+
+			families, err := registry.Gather()
+			assert.NoError(t, err)
+
+			histograms := 0
+
+			for _, family := range families {
+				if family.GetType() != dto.MetricType_HISTOGRAM {
+					continue
+				}
+
+				histograms++
+
+				histogram := family.GetMetric()[0].GetHistogram()
+
+				assert.Equal(t, uint64(1), histogram.GetSampleCount(), family.GetName())
+				assert.NotEmpty(t, histogram.GetBucket(), family.GetName())
+				assert.Equal(t, test.native, histogram.Schema != nil, family.GetName())
+			}
+
+			assert.Equal(t, 2, histograms)
+		})
+	}
+}
+
+type tierKey struct{}
+
+func TestPrometheusLabelsFromContext(t *testing.T) {
+	type testCase struct {
+		extractor func(context.Context) []string
+		record    bool
+		tier      string
+		logged    bool
+	}
+
+	extractTier := func(ctx context.Context) []string {
+		tier, _ := ctx.Value(tierKey{}).(string)
+
+		return []string{tier}
+	}
+
+	tests := map[string]testCase{
+		"extracted": {
+			extractor: extractTier,
+			record:    true,
+			tier:      "gold",
+			logged:    false,
+		},
+		"not recorded": {
+			extractor: extractTier,
+			record:    false,
+			tier:      "",
+			logged:    false,
+		},
+		"wrong label count": {
+			extractor: func(context.Context) []string { return []string{"gold", "extra"} },
+			record:    true,
+			tier:      "",
+			logged:    true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			var logs safeBuffer
+
+			registry := prometheus.NewRegistry()
+			recorder := server.NewPrometheus(
+				"test",
+				server.WithRegisterer(registry),
+				server.WithMetricLabelsFromContext([]string{"tier"}, test.extractor),
+			)
+
+			svr := server.New(context.Background(), recorder)
+			svr.Router().Use(func(next http.Handler) http.Handler {
+				return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+					request = request.WithContext(context.WithValue(request.Context(), tierKey{}, "gold"))
+					if test.record {
+						server.RecordMetricLabels(request)
+					}
+
+					next.ServeHTTP(writer, request)
+				})
+			})
+
+			testServer := httptest.NewServer(withLogger(svr, zerolog.New(&logs)))
+
+			for range 2 {
+				request, _ := http.NewRequestWithContext(context.Background(), http.MethodGet, testServer.URL+"/ping", nil)
+				request.Close = true
+
+				response, err := http.DefaultClient.Do(request)
+				assert.NoError(t, err)
+				assert.NoError(t, response.Body.Close())
+			}
+
+			testServer.Close()
+
+			mismatches := strings.Count(logs.String(), "metric label extractor returned the wrong number of values")
+			if test.logged {
+				assert.Equal(t, 1, mismatches)
+			} else {
+				assert.Zero(t, mismatches)
+			}
+
+			families, err := registry.Gather()
+			assert.NoError(t, err)
+
+			histograms := 0
+
+			for _, family := range families {
+				if family.GetType() != dto.MetricType_HISTOGRAM {
+					continue
+				}
+
+				histograms++
+
+				labels := map[string]string{}
+				for _, pair := range family.GetMetric()[0].GetLabel() {
+					labels[pair.GetName()] = pair.GetValue()
+				}
+
+				assert.Equal(
+					t,
+					map[string]string{"method": "GET", "path": "/ping", "code": "200", "tier": test.tier},
+					labels,
+					family.GetName(),
+				)
+			}
+
+			assert.Equal(t, 2, histograms)
+		})
+	}
+}
+
+func TestPrometheusSizeByStatusClass(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	recorder := server.NewPrometheus("test", server.WithRegisterer(registry), server.WithSizeByStatusClass())
+
+	testServer := httptest.NewServer(server.New(context.Background(), recorder))
+
+	get := func(path string) string {
+		request, _ := http.NewRequestWithContext(context.Background(), http.MethodGet, testServer.URL+path, nil)
+		request.Close = true
+
+		response, err := http.DefaultClient.Do(request)
+		assert.NoError(t, err)
+
+		body, err := io.ReadAll(response.Body)
+		assert.NoError(t, err)
+
+		assert.NoError(t, response.Body.Close())
+
+		return string(body)
+	}
+
+	get("/ping")
+	get("/ping")
+	get("/missing")
+
+	metrics := scrape(t, registry)
+
+	assert.Contains(t, metrics, `test_http_response_size_bytes_count{code="2xx",method="GET",path="/ping"} 2`)
+	assert.Contains(t, metrics, `test_http_response_size_bytes_count{code="4xx",method="GET",path="<unmatched>"} 1`)
+	assert.Contains(t, metrics, `test_http_request_duration_seconds_count{code="200",method="GET",path="/ping"} 2`)
+	assert.Contains(t, metrics, `test_http_request_duration_seconds_count{code="404",method="GET",path="<unmatched>"} 1`)
+
+	testServer.Close()
+}
+
+func TestPrometheusServerGauges(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	recorder := server.NewPrometheus("test", server.WithRegisterer(registry))
+
+	svr := server.New(
+		context.Background(),
+		recorder,
+		server.WithHealthDependency("database", &HealthCheck{}),
+		server.WithHealthDependency("cache", &HealthCheck{}),
+	)
+	svr.Router().Handle("/things", http.NotFoundHandler()).Methods(http.MethodGet)
+
+	testServer := httptest.NewServer(svr)
+
+	_, _, err := fetch(t, testServer.URL+"/ping")
+	assert.NoError(t, err)
+
+	metrics := scrape(t, registry)
+
+	assert.Contains(t, metrics, "test_server_routes_registered 8")
+	assert.Contains(t, metrics, "test_server_health_dependencies 2")
+
+	testServer.Close()
+}
+
+func TestPrometheusRecorderHealthCheck(t *testing.T) {
+	type testCase struct {
+		recorder   func() server.Recorder
+		statusCode int
+	}
+
+	tests := map[string]testCase{
+		"healthy by default": {
+			recorder: func() server.Recorder {
+				return server.NewPrometheus("test", server.WithRegisterer(prometheus.NewRegistry()))
+			},
+			statusCode: http.StatusOK,
+		},
+		"unhealthy": {
+			recorder: func() server.Recorder {
+				return server.NewPrometheus(
+					"test",
+					server.WithRegisterer(prometheus.NewRegistry()),
+					server.WithHealthCheck(func(context.Context) error { return errors.New("push gateway down") }),
+				)
+			},
+			statusCode: http.StatusInternalServerError,
+		},
+		"multi unhealthy": {
+			recorder: func() server.Recorder {
+				return server.NewMultiRecorder(
+					&server.NoOpRecorder{},
+					server.NewPrometheus(
+						"test",
+						server.WithRegisterer(prometheus.NewRegistry()),
+						server.WithHealthCheck(func(context.Context) error { return errors.New("push gateway down") }),
+					),
+				)
+			},
+			statusCode: http.StatusInternalServerError,
+		},
+		"not a health checker": {
+			recorder:   func() server.Recorder { return &server.NoOpRecorder{} },
+			statusCode: http.StatusNotFound,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			testServer := httptest.NewServer(
+				server.New(context.Background(), test.recorder(), server.WithRecorderHealthCheck()),
+			)
+
+			request, _ := http.NewRequestWithContext(context.Background(), http.MethodGet, testServer.URL+"/health/metrics", nil)
+			request.Close = true
+
+			response, err := http.DefaultClient.Do(request)
+			assert.NoError(t, err)
+			assert.NoError(t, response.Body.Close())
+
+			assert.Equal(t, test.statusCode, response.StatusCode)
+
+			testServer.Close()
+		})
+	}
+}
+
+func TestPrometheusErrors(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	recorder := server.NewPrometheus("test", server.WithRegisterer(registry))
+
+	svr := server.New(context.Background(), recorder)
+	svr.Router().Handle("/unavailable", http.HandlerFunc(func(writer http.ResponseWriter, _ *http.Request) {
+		writer.WriteHeader(http.StatusServiceUnavailable)
+	})).Methods(http.MethodGet)
+	svr.Router().Handle("/panic", http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+		panic("something bad")
+	})).Methods(http.MethodGet)
+
+	testServer := httptest.NewServer(withLogger(svr, zerolog.Nop()))
+
+	get := func(path string) string {
+		request, _ := http.NewRequestWithContext(context.Background(), http.MethodGet, testServer.URL+path, nil)
+		request.Close = true
+
+		response, err := http.DefaultClient.Do(request)
+		assert.NoError(t, err)
+
+		body, err := io.ReadAll(response.Body)
+		assert.NoError(t, err)
+
+		assert.NoError(t, response.Body.Close())
+
+		return string(body)
+	}
+
+	get("/ping")
+	get("/missing")
+	get("/unavailable")
+	get("/unavailable")
+	get("/panic")
+
+	metrics := scrape(t, registry)
+
+	assert.Contains(t, metrics, `test_http_errors_total{code="503",method="GET",path="/unavailable"} 2`)
+	assert.Contains(t, metrics, `test_http_errors_total{code="500",method="GET",path="/panic"} 1`)
+	assert.NotContains(t, metrics, `test_http_errors_total{code="200"`)
+	assert.NotContains(t, metrics, `test_http_errors_total{code="404"`)
+
+	testServer.Close()
+}
+
+func TestPrometheusMetricsGzip(t *testing.T) {
+	type testCase struct {
+		options []server.Option
+	}
+
+	tests := map[string]testCase{
+		"default": {
+			options: nil,
+		},
+		"compression": {
+			options: []server.Option{server.WithCompression()},
+		},
+		"response transformer": {
+			options: []server.Option{
+				server.WithResponseTransformer(func(_ int, _ string, body []byte) []byte {
+					return append(body, []byte("# transformed\n")...)
+				}),
+			},
+		},
+		"request timeout": {
+			options: []server.Option{server.WithRequestTimeout(time.Second)},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			var logs safeBuffer
+
+			recorder := server.NewPrometheus("test", server.WithRegisterer(prometheus.NewRegistry()))
+			svr := server.New(context.Background(), recorder, test.options...)
+
+			testServer := httptest.NewServer(withLogger(svr, zerolog.New(&logs)))
+
+			request, _ := http.NewRequestWithContext(context.Background(), http.MethodGet, testServer.URL+"/metrics", nil)
+			request.Close = true
+			request.Header.Set("Accept-Encoding", "gzip")
+
+			response, err := http.DefaultClient.Do(request)
+			assert.NoError(t, err)
+
+			compressed, err := io.ReadAll(response.Body)
+			assert.NoError(t, err)
+			assert.NoError(t, response.Body.Close())
+
+			testServer.Close()
+
+			assert.Equal(t, http.StatusOK, response.StatusCode)
+			assert.Equal(t, "gzip", response.Header.Get("Content-Encoding"))
+
+			reader, err := gzip.NewReader(bytes.NewReader(compressed))
+			if !assert.NoError(t, err) {
+				return
+			}
+
+			metrics, err := io.ReadAll(reader)
+			assert.NoError(t, err)
+			assert.Contains(t, string(metrics), "test_server_routes_registered")
+			assert.NotContains(t, string(metrics), "# transformed")
+
+			var entry struct {
+				ResponseBytes int `json:"response_bytes"`
+			}
+
+			assert.NoError(t, json.Unmarshal([]byte(logs.String()), &entry))
+			assert.Equal(t, len(compressed), entry.ResponseBytes)
+		})
+	}
+}
+
+func TestServerRegisterer(t *testing.T) {
+	type testCase struct {
+		recorder func(registry *prometheus.Registry) server.Recorder
+		expected bool
+	}
+
+	tests := map[string]testCase{
+		"prometheus": {
+			recorder: func(registry *prometheus.Registry) server.Recorder {
+				return server.NewPrometheus("test", server.WithRegisterer(registry))
+			},
+			expected: true,
+		},
+		"multi": {
+			recorder: func(registry *prometheus.Registry) server.Recorder {
+				return server.NewMultiRecorder(
+					&server.NoOpRecorder{},
+					server.NewPrometheus("test", server.WithRegisterer(registry)),
+				)
+			},
+			expected: true,
+		},
+		"not prometheus": {
+			recorder: func(*prometheus.Registry) server.Recorder {
+				return &server.NoOpRecorder{}
+			},
+			expected: false,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			registry := prometheus.NewRegistry()
+			svr := server.New(context.Background(), test.recorder(registry))
+
+			registerer := svr.Registerer()
+			if !test.expected {
+				assert.Nil(t, registerer)
+
+				return
+			}
+
+			assert.Equal(t, registry, registerer)
+
+			orders := prometheus.NewCounter(prometheus.CounterOpts{
+				Name: "orders_placed_total",
+				Help: "Orders Placed",
+			})
+			assert.NoError(t, registerer.Register(orders))
+			orders.Add(3)
+
+			testServer := httptest.NewServer(svr)
+			defer testServer.Close()
+
+			code, body, err := fetch(t, testServer.URL+"/metrics")
+			assert.NoError(t, err)
+			assert.Equal(t, http.StatusOK, code)
+			assert.Contains(t, body, "orders_placed_total 3")
+		})
+	}
+}
+
+func TestPrometheusLiveServer(t *testing.T) {
+	port := findOpenPort(t)
+	registry := prometheus.NewRegistry()
+
+	svr := server.New(
+		context.Background(),
+		server.NewPrometheus("live", server.WithRegisterer(registry)),
+		server.WithPort(port),
+	)
+	svr.Router().Handle("/things/{id}", http.HandlerFunc(func(writer http.ResponseWriter, _ *http.Request) {
+		_, _ = writer.Write([]byte(`thing`))
+	})).Methods(http.MethodGet)
+
+	done := make(chan error)
+
+	go func() {
+		done <- svr.Start(context.Background())
+	}()
+
+	url := fmt.Sprintf("http://localhost:%d", port)
+	waitForServer(t, url)
+
+	code, body, err := fetch(t, url+"/things/1")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "thing", body)
+
+	assert.NoError(t, svr.Stop(context.Background()))
+	assert.NoError(t, <-done)
+
+	body = scrape(t, registry)
+
+	assert.Contains(t, body, `live_http_request_duration_seconds_count{code="200",method="GET",path="/things/{id}"} 1`)
+	assert.Contains(t, body, `live_http_response_size_bytes_sum{code="200",method="GET",path="/things/{id}"} 5`)
+}
+
+func TestPrometheusRequestOutcomes(t *testing.T) {
+	type testCase struct {
+		options  []server.PrometheusOption
+		expected []string
+	}
+
+	tests := map[string]testCase{
+		"default predicate": {
+			options: nil,
+			expected: []string{
+				`test_http_requests_total{method="GET",outcome="success",path="/things/{id}"} 2`,
+				`test_http_requests_total{method="GET",outcome="failure",path="/things/{id}"} 1`,
+				`test_http_requests_total{method="GET",outcome="failure",path="/failed"} 1`,
+			},
+		},
+		"custom predicate": {
+			options: []server.PrometheusOption{
+				server.WithSuccessPredicate(func(code int) bool { return code < http.StatusBadRequest }),
+			},
+			expected: []string{
+				`test_http_requests_total{method="GET",outcome="success",path="/things/{id}"} 1`,
+				`test_http_requests_total{method="GET",outcome="failure",path="/things/{id}"} 2`,
+				`test_http_requests_total{method="GET",outcome="failure",path="/failed"} 1`,
+			},
+		},
+		"nil predicate": {
+			options: []server.PrometheusOption{server.WithSuccessPredicate(nil)},
+			expected: []string{
+				`test_http_requests_total{method="GET",outcome="success",path="/things/{id}"} 2`,
+				`test_http_requests_total{method="GET",outcome="failure",path="/things/{id}"} 1`,
+				`test_http_requests_total{method="GET",outcome="failure",path="/failed"} 1`,
+			},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			registry := prometheus.NewRegistry()
+			options := append([]server.PrometheusOption{server.WithRegisterer(registry)}, test.options...)
+
+			svr := server.New(context.Background(), server.NewPrometheus("test", options...))
+			svr.Router().Handle("/things/{id}", http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+				code, _ := strconv.Atoi(mux.Vars(request)["id"])
+				writer.WriteHeader(code)
+			})).Methods(http.MethodGet)
+			svr.Router().Handle("/failed", http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+				server.RecordFailure(request)
+				writer.WriteHeader(http.StatusOK)
+			})).Methods(http.MethodGet)
+
+			testServer := httptest.NewServer(svr)
+			defer testServer.Close()
+
+			for _, code := range []int{http.StatusOK, http.StatusNotFound, http.StatusServiceUnavailable} {
+				_, _, err := fetch(t, fmt.Sprintf("%s/things/%d", testServer.URL, code))
+				assert.NoError(t, err)
+			}
+
+			_, _, err := fetch(t, testServer.URL+"/failed")
+			assert.NoError(t, err)
+
+			for _, expected := range test.expected {
+				assert.Contains(t, scrape(t, registry), expected)
+			}
+		})
+	}
+}
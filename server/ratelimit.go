@@ -0,0 +1,108 @@
+package server
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// globalRateLimitKey is the bucket shared by every route without its own limit.
+const globalRateLimitKey = ""
+
+// RateLimitConfig allows up to Requests requests per Window. Unused requests refill steadily over the window, so
+// short bursts up to Requests are allowed.
+type RateLimitConfig struct {
+	Requests int
+	Window   time.Duration
+}
+
+func (c RateLimitConfig) enabled() bool {
+	return c.Requests > 0 && c.Window > 0
+}
+
+type tokenBucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+// rateLimiter keeps a token bucket per route limit.
+type rateLimiter struct {
+	mu      sync.Mutex
+	buckets map[string]*tokenBucket
+}
+
+func newRateLimiter() *rateLimiter {
+	return &rateLimiter{
+		mu:      sync.Mutex{},
+		buckets: make(map[string]*tokenBucket),
+	}
+}
+
+// take uses a request from the key's bucket. It returns how long until a request is available, or zero if the
+// request is allowed.
+func (l *rateLimiter) take(key string, config RateLimitConfig, now time.Time) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	bucket, ok := l.buckets[key]
+	if !ok {
+		bucket = &tokenBucket{tokens: float64(config.Requests), updated: now}
+		l.buckets[key] = bucket
+	}
+
+	perToken := config.Window / time.Duration(config.Requests)
+
+	bucket.tokens = math.Min(
+		float64(config.Requests),
+		bucket.tokens+float64(now.Sub(bucket.updated))/float64(perToken),
+	)
+	bucket.updated = now
+
+	if bucket.tokens < 1 {
+		return time.Duration((1 - bucket.tokens) * float64(perToken))
+	}
+
+	bucket.tokens--
+
+	return 0
+}
+
+func (s *Server) rateLimitFor(request *http.Request) (string, RateLimitConfig) {
+	path, err := mux.CurrentRoute(request).GetPathTemplate()
+	if err == nil {
+		if config, ok := s.routeRateLimits[path]; ok {
+			return path, config
+		}
+	}
+
+	// Probes answered with a 429 under load would get healthy servers restarted
+	if isOperationalPath(request.URL.Path) {
+		return globalRateLimitKey, RateLimitConfig{Requests: 0, Window: 0}
+	}
+
+	return globalRateLimitKey, s.rateLimit
+}
+
+func (s *Server) rateLimitMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		key, config := s.rateLimitFor(request)
+		if !config.enabled() {
+			next.ServeHTTP(writer, request)
+
+			return
+		}
+
+		if wait := s.rateLimiter.take(key, config, s.now()); wait > 0 {
+			writer.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			http.Error(writer, http.StatusText(http.StatusTooManyRequests), http.StatusTooManyRequests)
+
+			return
+		}
+
+		next.ServeHTTP(writer, request)
+	})
+}
@@ -0,0 +1,108 @@
+package server_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/b-sea/go-server/server"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRouteRateLimit(t *testing.T) {
+	clock := NewFakeClock()
+
+	svr := server.New(
+		context.Background(),
+		&server.NoOpRecorder{},
+		server.WithClock(clock.Now),
+		server.WithRateLimit(server.RateLimitConfig{Requests: 3, Window: time.Second}),
+		server.WithRouteRateLimit(map[string]server.RateLimitConfig{
+			"/expensive/{id}": {Requests: 1, Window: 10 * time.Second},
+		}),
+	)
+	svr.Router().Handle("/expensive/{id}", http.NotFoundHandler()).Methods(http.MethodGet)
+	svr.Router().Handle("/things", http.HandlerFunc(func(http.ResponseWriter, *http.Request) {})).Methods(http.MethodGet)
+	svr.Router().Handle("/other", http.HandlerFunc(func(http.ResponseWriter, *http.Request) {})).Methods(http.MethodGet)
+	svr.Router().Handle("/admin/jobs", http.HandlerFunc(func(http.ResponseWriter, *http.Request) {})).Methods(http.MethodGet)
+
+	testServer := httptest.NewServer(svr)
+	defer testServer.Close()
+
+	get := func(path string) *http.Response {
+		request, _ := http.NewRequestWithContext(context.Background(), http.MethodGet, testServer.URL+path, nil)
+		request.Close = true
+
+		response, err := http.DefaultClient.Do(request)
+		assert.NoError(t, err)
+		assert.NoError(t, response.Body.Close())
+
+		return response
+	}
+
+	// The expensive route allows a single request every 10 seconds, across all ids
+	assert.Equal(t, http.StatusNotFound, get("/expensive/1").StatusCode)
+
+	limited := get("/expensive/2")
+	assert.Equal(t, http.StatusTooManyRequests, limited.StatusCode)
+	assert.Equal(t, "10", limited.Header.Get("Retry-After"))
+
+	// Other routes share the global limit of 3 per second
+	for range 3 {
+		assert.Equal(t, http.StatusOK, get("/things").StatusCode)
+	}
+
+	limited = get("/other")
+	assert.Equal(t, http.StatusTooManyRequests, limited.StatusCode)
+	assert.Equal(t, "1", limited.Header.Get("Retry-After"))
+
+	// Application routes under /admin are not operational, so they share the global limit
+	assert.Equal(t, http.StatusTooManyRequests, get("/admin/jobs").StatusCode)
+
+	// Probes are never limited, so a busy server is not restarted
+	for _, path := range []string{"/ping", "/health", "/ready", "/metrics"} {
+		assert.Equal(t, http.StatusOK, get(path).StatusCode, path)
+	}
+
+	clock.Advance(time.Second)
+
+	assert.Equal(t, http.StatusOK, get("/things").StatusCode)
+	assert.Equal(t, http.StatusTooManyRequests, get("/expensive/1").StatusCode)
+
+	clock.Advance(9 * time.Second)
+
+	assert.Equal(t, http.StatusNotFound, get("/expensive/1").StatusCode)
+}
+
+func TestRouteRateLimitWithoutGlobal(t *testing.T) {
+	svr := server.New(
+		context.Background(),
+		&server.NoOpRecorder{},
+		server.WithRouteRateLimit(map[string]server.RateLimitConfig{
+			"/version": {Requests: 1, Window: time.Minute},
+		}),
+	)
+
+	testServer := httptest.NewServer(svr)
+	defer testServer.Close()
+
+	codes := map[string][]int{}
+
+	for range 3 {
+		for _, path := range []string{"/ping", "/version"} {
+			request, _ := http.NewRequestWithContext(context.Background(), http.MethodGet, testServer.URL+path, nil)
+			request.Close = true
+
+			response, err := http.DefaultClient.Do(request)
+			assert.NoError(t, err)
+			assert.NoError(t, response.Body.Close())
+
+			codes[path] = append(codes[path], response.StatusCode)
+		}
+	}
+
+	assert.Equal(t, []int{http.StatusOK, http.StatusOK, http.StatusOK}, codes["/ping"])
+	assert.Equal(t, []int{http.StatusOK, http.StatusTooManyRequests, http.StatusTooManyRequests}, codes["/version"])
+}
@@ -0,0 +1,7 @@
+//go:build !mips && !mipsle && !mips64 && !mips64le
+
+package server
+
+// soReusePort is the SO_REUSEPORT socket option. The syscall package only defines it for some Linux architectures, so
+// it is set here for the rest.
+const soReusePort = 0xf
@@ -0,0 +1,6 @@
+//go:build mips || mipsle || mips64 || mips64le
+
+package server
+
+// soReusePort is the SO_REUSEPORT socket option, which has its own value on MIPS.
+const soReusePort = 0x200
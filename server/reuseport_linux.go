@@ -0,0 +1,19 @@
+package server
+
+import (
+	"syscall"
+)
+
+// reusePortControl sets SO_REUSEPORT on a socket before it binds, so several listeners can share a port.
+func reusePortControl(_ string, _ string, conn syscall.RawConn) error {
+	var sockErr error
+
+	err := conn.Control(func(fd uintptr) {
+		sockErr = syscall.SetsockoptInt(int(fd), syscall.SOL_SOCKET, soReusePort, 1)
+	})
+	if err != nil {
+		return err //nolint: wrapcheck
+	}
+
+	return sockErr //nolint: wrapcheck
+}
@@ -0,0 +1,51 @@
+package server_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/b-sea/go-server/server"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServerReusePort(t *testing.T) {
+	port := findOpenPort(t)
+	url := fmt.Sprintf("http://localhost:%d", port)
+
+	servers := map[string]*server.Server{}
+	done := make(chan error, 2)
+
+	for _, version := range []string{"first", "second"} {
+		svr := server.New(
+			context.Background(),
+			&server.NoOpRecorder{},
+			server.WithPort(port),
+			server.WithReusePort(),
+			server.WithVersion(version),
+		)
+		servers[version] = svr
+
+		go func() {
+			done <- svr.Start(context.Background())
+		}()
+	}
+
+	// Connections are spread across both listeners, so both versions answer while both servers run
+	seen := map[string]bool{}
+
+	assert.Eventually(t, func() bool {
+		_, version, err := fetch(t, url+"/version")
+		if err == nil {
+			seen[version] = true
+		}
+
+		return seen["first"] && seen["second"]
+	}, 5*time.Second, time.Millisecond)
+
+	for _, svr := range servers {
+		assert.NoError(t, svr.Stop(context.Background()))
+		assert.NoError(t, <-done)
+	}
+}
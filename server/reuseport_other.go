@@ -0,0 +1,12 @@
+//go:build !linux
+
+package server
+
+import (
+	"syscall"
+)
+
+// reusePortControl fails on platforms where SO_REUSEPORT is not supported.
+func reusePortControl(string, string, syscall.RawConn) error {
+	return ErrReusePortUnsupported
+}
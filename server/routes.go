@@ -0,0 +1,50 @@
+package server
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+// RouteInfo describes a route registered with the Server.
+type RouteInfo struct {
+	Path    string   `json:"path"`
+	Methods []string `json:"methods,omitempty"`
+	Name    string   `json:"name,omitempty"`
+}
+
+// Routes returns every route registered with the server router. Routes without a path template are omitted.
+func (s *Server) Routes() []RouteInfo {
+	routes := make([]RouteInfo, 0)
+
+	_ = s.router.Walk(func(route *mux.Route, _ *mux.Router, _ []*mux.Route) error {
+		if route.GetHandler() == nil {
+			return nil
+		}
+
+		path, err := route.GetPathTemplate()
+		if err != nil {
+			return nil //nolint: nilerr
+		}
+
+		methods, _ := route.GetMethods()
+
+		routes = append(routes, RouteInfo{
+			Path:    path,
+			Methods: methods,
+			Name:    route.GetName(),
+		})
+
+		return nil
+	})
+
+	return routes
+}
+
+func (s *Server) routesHandler() http.Handler {
+	return http.HandlerFunc(func(writer http.ResponseWriter, _ *http.Request) {
+		writer.Header().Add("Content-Type", "application/json")
+
+		_ = s.encodeJSON(writer, s.Routes())
+	})
+}
@@ -0,0 +1,89 @@
+package server_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/b-sea/go-server/server"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRoutes(t *testing.T) {
+	svr := server.New(
+		context.Background(),
+		&server.NoOpRecorder{},
+		server.WithHealthDependency("sub-system", &HealthCheck{}),
+	)
+
+	svr.Router().Handle("/things/{id}", http.NotFoundHandler()).Methods(http.MethodGet, http.MethodPut).Name("thing")
+
+	assert.ElementsMatch(
+		t,
+		[]server.RouteInfo{
+			{Path: "/ping", Methods: []string{http.MethodGet}},
+			{Path: "/version", Methods: []string{http.MethodGet}},
+			{Path: "/metrics", Methods: []string{http.MethodGet}},
+			{Path: "/health", Methods: []string{http.MethodGet}},
+			{Path: "/ready", Methods: []string{http.MethodGet}},
+			{Path: "/health/sub-system", Methods: []string{http.MethodGet}},
+			{Path: "/things/{id}", Methods: []string{http.MethodGet, http.MethodPut}, Name: "thing"},
+		},
+		svr.Routes(),
+	)
+}
+
+func TestRoutesEndpoint(t *testing.T) {
+	type testCase struct {
+		option     server.Option
+		result     string
+		statusCode int
+	}
+
+	tests := map[string]testCase{
+		"disabled": {
+			option:     nil,
+			result:     "404 page not found\n",
+			statusCode: http.StatusNotFound,
+		},
+		"enabled": {
+			option: server.WithRoutesEndpoint(),
+			result: "[{\"path\":\"/ping\",\"methods\":[\"GET\"]}," +
+				"{\"path\":\"/version\",\"methods\":[\"GET\"]}," +
+				"{\"path\":\"/metrics\",\"methods\":[\"GET\"]}," +
+				"{\"path\":\"/health\",\"methods\":[\"GET\"]}," +
+				"{\"path\":\"/ready\",\"methods\":[\"GET\"]}," +
+				"{\"path\":\"/admin/routes\",\"methods\":[\"GET\"]}]\n",
+			statusCode: http.StatusOK,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			options := []server.Option{}
+			if test.option != nil {
+				options = append(options, test.option)
+			}
+
+			testServer := httptest.NewServer(server.New(context.Background(), &server.NoOpRecorder{}, options...))
+
+			request, _ := http.NewRequestWithContext(context.Background(), http.MethodGet, testServer.URL+"/admin/routes", nil)
+			request.Close = true
+
+			response, err := http.DefaultClient.Do(request)
+			assert.NoError(t, err)
+
+			body, err := io.ReadAll(response.Body)
+			assert.NoError(t, err)
+
+			assert.NoError(t, response.Body.Close())
+
+			assert.Equal(t, test.statusCode, response.StatusCode)
+			assert.Equal(t, test.result, string(body))
+
+			testServer.Close()
+		})
+	}
+}
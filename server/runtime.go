@@ -0,0 +1,132 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime"
+	"sync"
+	"time"
+)
+
+const (
+	goroutinesDependency = "goroutines"
+	memoryDependency     = "memory"
+	heartbeatDependency  = "heartbeat"
+
+	memStatsCacheTTL = time.Second
+)
+
+var (
+	_ HealthChecker = (*goroutineCheck)(nil)
+	_ HealthChecker = (*memoryCheck)(nil)
+	_ HealthChecker = (*heartbeatCheck)(nil)
+)
+
+var (
+	// ErrTooManyGoroutines is reported by the goroutines health dependency when the goroutine count is over its limit.
+	ErrTooManyGoroutines = errors.New("too many goroutines")
+
+	// ErrMemoryPressure is reported by the memory health dependency when heap in-use bytes are over their limit.
+	ErrMemoryPressure = errors.New("memory pressure")
+
+	// ErrRequestsStalled is reported by the heartbeat health dependency when requests are in flight but none has
+	// completed within its window.
+	ErrRequestsStalled = errors.New("requests stalled")
+)
+
+type goroutineCheck struct {
+	max int
+}
+
+func (c *goroutineCheck) HealthCheck(context.Context) error {
+	if count := runtime.NumGoroutine(); count > c.max {
+		return fmt.Errorf("%w: %d > %d", ErrTooManyGoroutines, count, c.max)
+	}
+
+	return nil
+}
+
+// memoryCheck reports unhealthy when heap in-use bytes exceed maxBytes. Reading MemStats stops the world, so each
+// read is reused for memStatsCacheTTL.
+type memoryCheck struct {
+	mu        sync.Mutex
+	maxBytes  uint64
+	now       func() time.Time
+	readAt    time.Time
+	heapInUse uint64
+}
+
+func (c *memoryCheck) HealthCheck(context.Context) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if now := c.now(); c.readAt.IsZero() || now.Sub(c.readAt) >= memStatsCacheTTL {
+		var stats runtime.MemStats
+
+		runtime.ReadMemStats(&stats)
+
+		c.readAt = now
+		c.heapInUse = stats.HeapInuse
+	}
+
+	if c.heapInUse > c.maxBytes {
+		return fmt.Errorf("%w: %d > %d bytes", ErrMemoryPressure, c.heapInUse, c.maxBytes)
+	}
+
+	return nil
+}
+
+// heartbeatCheck reports unhealthy when requests keep arriving but none completes within the window, which catches a
+// server that still accepts connections while every handler is blocked. Requests that arrive while another is already
+// in flight wait on the heartbeat, and any completion resets it. It only stalls once a request arrives more than a
+// window after the first waiting one, so idle time and long-lived streams, such as server-sent events, do not count
+// however many are open.
+type heartbeatCheck struct {
+	mu           sync.Mutex
+	window       time.Duration
+	now          func() time.Time
+	inFlight     int
+	waiting      int
+	waitingSince time.Time
+	lastArrival  time.Time
+}
+
+// begin records that a request has arrived.
+func (c *heartbeatCheck) begin() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.inFlight > 0 {
+		if c.waiting == 0 {
+			c.waitingSince = c.now()
+		}
+
+		c.waiting++
+		c.lastArrival = c.now()
+	}
+
+	c.inFlight++
+}
+
+// end records that a request has completed.
+func (c *heartbeatCheck) end() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.inFlight--
+	c.waiting = 0
+}
+
+func (c *heartbeatCheck) HealthCheck(context.Context) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.waiting == 0 || c.lastArrival.Sub(c.waitingSince) <= c.window {
+		return nil
+	}
+
+	stalled := c.now().Sub(c.waitingSince)
+
+	return fmt.Errorf("%w: %d in flight, none completed in %s", ErrRequestsStalled, c.inFlight, stalled)
+}
@@ -0,0 +1,178 @@
+package server_test
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/b-sea/go-server/server"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGoroutineThresholdCheck(t *testing.T) {
+	svr := server.New(
+		context.Background(),
+		&server.NoOpRecorder{},
+		server.WithGoroutineThresholdCheck(runtime.NumGoroutine()+20),
+	)
+
+	testServer := httptest.NewServer(svr)
+	defer testServer.Close()
+
+	check := func() int {
+		request, _ := http.NewRequestWithContext(
+			context.Background(),
+			http.MethodGet,
+			testServer.URL+"/health/goroutines",
+			nil,
+		)
+		request.Close = true
+
+		response, err := http.DefaultClient.Do(request)
+		assert.NoError(t, err)
+		assert.NoError(t, response.Body.Close())
+
+		return response.StatusCode
+	}
+
+	assert.Equal(t, http.StatusOK, check())
+
+	release := make(chan struct{})
+
+	var group sync.WaitGroup
+
+	for range 100 {
+		group.Go(func() { <-release })
+	}
+
+	assert.Equal(t, http.StatusInternalServerError, check())
+
+	close(release)
+	group.Wait()
+
+	assert.Eventually(t, func() bool { return check() == http.StatusOK }, time.Second, 10*time.Millisecond)
+}
+
+func TestMemoryThresholdCheck(t *testing.T) {
+	type testCase struct {
+		maxBytes   uint64
+		statusCode int
+	}
+
+	tests := map[string]testCase{
+		"under threshold": {
+			maxBytes:   math.MaxUint64,
+			statusCode: http.StatusOK,
+		},
+		"over threshold": {
+			maxBytes:   1,
+			statusCode: http.StatusInternalServerError,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			testServer := httptest.NewServer(
+				server.New(context.Background(), &server.NoOpRecorder{}, server.WithMemoryThresholdCheck(test.maxBytes)),
+			)
+
+			request, _ := http.NewRequestWithContext(
+				context.Background(),
+				http.MethodGet,
+				testServer.URL+"/health/memory",
+				nil,
+			)
+			request.Close = true
+
+			response, err := http.DefaultClient.Do(request)
+			assert.NoError(t, err)
+			assert.NoError(t, response.Body.Close())
+
+			assert.Equal(t, test.statusCode, response.StatusCode)
+
+			testServer.Close()
+		})
+	}
+}
+
+func TestHeartbeatLiveness(t *testing.T) {
+	clock := NewFakeClock()
+	entered := make(chan struct{})
+	release := make(chan struct{})
+
+	svr := server.New(
+		context.Background(),
+		&server.NoOpRecorder{},
+		server.WithClock(clock.Now),
+		server.WithHeartbeatLiveness(time.Minute),
+	)
+	svr.Router().Handle("/stuck", http.HandlerFunc(func(_ http.ResponseWriter, _ *http.Request) {
+		entered <- struct{}{}
+		<-release
+	})).Methods(http.MethodGet)
+
+	testServer := httptest.NewServer(svr)
+	defer testServer.Close()
+
+	check := func() (int, string) {
+		code, body, err := fetch(t, testServer.URL+"/health/heartbeat?verbose")
+		assert.NoError(t, err)
+
+		return code, body
+	}
+
+	// Idle time does not count against the heartbeat
+	clock.Advance(time.Hour)
+
+	code, _ := check()
+	assert.Equal(t, http.StatusOK, code)
+
+	var group sync.WaitGroup
+
+	stuck := func() {
+		group.Go(func() {
+			_, _, err := fetch(t, testServer.URL+"/stuck")
+			assert.NoError(t, err)
+		})
+
+		<-entered
+	}
+
+	// A single long request, such as a stream, is not a stall without other traffic
+	stuck()
+	clock.Advance(time.Hour)
+
+	code, _ = check()
+	assert.Equal(t, http.StatusOK, code)
+
+	// Neither are several streams open past the window
+	stuck()
+	clock.Advance(30 * time.Second)
+	stuck()
+
+	code, _ = check()
+	assert.Equal(t, http.StatusOK, code)
+
+	clock.Advance(time.Hour)
+
+	code, _ = check()
+	assert.Equal(t, http.StatusOK, code)
+
+	// A new request arriving more than a window later without any completing is
+	stuck()
+
+	code, body := check()
+	assert.Equal(t, http.StatusInternalServerError, code)
+	assert.Equal(t, "\"requests stalled: 4 in flight, none completed in 1h0m30s\"\n", body)
+
+	close(release)
+	group.Wait()
+
+	code, _ = check()
+	assert.Equal(t, http.StatusOK, code)
+}
@@ -3,10 +3,17 @@ package server
 
 import (
 	"context"
+	"crypto/tls"
 	"errors"
 	"fmt"
+	"maps"
+	"net"
 	"net/http"
+	"net/netip"
+	"runtime"
+	"slices"
 	"sync"
+	"syscall"
 	"time"
 
 	"github.com/google/uuid"
@@ -15,50 +22,211 @@ import (
 )
 
 const (
-	defaultPort    = 5000
-	defaultTimeout = 5 * time.Second
+	defaultPort            = 5000
+	defaultTimeout         = 5 * time.Second
+	defaultShutdownTimeout = time.Minute
 
 	healthEndpoint  = "/health"
+	readyEndpoint   = "/ready"
 	metricsEndpoint = "/metrics"
 	pingEndpoint    = "/ping"
 	versionEndpoint = "/version"
+	routesEndpoint  = "/admin/routes"
+	configEndpoint  = "/admin/config"
+	faviconEndpoint = "/favicon.ico"
 )
 
 // Server is a supply-run API web server.
 type Server struct {
 	mu                    sync.Mutex
+	prepare               sync.Once
 	readCorrelationHeader bool
+	trustedProxies        []netip.Prefix
+	compression           bool
+	responseTransformer   ResponseTransformer
+	pathMiddleware        []pathMiddleware
+	idempotency           *idempotency
+	idempotencyScope      IdempotencyScope
+	autoFlushInterval     time.Duration
+	maxHeaderCount        int
+	minReadRate           int
+	maxRequestBodySize    int64
+	maxResponseSize       int64
+	responseSizeWarning   int64
+	requestDecompression  bool
+	rateLimit             RateLimitConfig
+	routeRateLimits       map[string]RateLimitConfig
+	rateLimiter           *rateLimiter
+	maxConcurrentRequests int
+	serverTiming          bool
+	requestTimeout        time.Duration
+	requestTimeouts       map[string]time.Duration
+	writeDeadlineContext  bool
+	shutdownTimeout       time.Duration
+	jsonTimeoutCode       int
+	panicBreaker          *panicBreaker
 	newCorrelationID      func() string
+	correlationResponse   CorrelationResponsePolicy
+	traceparent           bool
+	unmatchedPathLabel    string
+	metricRoutes          map[string]bool
+	encodeJSON            JSONEncoder
+	defaultHeaders        map[string]string
+	commonLog             *commonLog
+	logTimeFormat         string
+	gcpLogFields          bool
+	gcpProjectID          string
+	startRequestLog       bool
+	recorder              Recorder
 	router                *mux.Router
+	endpoints             *mux.Router
+	endpointPaths         map[string]bool
+	routesEndpoint        bool
+	autoHead              bool
+	configAuth            Authenticator
+	authenticator         Authenticator
+	favicon               http.Handler
 	http                  *http.Server
+	listener              net.Listener
+	certFile              string
+	keyFile               string
+	tlsConfig             *tls.Config
+	reusePort             bool
+	bindAttempts          int
+	bindRetryDelay        time.Duration
+	adminAddr             string
+	admin                 *http.Server
+	draining              bool
+	drainBody             string
+	drainContentType      string
 	healthDependencies    map[string]HealthChecker
+	readyDependencies     map[string]HealthChecker
+	unhealthyCodes        map[string]int
+	healthTimeout         time.Duration
+	healthTimeouts        map[string]time.Duration
+	healthOKBody          string
+	healthTimings         bool
+	dependencyDetails     bool
+	failFastHealth        bool
+	healthSlots           chan struct{}
+	healthContentType     string
+	heartbeat             *heartbeatCheck
+	now                   func() time.Time
 	startedAt             time.Time
+	createdAt             time.Time
+	uptimeFromCreation    bool
+	done                  chan struct{}
+	warmup                time.Duration
 	version               string
 }
 
+var (
+	// ErrAlreadyStarted is returned when starting a Server that is already running.
+	ErrAlreadyStarted = errors.New("server already started")
+
+	// ErrReusePortUnsupported is returned when starting a Server with WithReusePort on a platform other than Linux.
+	ErrReusePortUnsupported = errors.New("SO_REUSEPORT is only supported on linux")
+)
+
 // New creates a new Server.
 func New(ctx context.Context, recorder Recorder, options ...Option) *Server {
 	server := &Server{
 		readCorrelationHeader: false,
+		trustedProxies:        make([]netip.Prefix, 0),
+		compression:           false,
+		responseTransformer:   nil,
+		pathMiddleware:        nil,
+		idempotency:           nil,
+		idempotencyScope:      nil,
+		autoFlushInterval:     0,
+		maxHeaderCount:        0,
+		minReadRate:           0,
+		maxRequestBodySize:    0,
+		maxResponseSize:       0,
+		responseSizeWarning:   0,
+		requestDecompression:  false,
+		rateLimit:             RateLimitConfig{Requests: 0, Window: 0},
+		routeRateLimits:       make(map[string]RateLimitConfig),
+		rateLimiter:           newRateLimiter(),
+		maxConcurrentRequests: 0,
+		serverTiming:          false,
+		requestTimeout:        0,
+		requestTimeouts:       make(map[string]time.Duration),
+		writeDeadlineContext:  false,
+		shutdownTimeout:       defaultShutdownTimeout,
+		jsonTimeoutCode:       0,
+		panicBreaker:          nil,
 		newCorrelationID:      uuid.NewString,
+		correlationResponse:   CorrelationAlways,
+		traceparent:           false,
+		unmatchedPathLabel:    unmatchedRoute,
+		metricRoutes:          make(map[string]bool),
+		encodeJSON:            encodeJSON,
+		defaultHeaders:        make(map[string]string),
+		commonLog:             nil,
+		logTimeFormat:         time.RFC3339,
+		gcpLogFields:          false,
+		gcpProjectID:          "",
+		startRequestLog:       false,
+		recorder:              recorder,
 		router:                mux.NewRouter(),
+		endpoints:             nil,
+		endpointPaths:         make(map[string]bool),
+		routesEndpoint:        false,
+		autoHead:              false,
+		configAuth:            nil,
+		authenticator:         nil,
+		favicon:               nil,
 		http: &http.Server{
 			Addr:              fmt.Sprintf(":%d", defaultPort),
 			ReadTimeout:       defaultTimeout,
 			ReadHeaderTimeout: defaultTimeout,
 			WriteTimeout:      defaultTimeout,
+			ConnState:         nil,
 		},
+		listener:           nil,
+		certFile:           "",
+		keyFile:            "",
+		tlsConfig:          nil,
+		reusePort:          false,
+		bindAttempts:       1,
+		bindRetryDelay:     0,
+		adminAddr:          "",
+		admin:              nil,
+		draining:           false,
+		drainBody:          defaultDrainingBody,
+		drainContentType:   defaultDrainingContentType,
 		healthDependencies: make(map[string]HealthChecker),
+		readyDependencies:  make(map[string]HealthChecker),
+		unhealthyCodes:     make(map[string]int),
+		healthTimeout:      0,
+		healthTimeouts:     make(map[string]time.Duration),
+		healthOKBody:       "",
+		healthTimings:      false,
+		dependencyDetails:  false,
+		failFastHealth:     false,
+		healthSlots:        nil,
+		healthContentType:  "application/json",
+		heartbeat:          nil,
+		now:                time.Now,
 		startedAt:          time.Time{},
+		createdAt:          time.Time{},
+		uptimeFromCreation: false,
+		done:               make(chan struct{}),
+		warmup:             0,
 		version:            "",
 	}
 
-	server.addDefaultHandlers(ctx, recorder)
-
 	for _, option := range options {
 		option(ctx, server)
 	}
 
+	server.createdAt = server.now()
+	server.http.ConnState = server.observeConnState
+
+	server.addDefaultHandlers(ctx, recorder)
+	server.addMiddleware(ctx, recorder)
+
 	return server
 }
 
@@ -67,17 +235,43 @@ func (s *Server) Version() string {
 	return s.version
 }
 
-// Uptime is the amount of time the server has beeen running.
+// Uptime is the amount of time the server has beeen running. With WithUptimeFromCreation, it is the time since the
+// server was created instead.
 func (s *Server) Uptime() time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.uptimeFromCreation {
+		return s.now().Sub(s.createdAt)
+	}
+
 	if s.startedAt.IsZero() {
 		return 0
 	}
 
-	return time.Since(s.startedAt)
+	return s.now().Sub(s.startedAt)
 }
 
-// Addr returns the server address.
+func (s *Server) warmingUp() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return !s.startedAt.IsZero() && s.now().Sub(s.startedAt) < s.warmup
+}
+
+func (s *Server) isDraining() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.draining
+}
+
+// Addr returns the server address, or the listener address if one was provided with WithListener.
 func (s *Server) Addr() string {
+	if s.listener != nil {
+		return s.listener.Addr().String()
+	}
+
 	return s.http.Addr
 }
 
@@ -91,53 +285,296 @@ func (s *Server) WriteTimeout() time.Duration {
 	return s.http.WriteTimeout
 }
 
+// ShutdownTimeout returns how long Stop waits for in-flight requests to finish.
+func (s *Server) ShutdownTimeout() time.Duration {
+	return s.shutdownTimeout
+}
+
 // Router returns the server router.
 func (s *Server) Router() *mux.Router {
 	return s.router
 }
 
+// Subrouter returns a router for every path under the prefix, such as /api/v1, which the caller can configure freely
+// with its own routes and middleware. Routes added to it still run the server middleware, so they are metered and
+// logged by their full path template.
+func (s *Server) Subrouter(prefix string) *mux.Router {
+	return s.router.PathPrefix(prefix).Subrouter()
+}
+
 func (s *Server) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
 	s.prepareHTTPServe()
 	s.http.Handler.ServeHTTP(writer, request)
 }
 
-// Start the Server.
+// Start the Server. Starting a Server that is already running returns ErrAlreadyStarted.
 func (s *Server) Start(ctx context.Context) error {
-	zerolog.Ctx(ctx).Info().Str("addr", s.http.Addr).Msg("starting server")
-	s.prepareHTTPServe()
-
 	s.mu.Lock()
-	s.startedAt = time.Now()
+
+	if !s.startedAt.IsZero() {
+		s.mu.Unlock()
+
+		return ErrAlreadyStarted
+	}
+
+	s.startedAt = s.now()
+	s.draining = false
 	s.mu.Unlock()
 
-	if err := s.http.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+	s.prepareHTTPServe()
+
+	// One summary line lets operators confirm the server came up as configured. CPU limits that do not match
+	// GOMAXPROCS are a common cause of latency in containers.
+	zerolog.Ctx(ctx).Info().
+		Str("addr", s.Addr()).
+		Int("gomaxprocs", runtime.GOMAXPROCS(0)).
+		Int("num_cpu", runtime.NumCPU()).
+		Str("go_version", runtime.Version()).
+		Str("version", s.version).
+		Dur("read_timeout", s.http.ReadTimeout).
+		Dur("write_timeout", s.http.WriteTimeout).
+		Dur("request_timeout", s.requestTimeout).
+		Bool("tls", s.tlsEnabled()).
+		Int("routes", len(s.Routes())).
+		Int("health_dependencies", len(s.readinessChecks())).
+		Msg("starting server")
+
+	if s.adminAddr != "" {
+		if err := s.startAdmin(ctx); err != nil {
+			s.mu.Lock()
+			s.startedAt = time.Time{}
+			s.mu.Unlock()
+
+			return err
+		}
+	}
+
+	if err := s.serve(ctx); !errors.Is(err, http.ErrServerClosed) {
+		s.mu.Lock()
+		s.startedAt = time.Time{}
+		s.mu.Unlock()
+
+		_ = s.stopAdmin(ctx)
+
 		return err //nolint: wrapcheck
 	}
 
 	return nil
 }
 
-// Stop the Server.
-func (s *Server) Stop(ctx context.Context) error {
-	zerolog.Ctx(ctx).Info().Str("addr", s.http.Addr).Msg("stopping server")
+func (s *Server) serve(ctx context.Context) error {
+	listener := s.listener
+	if listener == nil {
+		var err error
+
+		if listener, err = s.listen(ctx); err != nil {
+			return err
+		}
+	}
+
+	if s.tlsEnabled() {
+		// ServeTLS adds to the config, so keep the configured one untouched across restarts
+		s.http.TLSConfig = s.tlsConfig.Clone()
 
+		return s.http.ServeTLS(listener, s.certFile, s.keyFile) //nolint: wrapcheck
+	}
+
+	return s.http.Serve(listener) //nolint: wrapcheck
+}
+
+// listen binds the server address, retrying while it is still in use by a previous listener.
+func (s *Server) listen(ctx context.Context) (net.Listener, error) {
+	config := net.ListenConfig{} //nolint: exhaustruct
+	if s.reusePort {
+		config.Control = reusePortControl
+	}
+
+	for attempt := 1; ; attempt++ {
+		listener, err := config.Listen(ctx, "tcp", s.http.Addr)
+		if err == nil || !errors.Is(err, syscall.EADDRINUSE) || attempt >= s.bindAttempts {
+			return listener, err //nolint: wrapcheck
+		}
+
+		zerolog.Ctx(ctx).Warn().Err(err).Int("attempt", attempt).Msg("address in use, retrying bind")
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err() //nolint: wrapcheck
+		case <-time.After(s.bindRetryDelay):
+		}
+	}
+}
+
+// Stop the Server. Stopping a Server that is not running does nothing.
+//
+// With an admin port, the main port drains first while the admin port keeps serving, so orchestrators can watch
+// /ready report the draining state and still scrape /metrics. The admin port stops once the main port has drained.
+func (s *Server) Stop(ctx context.Context) error {
 	s.mu.Lock()
+
+	if s.startedAt.IsZero() {
+		s.mu.Unlock()
+
+		return nil
+	}
+
 	s.startedAt = time.Time{}
+	s.draining = true
 	s.mu.Unlock()
 
-	ctx, cancel := context.WithTimeout(ctx, time.Minute)
+	zerolog.Ctx(ctx).Info().Str("addr", s.Addr()).Msg("stopping server")
+
+	ctx, cancel := context.WithTimeout(ctx, s.shutdownTimeout)
 	defer cancel()
 
-	return s.http.Shutdown(ctx) //nolint: wrapcheck
+	err := s.http.Shutdown(ctx)
+
+	if adminErr := s.stopAdmin(ctx); err == nil {
+		err = adminErr
+	}
+
+	s.mu.Lock()
+	select {
+	case <-s.done:
+	default:
+		close(s.done)
+	}
+	s.mu.Unlock()
+
+	return err //nolint: wrapcheck
+}
+
+// Done returns a channel that is closed once Stop has finished shutting the Server down.
+func (s *Server) Done() <-chan struct{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.done
+}
+
+// Restart stops the Server, applies the given options, and starts it again with the same routes and dependencies.
+// Options that only apply when creating a Server, such as middleware, have no effect. A listener provided with
+// WithListener is closed on stop, so it must be provided again. Like Start, Restart blocks until the Server stops.
+func (s *Server) Restart(ctx context.Context, options ...Option) error {
+	if err := s.Stop(ctx); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	s.http = &http.Server{
+		Addr:              s.http.Addr,
+		Handler:           s.http.Handler,
+		ReadTimeout:       s.http.ReadTimeout,
+		ReadHeaderTimeout: s.http.ReadHeaderTimeout,
+		WriteTimeout:      s.http.WriteTimeout,
+		ConnState:         s.http.ConnState,
+	}
+	s.listener = nil
+	s.done = make(chan struct{})
+	s.mu.Unlock()
+
+	for _, option := range options {
+		option(ctx, s)
+	}
+
+	return s.Start(ctx)
 }
 
 func (s *Server) prepareHTTPServe() {
-	if s.router.NotFoundHandler == nil {
-		// Re-define the default NotFound handler so it passes through middleware correctly.
-		s.router.NotFoundHandler = s.router.NewRoute().HandlerFunc(http.NotFound).GetHandler()
+	s.prepare.Do(func() {
+		if s.router.NotFoundHandler == nil {
+			// Re-define the default NotFound handler so it passes through middleware correctly.
+			s.router.NotFoundHandler = s.router.NewRoute().HandlerFunc(http.NotFound).GetHandler()
+		}
+
+		s.http.Handler = s.handler()
+
+		// Recorded once routes are final, so dashboards can catch deployments missing routes or dependencies
+		if registrations, ok := s.recorder.(RegistrationRecorder); ok {
+			registrations.ObserveRoutesRegistered(len(s.Routes()))
+			registrations.ObserveHealthDependencies(len(s.readinessChecks()))
+		}
+	})
+}
+
+func (s *Server) addMiddleware(ctx context.Context, recorder Recorder) {
+	zerolog.Ctx(ctx).Debug().Str("middleware", "draining").Msg("register")
+	s.router.Use(s.drainingMiddleware)
+
+	if s.maxHeaderCount > 0 {
+		zerolog.Ctx(ctx).Debug().Str("middleware", "max header count").Msg("register")
+		s.router.Use(s.headerCountMiddleware)
+	}
+
+	if s.minReadRate > 0 {
+		zerolog.Ctx(ctx).Debug().Str("middleware", "min read rate").Msg("register")
+		s.router.Use(s.minReadRateMiddleware)
+	}
+
+	if s.maxRequestBodySize > 0 {
+		zerolog.Ctx(ctx).Debug().Str("middleware", "max request body size").Msg("register")
+		s.router.Use(s.maxBodySizeMiddleware)
+	}
+
+	if s.requestDecompression {
+		zerolog.Ctx(ctx).Debug().Str("middleware", "request decompression").Msg("register")
+		s.router.Use(s.requestDecompressionMiddleware)
+	}
+
+	if s.rateLimit.enabled() || len(s.routeRateLimits) > 0 {
+		zerolog.Ctx(ctx).Debug().Str("middleware", "rate limit").Msg("register")
+		s.router.Use(s.rateLimitMiddleware)
+	}
+
+	if s.maxConcurrentRequests > 0 {
+		zerolog.Ctx(ctx).Debug().Str("middleware", "concurrency limit").Msg("register")
+		s.router.Use(s.concurrencyMiddleware(recorder))
+	}
+
+	if s.panicBreaker != nil {
+		zerolog.Ctx(ctx).Debug().Str("middleware", "panic circuit breaker").Msg("register")
+		s.router.Use(s.panicBreakerMiddleware)
+	}
+
+	if s.writeDeadlineContext {
+		zerolog.Ctx(ctx).Debug().Str("middleware", "write deadline context").Msg("register")
+		s.router.Use(s.writeDeadlineContextMiddleware)
+	}
+
+	if s.requestTimeout > 0 || len(s.requestTimeouts) > 0 {
+		zerolog.Ctx(ctx).Debug().Str("middleware", "timeout").Msg("register")
+		s.router.Use(s.timeoutMiddleware)
+	}
+
+	if s.compression {
+		zerolog.Ctx(ctx).Debug().Str("middleware", "compression").Msg("register")
+		s.router.Use(compressionMiddleware)
+	}
+
+	if s.responseTransformer != nil {
+		zerolog.Ctx(ctx).Debug().Str("middleware", "response transformer").Msg("register")
+		s.router.Use(s.transformMiddleware)
+	}
+
+	for _, middleware := range s.pathMiddleware {
+		zerolog.Ctx(ctx).Debug().Str("middleware", "path").Str("prefix", middleware.prefix).Msg("register")
+		s.router.Use(middleware.handler)
+	}
+
+	if s.idempotency != nil {
+		zerolog.Ctx(ctx).Debug().Str("middleware", "idempotency").Msg("register")
+
+		if s.idempotencyScope != nil {
+			s.idempotency.scope = s.idempotencyScope
+		}
+
+		s.router.Use(s.idempotency.middleware)
 	}
 
-	s.http.Handler = s.router
+	if s.autoFlushInterval > 0 {
+		zerolog.Ctx(ctx).Debug().Str("middleware", "auto flush").Msg("register")
+		s.router.Use(s.autoFlushMiddleware)
+	}
 }
 
 func (s *Server) addDefaultHandlers(ctx context.Context, recorder Recorder) {
@@ -169,25 +606,69 @@ func (s *Server) addDefaultHandlers(ctx context.Context, recorder Recorder) {
 		}(),
 	).Methods(http.MethodGet)
 
-	zerolog.Ctx(ctx).Debug().Str("method", http.MethodGet).Str("path", metricsEndpoint).Msg("register")
-	s.router.Handle(
-		pingEndpoint,
-		func() http.HandlerFunc {
-			return func(writer http.ResponseWriter, _ *http.Request) {
-				_, _ = writer.Write([]byte(`pong`))
-			}
-		}(),
-	).Methods(http.MethodGet)
+	metrics := recorder.Handler()
+	if metrics == nil {
+		zerolog.Ctx(ctx).Warn().Msg("recorder has no metrics handler")
 
-	s.router.Handle(
-		metricsEndpoint,
-		func() http.HandlerFunc {
-			return func(writer http.ResponseWriter, request *http.Request) {
-				recorder.Handler().ServeHTTP(writer, request)
-			}
-		}(),
-	).Methods(http.MethodGet)
+		metrics = http.HandlerFunc(func(http.ResponseWriter, *http.Request) {})
+	}
+
+	zerolog.Ctx(ctx).Debug().Str("method", http.MethodGet).Str("path", metricsEndpoint).Msg("register")
+	s.router.Handle(metricsEndpoint, metrics).Methods(http.MethodGet)
 
 	zerolog.Ctx(ctx).Debug().Str("method", http.MethodGet).Str("path", healthEndpoint).Msg("register")
-	s.router.Handle(healthEndpoint, s.healthCheckHandler()).Methods(http.MethodGet)
+	s.router.Handle(healthEndpoint, s.healthCheckHandler("healthz", func() map[string]HealthChecker {
+		return s.healthDependencies
+	})).Methods(http.MethodGet)
+
+	zerolog.Ctx(ctx).Debug().Str("method", http.MethodGet).Str("path", readyEndpoint).Msg("register")
+	s.router.Handle(readyEndpoint, s.readinessHandler()).Methods(http.MethodGet)
+
+	// Endpoints enabled by options get their own subrouter, so those added after the server first serves a request
+	// still match before the catch-all not found route
+	s.endpoints = s.router.NewRoute().Subrouter()
+
+	for _, name := range slices.Sorted(maps.Keys(s.healthDependencies)) {
+		s.handleEndpoint(ctx, healthEndpoint+"/"+name, s.dependencyHealthCheckHandler(name))
+	}
+
+	if s.routesEndpoint {
+		s.handleEndpoint(ctx, routesEndpoint, s.routesHandler())
+	}
+
+	if s.configAuth != nil {
+		s.handleEndpoint(ctx, configEndpoint, s.configEndpointHandler())
+	}
+
+	if s.favicon != nil {
+		s.handleEndpoint(ctx, faviconEndpoint, s.faviconHandler())
+	}
+}
+
+// handleEndpoint registers a GET endpoint enabled by an option, once. Options applied after New, such as through
+// Restart, register their endpoint right away, while those applied during New wait for addDefaultHandlers, since
+// WithRouter may still replace the router.
+func (s *Server) handleEndpoint(ctx context.Context, path string, handler http.Handler) {
+	if s.endpoints == nil || s.endpointPaths[path] {
+		return
+	}
+
+	zerolog.Ctx(ctx).Debug().Str("method", http.MethodGet).Str("path", path).Msg("register")
+	s.endpoints.Handle(path, handler).Methods(http.MethodGet)
+
+	s.endpointPaths[path] = true
+}
+
+// configEndpointHandler serves the config endpoint behind the authenticator set when the request arrives.
+func (s *Server) configEndpointHandler() http.Handler {
+	return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		requireAuth(s.configAuth, s.configHandler()).ServeHTTP(writer, request)
+	})
+}
+
+// faviconHandler serves the icon set when the request arrives.
+func (s *Server) faviconHandler() http.Handler {
+	return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		s.favicon.ServeHTTP(writer, request)
+	})
 }
@@ -5,13 +5,18 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"log"
 	"net"
 	"net/http"
 	"net/http/httptest"
+	"runtime"
+	"sync"
+	"syscall"
 	"testing"
 	"time"
 
 	"github.com/b-sea/go-server/server"
+	"github.com/gorilla/mux"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/pkgerrors"
 	"github.com/stretchr/testify/assert"
@@ -30,6 +35,197 @@ func findOpenPort(t *testing.T) int {
 	return port
 }
 
+func withLogger(handler http.Handler, log zerolog.Logger) http.Handler {
+	return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		handler.ServeHTTP(writer, request.WithContext(log.WithContext(request.Context())))
+	})
+}
+
+func waitForServer(t *testing.T, url string) {
+	t.Helper()
+
+	assert.Eventually(t, func() bool {
+		request, _ := http.NewRequestWithContext(context.Background(), http.MethodGet, url+"/ping", nil)
+		request.Close = true
+
+		response, err := http.DefaultClient.Do(request)
+		if err != nil {
+			return false
+		}
+
+		_ = response.Body.Close()
+
+		return response.StatusCode == http.StatusOK
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+// safeBuffer is a bytes.Buffer that can be written by a running server while a test reads it.
+type safeBuffer struct {
+	mu     sync.Mutex
+	buffer bytes.Buffer
+}
+
+func (b *safeBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.buffer.Write(p)
+}
+
+func (b *safeBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.buffer.String()
+}
+
+type FakeClock struct {
+	mu      sync.Mutex
+	current time.Time
+}
+
+func NewFakeClock() *FakeClock {
+	return &FakeClock{current: time.Date(2025, time.January, 1, 0, 0, 0, 0, time.UTC)}
+}
+
+func (c *FakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.current
+}
+
+func (c *FakeClock) Advance(duration time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.current = c.current.Add(duration)
+}
+
+var _ server.Recorder = (*SpyRecorder)(nil)
+
+type Observation struct {
+	Method string
+	Path   string
+	Code   int
+	Value  float64
+}
+
+type SpyRecorder struct {
+	mu         sync.Mutex
+	Durations  []Observation
+	Sizes      []Observation
+	QueueTimes []Observation
+	Failures   []Observation
+	Errors     []Observation
+	Routes     int
+	Health     int
+	TLS        []string
+	TLSErrors  int
+}
+
+func (r *SpyRecorder) Handler() http.Handler {
+	return http.NotFoundHandler()
+}
+
+func (r *SpyRecorder) ObserveHTTPRequestDuration(method string, path string, code int, duration time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.Durations = append(r.Durations, Observation{Method: method, Path: path, Code: code, Value: duration.Seconds()})
+}
+
+func (r *SpyRecorder) ObserveHTTPResponseSize(method string, path string, code int, bytes int64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.Sizes = append(r.Sizes, Observation{Method: method, Path: path, Code: code, Value: float64(bytes)})
+}
+
+func (r *SpyRecorder) ObserveHTTPQueueTime(method string, path string, duration time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.QueueTimes = append(r.QueueTimes, Observation{Method: method, Path: path, Code: 0, Value: duration.Seconds()})
+}
+
+func (r *SpyRecorder) ObserveHTTPFailure(method string, path string, code int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.Failures = append(r.Failures, Observation{Method: method, Path: path, Code: code, Value: 1})
+}
+
+func (r *SpyRecorder) ObserveRoutesRegistered(count int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.Routes = count
+}
+
+func (r *SpyRecorder) ObserveHealthDependencies(count int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.Health = count
+}
+
+func (r *SpyRecorder) ObserveHTTPError(method string, path string, code int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.Errors = append(r.Errors, Observation{Method: method, Path: path, Code: code, Value: 1})
+}
+
+func (r *SpyRecorder) ObserveTLSHandshake(version string, cipherSuite string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.TLS = append(r.TLS, version+" "+cipherSuite)
+}
+
+func (r *SpyRecorder) ObserveTLSHandshakeError() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.TLSErrors++
+}
+
+func (r *SpyRecorder) ErrorObservations() []Observation {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return append([]Observation{}, r.Errors...)
+}
+
+func (r *SpyRecorder) FailureObservations() []Observation {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return append([]Observation{}, r.Failures...)
+}
+
+func (r *SpyRecorder) QueueTimeObservations() []Observation {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return append([]Observation{}, r.QueueTimes...)
+}
+
+func (r *SpyRecorder) SizeObservations() []Observation {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return append([]Observation{}, r.Sizes...)
+}
+
+func (r *SpyRecorder) DurationObservations() []Observation {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return append([]Observation{}, r.Durations...)
+}
+
 func TestServerStartStop(t *testing.T) {
 	testServer := server.New(context.Background(), &server.NoOpRecorder{}, server.WithPort(findOpenPort(t)))
 
@@ -44,6 +240,276 @@ func TestServerStartStop(t *testing.T) {
 	assert.NoError(t, testServer.Stop(context.Background()))
 }
 
+func TestServerShutdownTimeout(t *testing.T) {
+	port := findOpenPort(t)
+	entered := make(chan struct{})
+	release := make(chan struct{})
+
+	testServer := server.New(
+		context.Background(),
+		&server.NoOpRecorder{},
+		server.WithPort(port),
+		server.WithShutdownTimeout(50*time.Millisecond),
+	)
+	testServer.Router().Handle("/slow", http.HandlerFunc(func(_ http.ResponseWriter, _ *http.Request) {
+		close(entered)
+		<-release
+	})).Methods(http.MethodGet)
+
+	done := make(chan error)
+
+	go func() {
+		done <- testServer.Start(context.Background())
+	}()
+
+	url := fmt.Sprintf("http://localhost:%d", port)
+	waitForServer(t, url)
+
+	fetched := make(chan struct{})
+
+	go func() {
+		defer close(fetched)
+
+		_, _, _ = fetch(t, url+"/slow")
+	}()
+
+	<-entered
+
+	start := time.Now()
+
+	assert.ErrorIs(t, testServer.Stop(context.Background()), context.DeadlineExceeded)
+	assert.Less(t, time.Since(start), time.Second)
+
+	close(release)
+	<-fetched
+	assert.NoError(t, <-done)
+}
+
+func TestServerDoubleStart(t *testing.T) {
+	port := findOpenPort(t)
+	testServer := server.New(context.Background(), &server.NoOpRecorder{}, server.WithPort(port))
+
+	done := make(chan error)
+
+	go func() {
+		done <- testServer.Start(context.Background())
+	}()
+
+	waitForServer(t, fmt.Sprintf("http://localhost:%d", port))
+
+	assert.ErrorIs(t, testServer.Start(context.Background()), server.ErrAlreadyStarted)
+	assert.NoError(t, testServer.Stop(context.Background()))
+	assert.NoError(t, <-done)
+}
+
+func TestServerStartLog(t *testing.T) {
+	var buffer safeBuffer
+
+	port := findOpenPort(t)
+	ctx := zerolog.New(&buffer).WithContext(context.Background())
+	testServer := server.New(
+		ctx,
+		&server.NoOpRecorder{},
+		server.WithPort(port),
+		server.WithVersion("1.2.3"),
+		server.WithRequestTimeout(2*time.Second),
+		server.WithHealthDependency("db", &HealthCheck{}),
+	)
+	testServer.Router().Handle("/things", http.NotFoundHandler()).Methods(http.MethodGet)
+
+	done := make(chan error)
+
+	go func() {
+		done <- testServer.Start(ctx)
+	}()
+
+	waitForServer(t, fmt.Sprintf("http://localhost:%d", port))
+
+	assert.NoError(t, testServer.Stop(ctx))
+	assert.NoError(t, <-done)
+
+	assert.Contains(
+		t,
+		buffer.String(),
+		fmt.Sprintf(
+			`"addr":":%d","gomaxprocs":%d,"num_cpu":%d,"go_version":"%s","version":"1.2.3","read_timeout":5000,`+
+				`"write_timeout":5000,"request_timeout":2000,"tls":false,"routes":%d,"health_dependencies":1,`+
+				`"message":"starting server"`,
+			port,
+			runtime.GOMAXPROCS(0),
+			runtime.NumCPU(),
+			runtime.Version(),
+			len(testServer.Routes()),
+		),
+	)
+}
+
+func TestServerWithListener(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+
+	testServer := server.New(context.Background(), &server.NoOpRecorder{}, server.WithListener(listener))
+	assert.Equal(t, listener.Addr().String(), testServer.Addr())
+
+	done := make(chan error)
+
+	go func() {
+		done <- testServer.Start(context.Background())
+	}()
+
+	waitForServer(t, "http://"+listener.Addr().String())
+
+	assert.NoError(t, testServer.Stop(context.Background()))
+	assert.NoError(t, <-done)
+}
+
+func TestServerDone(t *testing.T) {
+	port := findOpenPort(t)
+	testServer := server.New(context.Background(), &server.NoOpRecorder{}, server.WithPort(port))
+
+	go func() {
+		assert.NoError(t, testServer.Start(context.Background()))
+	}()
+
+	waitForServer(t, fmt.Sprintf("http://localhost:%d", port))
+
+	select {
+	case <-testServer.Done():
+		t.Fatal("done before stop")
+	default:
+	}
+
+	assert.NoError(t, testServer.Stop(context.Background()))
+
+	select {
+	case <-testServer.Done():
+	default:
+		t.Fatal("not done after stop")
+	}
+}
+
+func TestServerRestart(t *testing.T) {
+	router := mux.NewRouter()
+	router.Handle("/existing", http.HandlerFunc(func(writer http.ResponseWriter, _ *http.Request) {
+		_, _ = writer.Write([]byte(`still here`))
+	})).Methods(http.MethodGet)
+
+	oldPort := findOpenPort(t)
+	newPort := findOpenPort(t)
+
+	testServer := server.New(
+		context.Background(),
+		&server.NoOpRecorder{},
+		server.WithPort(oldPort),
+		server.WithRouter(router),
+		server.WithHealthDependency("db", &HealthCheck{}),
+	)
+
+	started := make(chan error, 1)
+
+	go func() {
+		started <- testServer.Start(context.Background())
+	}()
+
+	waitForServer(t, fmt.Sprintf("http://localhost:%d", oldPort))
+
+	restarted := make(chan error, 1)
+
+	go func() {
+		restarted <- testServer.Restart(
+			context.Background(), server.WithPort(newPort), server.WithHealthDependency("cache", &HealthCheck{}),
+		)
+	}()
+
+	assert.NoError(t, <-started)
+	waitForServer(t, fmt.Sprintf("http://localhost:%d", newPort))
+
+	for _, path := range []string{"/existing", "/health/db", "/health/cache"} {
+		request, _ := http.NewRequestWithContext(
+			context.Background(), http.MethodGet, fmt.Sprintf("http://localhost:%d%s", newPort, path), nil,
+		)
+		request.Close = true
+
+		response, err := http.DefaultClient.Do(request)
+		assert.NoError(t, err)
+		assert.NoError(t, response.Body.Close())
+
+		assert.Equal(t, http.StatusOK, response.StatusCode, path)
+	}
+
+	request, _ := http.NewRequestWithContext(
+		context.Background(), http.MethodGet, fmt.Sprintf("http://localhost:%d/ping", oldPort), nil,
+	)
+	request.Close = true
+
+	_, err := http.DefaultClient.Do(request) //nolint: bodyclose
+	assert.Error(t, err)
+
+	select {
+	case <-testServer.Done():
+		t.Fatal("done before stop")
+	default:
+	}
+
+	assert.NoError(t, testServer.Stop(context.Background()))
+	assert.NoError(t, <-restarted)
+}
+
+func TestServerBindRetry(t *testing.T) {
+	type testCase struct {
+		option server.Option
+		binds  bool
+	}
+
+	tests := map[string]testCase{
+		"retry": {
+			option: server.WithBindRetry(100, 10*time.Millisecond),
+			binds:  true,
+		},
+		"no retry": {
+			option: server.WithBindRetry(0, 0),
+			binds:  false,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			occupied, err := net.Listen("tcp", "localhost:0")
+			assert.NoError(t, err)
+
+			port := occupied.Addr().(*net.TCPAddr).Port
+			testServer := server.New(context.Background(), &server.NoOpRecorder{}, server.WithPort(port), test.option)
+
+			started := make(chan error, 1)
+
+			go func() {
+				started <- testServer.Start(context.Background())
+			}()
+
+			time.Sleep(50 * time.Millisecond)
+			assert.NoError(t, occupied.Close())
+
+			if !test.binds {
+				assert.ErrorIs(t, <-started, syscall.EADDRINUSE)
+
+				return
+			}
+
+			waitForServer(t, fmt.Sprintf("http://localhost:%d", port))
+
+			assert.NoError(t, testServer.Stop(context.Background()))
+			assert.NoError(t, <-started)
+		})
+	}
+}
+
+func TestServerStopBeforeStart(t *testing.T) {
+	testServer := server.New(context.Background(), &server.NoOpRecorder{}, server.WithPort(findOpenPort(t)))
+
+	assert.NoError(t, testServer.Stop(context.Background()))
+	assert.NoError(t, testServer.Stop(context.Background()))
+}
+
 func TestServerMetrics(t *testing.T) {
 	testServer := httptest.NewServer(server.New(context.Background(), &server.NoOpRecorder{}))
 
@@ -71,6 +537,45 @@ func TestServerMetrics(t *testing.T) {
 	testServer.Close()
 }
 
+type NilHandlerRecorder struct {
+	server.NoOpRecorder
+}
+
+func (r *NilHandlerRecorder) Handler() http.Handler {
+	return nil
+}
+
+func TestServerMetricsNilHandler(t *testing.T) {
+	var buffer bytes.Buffer
+
+	testServer := httptest.NewServer(
+		server.New(zerolog.New(&buffer).WithContext(context.Background()), &NilHandlerRecorder{}),
+	)
+
+	request, _ := http.NewRequestWithContext(
+		context.Background(),
+		http.MethodGet,
+		fmt.Sprintf("%s/metrics", testServer.URL),
+		nil,
+	)
+
+	request.Close = true
+
+	response, err := http.DefaultClient.Do(request)
+	assert.NoError(t, err)
+
+	body, err := io.ReadAll(response.Body)
+	assert.NoError(t, err)
+
+	assert.NoError(t, response.Body.Close())
+
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+	assert.Equal(t, ``, string(body))
+	assert.Contains(t, buffer.String(), `{"level":"warn","message":"recorder has no metrics handler"}`)
+
+	testServer.Close()
+}
+
 func TestServerPing(t *testing.T) {
 	testServer := httptest.NewServer(server.New(context.Background(), &server.NoOpRecorder{}))
 
@@ -166,3 +671,330 @@ func TestPanickedHandler(t *testing.T) {
 
 	testServer.Close()
 }
+
+func TestPanickedHandlerDetails(t *testing.T) {
+	var buffer bytes.Buffer
+
+	svr := server.New(context.Background(), &server.NoOpRecorder{})
+
+	svr.Router().Handle(
+		"/test/{id}",
+		func() http.HandlerFunc {
+			return func(writer http.ResponseWriter, _ *http.Request) {
+				panic("uh oh!")
+			}
+		}(),
+	).Methods(http.MethodPost)
+
+	testServer := httptest.NewServer(withLogger(svr, zerolog.New(&buffer).Level(zerolog.ErrorLevel)))
+
+	request, _ := http.NewRequestWithContext(
+		context.Background(),
+		http.MethodPost,
+		fmt.Sprintf("%s/test/123?full=true", testServer.URL),
+		nil,
+	)
+
+	request.Close = true
+
+	response, err := http.DefaultClient.Do(request)
+	assert.NoError(t, err)
+	assert.NoError(t, response.Body.Close())
+
+	testServer.Close()
+
+	assert.Equal(t, http.StatusInternalServerError, response.StatusCode)
+	assert.Contains(t, buffer.String(), `"error":"panic: uh oh!"`)
+	assert.Contains(t, buffer.String(), `"method":"POST","path":"/test/{id}","url":"/test/123?full=true"`)
+}
+
+func TestPanickedHandlerAfterWrite(t *testing.T) {
+	var (
+		buffer   safeBuffer
+		errorLog safeBuffer
+	)
+
+	recorder := &SpyRecorder{}
+	svr := server.New(context.Background(), recorder)
+
+	svr.Router().Handle(
+		"/test",
+		func() http.HandlerFunc {
+			return func(writer http.ResponseWriter, _ *http.Request) {
+				_, _ = writer.Write([]byte(`partial`))
+
+				panic("uh oh!")
+			}
+		}(),
+	).Methods(http.MethodGet)
+
+	testServer := httptest.NewUnstartedServer(withLogger(svr, zerolog.New(&buffer).Level(zerolog.ErrorLevel)))
+	testServer.Config.ErrorLog = log.New(&errorLog, "", 0)
+	testServer.Start()
+
+	code, body, err := fetch(t, testServer.URL+"/test")
+	assert.NoError(t, err)
+
+	testServer.Close()
+
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "partial", body)
+	assert.Contains(t, buffer.String(), `"error":"panic: uh oh!"`)
+	assert.NotContains(t, errorLog.String(), "superfluous")
+
+	durations := recorder.DurationObservations()
+	if assert.Len(t, durations, 1) {
+		assert.Equal(t, http.StatusOK, durations[0].Code)
+	}
+}
+
+func TestServerFavicon(t *testing.T) {
+	type testCase struct {
+		option      server.Option
+		result      string
+		statusCode  int
+		contentType string
+	}
+
+	tests := map[string]testCase{
+		"not configured": {
+			option:      nil,
+			result:      "404 page not found\n",
+			statusCode:  http.StatusNotFound,
+			contentType: "text/plain; charset=utf-8",
+		},
+		"icon": {
+			option:      server.WithFavicon([]byte("icon-bytes"), "image/x-icon"),
+			result:      "icon-bytes",
+			statusCode:  http.StatusOK,
+			contentType: "image/x-icon",
+		},
+		"suppressed": {
+			option:      server.WithFavicon(nil, ""),
+			result:      "",
+			statusCode:  http.StatusNoContent,
+			contentType: "",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			options := []server.Option{}
+			if test.option != nil {
+				options = append(options, test.option)
+			}
+
+			testServer := httptest.NewServer(server.New(context.Background(), &server.NoOpRecorder{}, options...))
+
+			request, _ := http.NewRequestWithContext(context.Background(), http.MethodGet, testServer.URL+"/favicon.ico", nil)
+			request.Close = true
+
+			response, err := http.DefaultClient.Do(request)
+			assert.NoError(t, err)
+
+			body, err := io.ReadAll(response.Body)
+			assert.NoError(t, err)
+
+			assert.NoError(t, response.Body.Close())
+
+			assert.Equal(t, test.statusCode, response.StatusCode)
+			assert.Equal(t, test.contentType, response.Header.Get("Content-Type"))
+			assert.Equal(t, test.result, string(body))
+
+			testServer.Close()
+		})
+	}
+}
+
+func TestServerWithRouter(t *testing.T) {
+	router := mux.NewRouter()
+	router.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+			writer.Header().Set("X-Existing", "yes")
+			next.ServeHTTP(writer, request)
+		})
+	})
+	router.Handle("/existing", http.HandlerFunc(func(writer http.ResponseWriter, _ *http.Request) {
+		_, _ = writer.Write([]byte(`still here`))
+	})).Methods(http.MethodGet)
+
+	svr := server.New(context.Background(), &server.NoOpRecorder{}, server.WithRouter(router))
+	assert.Same(t, router, svr.Router())
+
+	testServer := httptest.NewServer(svr)
+
+	for path, expected := range map[string]string{"/existing": "still here", "/ping": "pong"} {
+		request, _ := http.NewRequestWithContext(context.Background(), http.MethodGet, testServer.URL+path, nil)
+		request.Close = true
+
+		response, err := http.DefaultClient.Do(request)
+		assert.NoError(t, err)
+
+		body, err := io.ReadAll(response.Body)
+		assert.NoError(t, err)
+		assert.NoError(t, response.Body.Close())
+
+		assert.Equal(t, http.StatusOK, response.StatusCode)
+		assert.Equal(t, expected, string(body))
+		assert.Equal(t, "yes", response.Header.Get("X-Existing"))
+		assert.NotEmpty(t, response.Header.Get("Correlation-ID"))
+	}
+
+	testServer.Close()
+}
+
+func TestServerWithRouterMiddlewareOrder(t *testing.T) {
+	seen := map[string]bool{}
+
+	routeTemplateSeen := func(name string) mux.MiddlewareFunc {
+		return func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+				_, seen[name] = server.RouteTemplateFromContext(request.Context())
+
+				if request.URL.Query().Has(name + "-panic") {
+					panic("uh oh!")
+				}
+
+				next.ServeHTTP(writer, request)
+			})
+		}
+	}
+
+	router := mux.NewRouter()
+	router.Use(routeTemplateSeen("existing"))
+	router.Handle("/existing", http.HandlerFunc(func(http.ResponseWriter, *http.Request) {})).Methods(http.MethodGet)
+
+	svr := server.New(context.Background(), &server.NoOpRecorder{}, server.WithRouter(router))
+	svr.Router().Use(routeTemplateSeen("added"))
+
+	response := httptest.NewRecorder()
+	svr.ServeHTTP(response, httptest.NewRequest(http.MethodGet, "/existing", nil))
+
+	// Middleware already on the router runs before the server middleware, and middleware added after New runs inside
+	assert.Equal(t, http.StatusOK, response.Code)
+	assert.Equal(t, map[string]bool{"existing": false, "added": true}, seen)
+
+	response = httptest.NewRecorder()
+	svr.ServeHTTP(response, httptest.NewRequest(http.MethodGet, "/existing?added-panic", nil))
+	assert.Equal(t, http.StatusInternalServerError, response.Code)
+
+	assert.Panics(t, func() {
+		svr.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/existing?existing-panic", nil))
+	})
+}
+
+func TestServerLateEndpointOptions(t *testing.T) {
+	type testCase struct {
+		option     server.Option
+		path       string
+		statusCode int
+	}
+
+	tests := map[string]testCase{
+		"health dependency": {
+			option:     server.WithHealthDependency("db", &HealthCheck{}),
+			path:       "/health/db",
+			statusCode: http.StatusOK,
+		},
+		"routes endpoint": {
+			option:     server.WithRoutesEndpoint(),
+			path:       "/admin/routes",
+			statusCode: http.StatusOK,
+		},
+		"favicon": {
+			option:     server.WithFavicon(nil, ""),
+			path:       "/favicon.ico",
+			statusCode: http.StatusNoContent,
+		},
+		"config endpoint": {
+			option:     server.WithConfigEndpoint(func(*http.Request) bool { return false }),
+			path:       "/admin/config",
+			statusCode: http.StatusUnauthorized,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			svr := server.New(context.Background(), &server.NoOpRecorder{})
+
+			// Applying the option twice must not register the endpoint twice
+			test.option(context.Background(), svr)
+			test.option(context.Background(), svr)
+
+			testServer := httptest.NewServer(svr)
+
+			request, _ := http.NewRequestWithContext(context.Background(), http.MethodGet, testServer.URL+test.path, nil)
+			request.Close = true
+
+			response, err := http.DefaultClient.Do(request)
+			assert.NoError(t, err)
+			assert.NoError(t, response.Body.Close())
+
+			testServer.Close()
+
+			assert.Equal(t, test.statusCode, response.StatusCode)
+
+			registered := 0
+
+			for _, route := range svr.Routes() {
+				if route.Path == test.path {
+					registered++
+				}
+			}
+
+			assert.Equal(t, 1, registered)
+		})
+	}
+}
+
+func TestServerWithNilRouter(t *testing.T) {
+	svr := server.New(context.Background(), &server.NoOpRecorder{}, server.WithRouter(nil))
+
+	assert.NotNil(t, svr.Router())
+	assert.NotEmpty(t, svr.Routes())
+}
+
+func TestServerSubrouter(t *testing.T) {
+	var logs safeBuffer
+
+	recorder := &SpyRecorder{}
+	svr := server.New(context.Background(), recorder)
+
+	api := svr.Subrouter("/api/v1")
+	api.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+			writer.Header().Set("X-API", "v1")
+			next.ServeHTTP(writer, request)
+		})
+	})
+	api.Handle("/things/{id}", http.HandlerFunc(func(writer http.ResponseWriter, _ *http.Request) {
+		_, _ = writer.Write([]byte(`thing`))
+	})).Methods(http.MethodGet)
+
+	testServer := httptest.NewServer(withLogger(svr, zerolog.New(&logs)))
+
+	request, _ := http.NewRequestWithContext(context.Background(), http.MethodGet, testServer.URL+"/api/v1/things/1", nil)
+	request.Close = true
+
+	response, err := http.DefaultClient.Do(request)
+	assert.NoError(t, err)
+
+	body, err := io.ReadAll(response.Body)
+	assert.NoError(t, err)
+	assert.NoError(t, response.Body.Close())
+
+	testServer.Close()
+
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+	assert.Equal(t, "thing", string(body))
+	assert.Equal(t, "v1", response.Header.Get("X-API"))
+	assert.NotEmpty(t, response.Header.Get("Correlation-ID"))
+	assert.Contains(t, logs.String(), `"route":"/api/v1/things/{id}"`)
+	assert.Contains(t, svr.Routes(), server.RouteInfo{Path: "/api/v1/things/{id}", Methods: []string{"GET"}, Name: ""})
+
+	durations := recorder.DurationObservations()
+	if assert.Len(t, durations, 1) {
+		assert.Equal(t, "/api/v1/things/{id}", durations[0].Path)
+		assert.Equal(t, http.StatusOK, durations[0].Code)
+	}
+}
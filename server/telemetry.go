@@ -4,14 +4,40 @@ import (
 	"context"
 	"fmt"
 	"net/http"
+	"strconv"
+	"sync/atomic"
 	"time"
 
+	"github.com/google/uuid"
 	"github.com/gorilla/mux"
 	"github.com/pkg/errors"
 	"github.com/rs/zerolog"
 )
 
-const correlationHeader = "Correlation-Id"
+const (
+	correlationHeader  = "Correlation-Id"
+	serverTimingHeader = "Server-Timing"
+	metricRouteHeader  = "X-Metric-Route"
+
+	// logTimeField is separate from zerolog.TimestampFieldName, so loggers that already add a timestamp do not log
+	// the field twice
+	logTimeField = "log_time"
+
+	unmatchedRoute = "<unmatched>"
+)
+
+// CorrelationResponsePolicy controls when the correlation ID is written to the Correlation-Id response header.
+type CorrelationResponsePolicy int
+
+const (
+	// CorrelationAlways writes the correlation ID to every response.
+	CorrelationAlways CorrelationResponsePolicy = iota
+	// CorrelationOnlyGenerated writes the correlation ID only when the server generated it, so client-supplied IDs
+	// are never reflected back.
+	CorrelationOnlyGenerated
+	// CorrelationNever never writes the correlation ID to the response.
+	CorrelationNever
+)
 
 // Recorder defines functions for tracking HTTP-based metrics.
 type Recorder interface {
@@ -20,42 +46,214 @@ type Recorder interface {
 	ObserveHTTPResponseSize(method string, path string, code int, bytes int64)
 }
 
+func newSortableCorrelationID() string {
+	id, err := uuid.NewV7()
+	if err != nil {
+		return uuid.NewString()
+	}
+
+	return id.String()
+}
+
+// writeCorrelationHeader reports whether the correlation ID belongs in the response under the configured policy.
+func (s *Server) writeCorrelationHeader(generated bool) bool {
+	switch s.correlationResponse {
+	case CorrelationNever:
+		return false
+	case CorrelationOnlyGenerated:
+		return generated
+	default:
+		return true
+	}
+}
+
+type routeTemplateKey struct{}
+
+// RouteTemplateFromContext returns the path template of the route that matched the request, such as /things/{id}.
+func RouteTemplateFromContext(ctx context.Context) (string, bool) {
+	route, ok := ctx.Value(routeTemplateKey{}).(string)
+
+	return route, ok
+}
+
+// ContextRecorder is a Recorder that can label metrics with values from the request context.
+type ContextRecorder interface {
+	Recorder
+
+	// WithContext returns a Recorder for a single request.
+	WithContext(ctx context.Context) Recorder
+}
+
+// QueueRecorder is a Recorder that tracks how long requests wait for a slot under WithMaxConcurrentRequests.
+type QueueRecorder interface {
+	Recorder
+
+	ObserveHTTPQueueTime(method string, path string, duration time.Duration)
+}
+
+// FailureRecorder is a Recorder that tracks requests marked as failed with RecordFailure.
+type FailureRecorder interface {
+	Recorder
+
+	ObserveHTTPFailure(method string, path string, code int)
+}
+
+// ErrorRecorder is a Recorder that counts server error responses.
+type ErrorRecorder interface {
+	Recorder
+
+	ObserveHTTPError(method string, path string, code int)
+}
+
+// RegistrationRecorder is a Recorder that tracks how many routes and health dependencies the server has.
+type RegistrationRecorder interface {
+	Recorder
+
+	ObserveRoutesRegistered(count int)
+	ObserveHealthDependencies(count int)
+}
+
+// TLSRecorder is a Recorder that tracks the TLS handshakes of connections the server terminates itself.
+type TLSRecorder interface {
+	Recorder
+
+	ObserveTLSHandshake(version string, cipherSuite string)
+	ObserveTLSHandshakeError()
+}
+
 type telemetryWriter struct {
 	http.ResponseWriter
 
 	StatusCode int
 	Size       int
+
+	maxSize     int
+	truncated   bool
+	wroteHeader bool
+	onHeader    func(header http.Header)
+}
+
+// prepareHeader runs the header hook once, just before the response headers are sent.
+func (w *telemetryWriter) prepareHeader() {
+	if w.wroteHeader {
+		return
+	}
+
+	w.wroteHeader = true
+
+	if w.onHeader != nil {
+		w.onHeader(w.Header())
+	}
 }
 
 func (w *telemetryWriter) WriteHeader(statusCode int) {
+	if statusCode >= http.StatusOK {
+		w.prepareHeader()
+	}
+
 	w.StatusCode = statusCode
 	w.ResponseWriter.WriteHeader(statusCode)
 }
 
 func (w *telemetryWriter) Write(p []byte) (int, error) {
-	w.Size += len(p)
+	// Sniff the content type like net/http does on the first write, so it is known when logging
+	if _, ok := w.Header()["Content-Type"]; !ok && w.Size == 0 && len(p) > 0 {
+		w.Header().Set("Content-Type", http.DetectContentType(p))
+	}
+
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+
+	if w.maxSize > 0 && w.Size+len(p) > w.maxSize {
+		w.truncated = true
+
+		n, err := w.ResponseWriter.Write(p[:w.maxSize-w.Size])
+		w.Size += n
+
+		if err != nil {
+			return n, err //nolint: wrapcheck
+		}
+
+		return n, ErrResponseTooLarge
+	}
 
-	return w.ResponseWriter.Write(p) //nolint: wrapcheck
+	n, err := w.ResponseWriter.Write(p)
+	w.Size += n
+
+	return n, err //nolint: wrapcheck
+}
+
+func (w *telemetryWriter) Flush() {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+
+	_ = http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (w *telemetryWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
 }
 
 func (s *Server) telemetryMiddleware(recorder Recorder) mux.MiddlewareFunc { //nolint: funlen
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
 			start := time.Now()
+			failed := &atomic.Bool{}
+			request, autoHead := restoreHead(request)
 
-			path, err := mux.CurrentRoute(request).GetPathTemplate()
-			if err != nil {
-				path = request.URL.Path
+			route := routeTemplate(request)
+			path := s.metricPath(route)
+
+			// The built-in probes and scrapes are never cut off, since a cut would also corrupt a compressed body
+			maxSize := int(s.maxResponseSize)
+			if isOperationalPath(request.URL.Path) {
+				maxSize = 0
 			}
 
 			hijack := &telemetryWriter{
 				ResponseWriter: writer,
 				StatusCode:     http.StatusOK,
 				Size:           0,
+				maxSize:        maxSize,
+				truncated:      false,
+				wroteHeader:    false,
+				onHeader:       nil,
 			}
 
-			defer func(ctx context.Context) {
-				log := zerolog.Ctx(ctx)
+			if s.serverTiming || len(s.metricRoutes) > 0 {
+				hijack.onHeader = func(header http.Header) {
+					if s.serverTiming {
+						header.Set(serverTimingHeader, formatServerTiming(time.Since(start)))
+					}
+
+					if len(s.metricRoutes) > 0 {
+						path = s.metricRouteOverride(header, path)
+					}
+				}
+			}
+
+			if s.heartbeat != nil && !isOperationalPath(request.URL.Path) {
+				s.heartbeat.begin()
+				defer s.heartbeat.end()
+			}
+
+			scheme := s.requestScheme(request)
+
+			// Replaced by the context handed to the next handler, which holds the metric labels
+			metricCtx := request.Context()
+
+			// Set once the request's correlation ID and traceparent are known
+			requestLog := zerolog.Ctx(request.Context())
+			gcpTrace, gcpSpan := "", ""
+
+			defer func() {
+				log := requestLog
+				if s.gcpLogFields {
+					log = gcpLogger(log, s.gcpProjectID, gcpTrace, gcpSpan)
+				}
 
 				panicked := recover()
 				if panicked != nil {
@@ -64,44 +262,217 @@ func (s *Server) telemetryMiddleware(recorder Recorder) mux.MiddlewareFunc { //n
 						err = fmt.Errorf("%v", panicked) //nolint: err113
 					}
 
-					hijack.WriteHeader(http.StatusInternalServerError)
-					log.Error().Stack().Err(errors.Wrap(err, "panic")).Send()
+					// Once headers are sent the status cannot change, so the panic is only logged
+					if !hijack.wroteHeader {
+						hijack.WriteHeader(http.StatusInternalServerError)
+					}
+
+					log.Error().
+						Stack().
+						Err(errors.Wrap(err, "panic")).
+						Str("method", request.Method).
+						Str("path", path).
+						Str("url", request.URL.RequestURI()).
+						Send()
 				}
 
+				// Handlers that never write still get their headers sent once they return
+				hijack.prepareHeader()
+
 				duration := time.Since(start)
 
-				log.Info().
-					Str("method", request.Method).
-					Str("url", request.URL.RequestURI()).
-					Str("user_agent", request.UserAgent()).
-					Int("status_code", hijack.StatusCode).
-					Dur("duration_ms", duration).
-					Int("response_bytes", hijack.Size).
-					Msg("request complete")
+				if hijack.truncated {
+					failed.Store(true)
+					log.Warn().
+						Str("method", request.Method).
+						Str("url", request.URL.RequestURI()).
+						Int("max_response_bytes", hijack.maxSize).
+						Msg("response truncated")
+				}
+
+				if s.responseSizeWarning > 0 && int64(hijack.Size) > s.responseSizeWarning {
+					log.Warn().
+						Str("method", request.Method).
+						Str("url", request.URL.RequestURI()).
+						Str("route", route).
+						Int("response_bytes", hijack.Size).
+						Int64("warn_response_bytes", s.responseSizeWarning).
+						Msg("large response")
+				}
+
+				event := log.Info().Str(logTimeField, s.now().Format(s.logTimeFormat))
+
+				if s.gcpLogFields {
+					event = event.
+						Dict("httpRequest", gcpHTTPRequest(request, hijack.StatusCode, hijack.Size, duration)).
+						Str("route", route).
+						Str("content_type", hijack.Header().Get("Content-Type"))
+				} else {
+					event = event.
+						Str("method", request.Method).
+						Str("url", request.URL.RequestURI()).
+						Str("route", route).
+						Str("user_agent", request.UserAgent()).
+						Str("proto", request.Proto).
+						Str("scheme", scheme).
+						Int("status_code", hijack.StatusCode).
+						Str("content_type", hijack.Header().Get("Content-Type")).
+						Dur("duration_ms", duration).
+						Int("response_bytes", hijack.Size)
+				}
+
+				if failed.Load() {
+					event = event.Bool("failed", true)
+				}
+
+				event.Msg("request complete")
 
-				recorder.ObserveHTTPRequestDuration(request.Method, path, hijack.StatusCode, duration)
-				recorder.ObserveHTTPResponseSize(request.Method, path, hijack.StatusCode, int64(hijack.Size))
-			}(request.Context())
+				if s.commonLog != nil {
+					s.commonLog.write(request, start, hijack.StatusCode, hijack.Size)
+				}
+
+				// Scrapes are not observed so /metrics does not report on itself
+				if route == metricsEndpoint {
+					return
+				}
+
+				observer := observerFor(recorder, metricContext(metricCtx))
+				observer.ObserveHTTPRequestDuration(request.Method, path, hijack.StatusCode, duration)
+				observer.ObserveHTTPResponseSize(request.Method, path, hijack.StatusCode, int64(hijack.Size))
+
+				if failures, ok := observer.(FailureRecorder); ok && failed.Load() {
+					failures.ObserveHTTPFailure(request.Method, path, hijack.StatusCode)
+				}
+
+				if errs, ok := observer.(ErrorRecorder); ok && hijack.StatusCode >= http.StatusInternalServerError {
+					errs.ObserveHTTPError(request.Method, path, hijack.StatusCode)
+				}
+			}()
+
+			for key, value := range s.defaultHeaders {
+				hijack.Header().Set(key, value)
+			}
 
 			// Ensure the correlation ID is set up and passed through
 			correlationID := ""
+			generated := false
 
 			if s.readCorrelationHeader {
 				correlationID = request.Header.Get(correlationHeader)
 			}
 
-			if correlationID == "" {
+			if correlationID == "" && s.newCorrelationID != nil {
 				correlationID = s.newCorrelationID()
+				generated = true
+			}
+
+			if s.newCorrelationID != nil && s.writeCorrelationHeader(generated) {
+				hijack.Header().Add(correlationHeader, correlationID)
+			}
+
+			// Each request logs through its own copy, since the context logger is shared between requests
+			fields := requestLog.With()
+
+			if correlationID != "" {
+				fields = fields.Str("correlation_id", correlationID)
+			}
+
+			ctx := context.WithValue(request.Context(), failureKey{}, failed)
+			ctx = context.WithValue(ctx, metricLabelsKey{}, &atomic.Pointer[context.Context]{})
+			ctx = context.WithValue(ctx, schemeKey{}, scheme)
+
+			if route != unmatchedRoute {
+				ctx = context.WithValue(ctx, routeTemplateKey{}, route)
 			}
 
-			log := zerolog.Ctx(request.Context())
+			if s.traceparent {
+				traceparent := newTraceparent(request.Header.Get(traceparentHeader))
+
+				hijack.Header().Set(traceparentHeader, traceparent.String())
+				fields = fields.Str("trace_id", traceparent.TraceID).Str("span_id", traceparent.SpanID)
 
-			hijack.Header().Add(correlationHeader, correlationID)
-			log.UpdateContext(func(c zerolog.Context) zerolog.Context {
-				return c.Str("correlation_id", correlationID)
-			})
+				ctx = context.WithValue(ctx, traceparentKey{}, traceparent)
+				gcpTrace, gcpSpan = traceparent.TraceID, traceparent.SpanID
+			}
 
-			next.ServeHTTP(hijack, request.WithContext(log.WithContext(request.Context())))
+			log := fields.Logger()
+			requestLog = &log
+
+			if s.gcpLogFields {
+				ctx = gcpLogger(requestLog, s.gcpProjectID, gcpTrace, gcpSpan).WithContext(ctx)
+			} else {
+				ctx = requestLog.WithContext(ctx)
+			}
+
+			if s.startRequestLog {
+				zerolog.Ctx(ctx).Debug().
+					Str(logTimeField, s.now().Format(s.logTimeFormat)).
+					Str("method", request.Method).
+					Str("url", request.URL.RequestURI()).
+					Str("route", route).
+					Msg("request started")
+			}
+
+			var response http.ResponseWriter = hijack
+			if autoHead {
+				response = &headWriter{ResponseWriter: hijack}
+			}
+
+			metricCtx = ctx
+
+			next.ServeHTTP(response, request.WithContext(ctx))
 		})
 	}
 }
+
+// logEnabled reports whether the request logger writes events at the given level, so log fields that are costly to
+// build can be skipped when they would be thrown away.
+func logEnabled(ctx context.Context, level zerolog.Level) bool {
+	return level >= zerolog.Ctx(ctx).GetLevel() && level >= zerolog.GlobalLevel()
+}
+
+// metricRouteOverride strips the metric route header from a response, returning its value as the path label if it is
+// one of the allowed metric routes.
+func (s *Server) metricRouteOverride(header http.Header, path string) string {
+	value := header.Get(metricRouteHeader)
+	header.Del(metricRouteHeader)
+
+	if !s.metricRoutes[value] {
+		return path
+	}
+
+	return value
+}
+
+// routeTemplate returns the matched route path template, or unmatchedRoute if there is none.
+func routeTemplate(request *http.Request) string {
+	route, err := mux.CurrentRoute(request).GetPathTemplate()
+	if err != nil {
+		return unmatchedRoute
+	}
+
+	return route
+}
+
+// metricPath returns the path label for a route. Raw URL paths would give metrics unbounded cardinality, so
+// unmatched requests share one label.
+func (s *Server) metricPath(route string) string {
+	if route == unmatchedRoute {
+		return s.unmatchedPathLabel
+	}
+
+	return route
+}
+
+// observerFor binds a ContextRecorder to the request context.
+func observerFor(recorder Recorder, ctx context.Context) Recorder {
+	if contextual, ok := recorder.(ContextRecorder); ok {
+		return contextual.WithContext(ctx)
+	}
+
+	return recorder
+}
+
+func formatServerTiming(duration time.Duration) string {
+	return "total;dur=" + strconv.FormatFloat(float64(duration)/float64(time.Millisecond), 'f', 3, 64)
+}
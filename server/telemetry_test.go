@@ -0,0 +1,769 @@
+package server_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/b-sea/go-server/server"
+	"github.com/gorilla/mux"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServerTimingHeader(t *testing.T) {
+	type testCase struct {
+		option  server.Option
+		path    string
+		present bool
+	}
+
+	tests := map[string]testCase{
+		"disabled": {
+			option:  nil,
+			path:    "/write",
+			present: false,
+		},
+		"written body": {
+			option:  server.WithServerTimingHeader(),
+			path:    "/write",
+			present: true,
+		},
+		"empty body": {
+			option:  server.WithServerTimingHeader(),
+			path:    "/empty",
+			present: true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			options := []server.Option{}
+			if test.option != nil {
+				options = append(options, test.option)
+			}
+
+			svr := server.New(context.Background(), &server.NoOpRecorder{}, options...)
+			svr.Router().Handle(
+				"/write",
+				func() http.HandlerFunc {
+					return func(writer http.ResponseWriter, _ *http.Request) {
+						time.Sleep(10 * time.Millisecond)
+
+						_, _ = writer.Write([]byte(`ok`))
+					}
+				}(),
+			).Methods(http.MethodGet)
+			svr.Router().Handle(
+				"/empty",
+				func() http.HandlerFunc {
+					return func(http.ResponseWriter, *http.Request) {
+						time.Sleep(10 * time.Millisecond)
+					}
+				}(),
+			).Methods(http.MethodGet)
+
+			testServer := httptest.NewServer(svr)
+
+			request, _ := http.NewRequestWithContext(context.Background(), http.MethodGet, testServer.URL+test.path, nil)
+			request.Close = true
+
+			response, err := http.DefaultClient.Do(request)
+			assert.NoError(t, err)
+			assert.NoError(t, response.Body.Close())
+
+			testServer.Close()
+
+			timing := response.Header.Get("Server-Timing")
+			if !test.present {
+				assert.Empty(t, timing)
+
+				return
+			}
+
+			assert.Regexp(t, `^total;dur=\d+\.\d{3}$`, timing)
+
+			duration, err := strconv.ParseFloat(strings.TrimPrefix(timing, "total;dur="), 64)
+			assert.NoError(t, err)
+			assert.GreaterOrEqual(t, duration, 10.0)
+			assert.Less(t, duration, 5000.0)
+		})
+	}
+}
+
+func TestRequestLogRoute(t *testing.T) {
+	type testCase struct {
+		path  string
+		route string
+	}
+
+	tests := map[string]testCase{
+		"template": {
+			path:  "/things/123",
+			route: "/things/{id}",
+		},
+		"built-in": {
+			path:  "/ping",
+			route: "/ping",
+		},
+		"unmatched": {
+			path:  "/missing",
+			route: "<unmatched>",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			var buffer bytes.Buffer
+
+			svr := server.New(context.Background(), &server.NoOpRecorder{})
+			svr.Router().Handle("/things/{id}", http.NotFoundHandler()).Methods(http.MethodGet)
+
+			testServer := httptest.NewServer(withLogger(svr, zerolog.New(&buffer)))
+
+			request, _ := http.NewRequestWithContext(context.Background(), http.MethodGet, testServer.URL+test.path, nil)
+			request.Close = true
+
+			response, err := http.DefaultClient.Do(request)
+			assert.NoError(t, err)
+			assert.NoError(t, response.Body.Close())
+
+			testServer.Close()
+
+			assert.Contains(t, buffer.String(), `"route":"`+test.route+`"`)
+		})
+	}
+}
+
+func TestMetricsScrapeNotObserved(t *testing.T) {
+	recorder := &SpyRecorder{}
+	testServer := httptest.NewServer(server.New(context.Background(), recorder))
+
+	for _, path := range []string{"/metrics", "/ping"} {
+		request, _ := http.NewRequestWithContext(context.Background(), http.MethodGet, testServer.URL+path, nil)
+		request.Close = true
+
+		response, err := http.DefaultClient.Do(request)
+		assert.NoError(t, err)
+		assert.NoError(t, response.Body.Close())
+
+		assert.NotEmpty(t, response.Header.Get("Correlation-ID"))
+	}
+
+	testServer.Close()
+
+	observations := recorder.DurationObservations()
+	if assert.Len(t, observations, 1) {
+		assert.Equal(t, "/ping", observations[0].Path)
+	}
+
+	assert.Len(t, recorder.SizeObservations(), 1)
+}
+
+func TestUnmatchedPathLabel(t *testing.T) {
+	type testCase struct {
+		option server.Option
+		label  string
+	}
+
+	tests := map[string]testCase{
+		"default": {
+			option: nil,
+			label:  "<unmatched>",
+		},
+		"custom": {
+			option: server.WithUnmatchedPathLabel("other"),
+			label:  "other",
+		},
+		"empty ignored": {
+			option: server.WithUnmatchedPathLabel(""),
+			label:  "<unmatched>",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			recorder := &SpyRecorder{}
+
+			options := []server.Option{}
+			if test.option != nil {
+				options = append(options, test.option)
+			}
+
+			svr := server.New(context.Background(), recorder, options...)
+			svr.Router().NewRoute().MatcherFunc(func(request *http.Request, _ *mux.RouteMatch) bool {
+				return request.Header.Get("X-Templateless") != ""
+			}).Handler(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+
+			testServer := httptest.NewServer(svr)
+
+			for i, path := range []string{"/scanner/1", "/scanner/2", "/templateless/3"} {
+				request, _ := http.NewRequestWithContext(context.Background(), http.MethodGet, testServer.URL+path, nil)
+				request.Close = true
+
+				if i == 2 {
+					request.Header.Set("X-Templateless", "yes")
+				}
+
+				response, err := http.DefaultClient.Do(request)
+				assert.NoError(t, err)
+				assert.NoError(t, response.Body.Close())
+			}
+
+			testServer.Close()
+
+			observations := recorder.DurationObservations()
+			if assert.Len(t, observations, 3) {
+				assert.Equal(t, test.label, observations[0].Path)
+				assert.Equal(t, test.label, observations[1].Path)
+				assert.Equal(t, test.label, observations[2].Path)
+				assert.Equal(t, http.StatusOK, observations[2].Code)
+			}
+		})
+	}
+}
+
+func TestRequestLogContentType(t *testing.T) {
+	type testCase struct {
+		path        string
+		contentType string
+	}
+
+	tests := map[string]testCase{
+		"json": {
+			path:        "/json",
+			contentType: "application/json",
+		},
+		"plain": {
+			path:        "/ping",
+			contentType: "text/plain; charset=utf-8",
+		},
+		"empty": {
+			path:        "/empty",
+			contentType: "",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			var buffer bytes.Buffer
+
+			svr := server.New(context.Background(), &server.NoOpRecorder{})
+			svr.Router().Handle("/json", http.HandlerFunc(func(writer http.ResponseWriter, _ *http.Request) {
+				_ = svr.WriteJSON(writer, http.StatusOK, map[string]string{"hello": "there"})
+			})).Methods(http.MethodGet)
+			svr.Router().Handle("/empty", http.HandlerFunc(func(writer http.ResponseWriter, _ *http.Request) {
+				writer.WriteHeader(http.StatusNoContent)
+			})).Methods(http.MethodGet)
+
+			testServer := httptest.NewServer(withLogger(svr, zerolog.New(&buffer)))
+
+			request, _ := http.NewRequestWithContext(context.Background(), http.MethodGet, testServer.URL+test.path, nil)
+			request.Close = true
+
+			response, err := http.DefaultClient.Do(request)
+			assert.NoError(t, err)
+			assert.NoError(t, response.Body.Close())
+
+			testServer.Close()
+
+			assert.Contains(t, buffer.String(), `"content_type":"`+test.contentType+`"`)
+		})
+	}
+}
+
+func TestMetricRouteHeader(t *testing.T) {
+	type testCase struct {
+		value string
+		path  string
+	}
+
+	tests := map[string]testCase{
+		"allowed": {
+			value: "get_user",
+			path:  "get_user",
+		},
+		"not allowed": {
+			value: "user_12345",
+			path:  "/users/{id}",
+		},
+		"not set": {
+			value: "",
+			path:  "/users/{id}",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			recorder := &SpyRecorder{}
+
+			svr := server.New(context.Background(), recorder, server.WithMetricRouteHeader("get_user", "list_users"))
+			svr.Router().Handle("/users/{id}", http.HandlerFunc(func(writer http.ResponseWriter, _ *http.Request) {
+				if test.value != "" {
+					writer.Header().Set("X-Metric-Route", test.value)
+				}
+
+				_, _ = writer.Write([]byte(`a user`))
+			})).Methods(http.MethodGet)
+
+			testServer := httptest.NewServer(svr)
+
+			request, _ := http.NewRequestWithContext(context.Background(), http.MethodGet, testServer.URL+"/users/1", nil)
+			request.Close = true
+
+			response, err := http.DefaultClient.Do(request)
+			assert.NoError(t, err)
+			assert.NoError(t, response.Body.Close())
+
+			testServer.Close()
+
+			assert.Empty(t, response.Header.Get("X-Metric-Route"))
+
+			observations := recorder.DurationObservations()
+			if assert.Len(t, observations, 1) {
+				assert.Equal(t, test.path, observations[0].Path)
+			}
+
+			sizes := recorder.SizeObservations()
+			if assert.Len(t, sizes, 1) {
+				assert.Equal(t, test.path, sizes[0].Path)
+			}
+		})
+	}
+}
+
+func TestStartRequestLog(t *testing.T) {
+	type testCase struct {
+		option   server.Option
+		messages []string
+	}
+
+	tests := map[string]testCase{
+		"enabled": {
+			option:   server.WithStartRequestLog(),
+			messages: []string{"request started", "request complete"},
+		},
+		"disabled": {
+			option:   nil,
+			messages: []string{"request complete"},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			var buffer bytes.Buffer
+
+			options := []server.Option{}
+			if test.option != nil {
+				options = append(options, test.option)
+			}
+
+			svr := server.New(context.Background(), &server.NoOpRecorder{}, options...)
+			testServer := httptest.NewServer(withLogger(svr, zerolog.New(&buffer)))
+
+			request, _ := http.NewRequestWithContext(context.Background(), http.MethodGet, testServer.URL+"/ping", nil)
+			request.Close = true
+
+			response, err := http.DefaultClient.Do(request)
+			assert.NoError(t, err)
+			assert.NoError(t, response.Body.Close())
+
+			testServer.Close()
+
+			messages := []string{}
+			decoder := json.NewDecoder(&buffer)
+
+			for decoder.More() {
+				var entry struct {
+					Message       string `json:"message"`
+					Method        string `json:"method"`
+					URL           string `json:"url"`
+					CorrelationID string `json:"correlation_id"`
+				}
+
+				assert.NoError(t, decoder.Decode(&entry))
+
+				messages = append(messages, entry.Message)
+
+				assert.Equal(t, http.MethodGet, entry.Method)
+				assert.Equal(t, "/ping", entry.URL)
+				assert.Equal(t, response.Header.Get("Correlation-Id"), entry.CorrelationID)
+			}
+
+			assert.Equal(t, test.messages, messages)
+		})
+	}
+}
+
+func TestRouteTemplateFromContext(t *testing.T) {
+	type testCase struct {
+		path  string
+		route string
+		ok    bool
+	}
+
+	tests := map[string]testCase{
+		"template": {
+			path:  "/things/123",
+			route: "/things/{id}",
+			ok:    true,
+		},
+		"no template": {
+			path:  "/missing",
+			route: "",
+			ok:    false,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			route := ""
+			ok := false
+
+			handler := http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+				route, ok = server.RouteTemplateFromContext(request.Context())
+
+				writer.WriteHeader(http.StatusTeapot)
+			})
+
+			svr := server.New(context.Background(), &server.NoOpRecorder{})
+			svr.Router().Handle("/things/{id}", handler).Methods(http.MethodGet)
+			svr.Router().Headers("X-Test", "yes").Handler(handler)
+
+			testServer := httptest.NewServer(svr)
+
+			request, _ := http.NewRequestWithContext(context.Background(), http.MethodGet, testServer.URL+test.path, nil)
+			request.Header.Set("X-Test", "yes")
+			request.Close = true
+
+			response, err := http.DefaultClient.Do(request)
+			assert.NoError(t, err)
+			assert.NoError(t, response.Body.Close())
+
+			testServer.Close()
+
+			assert.Equal(t, http.StatusTeapot, response.StatusCode)
+			assert.Equal(t, test.route, route)
+			assert.Equal(t, test.ok, ok)
+		})
+	}
+}
+
+func TestRequestLogTimeFormat(t *testing.T) {
+	type testCase struct {
+		option    server.Option
+		timestamp bool
+		expected  string
+	}
+
+	tests := map[string]testCase{
+		"default": {
+			option:    nil,
+			timestamp: false,
+			expected:  "2025-01-01T00:00:00Z",
+		},
+		"custom": {
+			option:    server.WithLogTimeFormat(time.RFC1123),
+			timestamp: false,
+			expected:  "Wed, 01 Jan 2025 00:00:00 UTC",
+		},
+		"empty": {
+			option:    server.WithLogTimeFormat(""),
+			timestamp: false,
+			expected:  "2025-01-01T00:00:00Z",
+		},
+		"timestamped logger": {
+			option:    server.WithStartRequestLog(),
+			timestamp: true,
+			expected:  "2025-01-01T00:00:00Z",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			var buffer bytes.Buffer
+
+			clock := NewFakeClock()
+			options := []server.Option{server.WithClock(clock.Now)}
+
+			if test.option != nil {
+				options = append(options, test.option)
+			}
+
+			logger := zerolog.New(&buffer).Level(zerolog.DebugLevel)
+			if test.timestamp {
+				logger = logger.With().Timestamp().Logger()
+			}
+
+			svr := server.New(context.Background(), &server.NoOpRecorder{}, options...)
+			testServer := httptest.NewServer(withLogger(svr, logger))
+
+			request, _ := http.NewRequestWithContext(context.Background(), http.MethodGet, testServer.URL+"/ping", nil)
+			request.Close = true
+
+			response, err := http.DefaultClient.Do(request)
+			assert.NoError(t, err)
+			assert.NoError(t, response.Body.Close())
+
+			testServer.Close()
+
+			for line := range strings.SplitSeq(strings.TrimSpace(buffer.String()), "\n") {
+				var entry struct {
+					LogTime string `json:"log_time"`
+				}
+
+				assert.NoError(t, json.Unmarshal([]byte(line), &entry))
+				assert.Equal(t, test.expected, entry.LogTime)
+
+				// A repeated key is invalid for many log pipelines
+				timestamps := strings.Count(line, `"time":`)
+				if test.timestamp {
+					assert.Equal(t, 1, timestamps, line)
+				} else {
+					assert.Zero(t, timestamps, line)
+				}
+			}
+		})
+	}
+}
+
+func TestGCPLogFields(t *testing.T) {
+	type testCase struct {
+		options       []server.Option
+		expectedTrace string
+	}
+
+	tests := map[string]testCase{
+		"no traceparent": {
+			options:       []server.Option{server.WithGCPLogFields("my-project")},
+			expectedTrace: "",
+		},
+		"traceparent": {
+			options:       []server.Option{server.WithGCPLogFields("my-project"), server.WithTraceparent()},
+			expectedTrace: "projects/my-project/traces/4bf92f3577b34da6a3ce929d0e0e4736",
+		},
+		"no project": {
+			options:       []server.Option{server.WithGCPLogFields(""), server.WithTraceparent()},
+			expectedTrace: "",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			var buffer safeBuffer
+
+			svr := server.New(
+				context.Background(),
+				&server.NoOpRecorder{},
+				append(
+					[]server.Option{
+						server.WithCustomCorrelationID(func() string { return "correlation-id" }),
+					},
+					test.options...,
+				)...,
+			)
+			svr.Router().Handle("/test", http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+				zerolog.Ctx(request.Context()).Warn().Msg("from handler")
+				writer.WriteHeader(http.StatusAccepted)
+			})).Methods(http.MethodPost)
+
+			testServer := httptest.NewServer(withLogger(svr, zerolog.New(&buffer)))
+
+			request, _ := http.NewRequestWithContext(context.Background(), http.MethodPost, testServer.URL+"/test?a=1", nil)
+			request.Close = true
+			request.Header.Set("User-Agent", "test-agent")
+			request.Header.Set("Traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+
+			response, err := http.DefaultClient.Do(request)
+			assert.NoError(t, err)
+			assert.NoError(t, response.Body.Close())
+
+			testServer.Close()
+
+			type entry struct {
+				Message     string `json:"message"`
+				Severity    string `json:"severity"`
+				Trace       string `json:"logging.googleapis.com/trace"`
+				StatusCode  *int   `json:"status_code"`
+				HTTPRequest struct {
+					RequestMethod string `json:"requestMethod"`
+					RequestURL    string `json:"requestUrl"`
+					Status        int    `json:"status"`
+					UserAgent     string `json:"userAgent"`
+					ResponseSize  string `json:"responseSize"`
+					RemoteIP      string `json:"remoteIp"`
+					Latency       string `json:"latency"`
+				} `json:"httpRequest"`
+			}
+
+			lines := strings.Split(strings.TrimSpace(buffer.String()), "\n")
+			if !assert.Len(t, lines, 2) {
+				return
+			}
+
+			var handlerLog, requestLog entry
+
+			assert.NoError(t, json.Unmarshal([]byte(lines[0]), &handlerLog))
+			assert.NoError(t, json.Unmarshal([]byte(lines[1]), &requestLog))
+
+			assert.Equal(t, "from handler", handlerLog.Message)
+			assert.Equal(t, "WARNING", handlerLog.Severity)
+			assert.Equal(t, test.expectedTrace, handlerLog.Trace)
+
+			assert.Equal(t, "request complete", requestLog.Message)
+			assert.Equal(t, "INFO", requestLog.Severity)
+			assert.Equal(t, test.expectedTrace, requestLog.Trace)
+			assert.Nil(t, requestLog.StatusCode)
+			assert.Equal(t, http.MethodPost, requestLog.HTTPRequest.RequestMethod)
+			assert.Equal(t, "/test?a=1", requestLog.HTTPRequest.RequestURL)
+			assert.Equal(t, http.StatusAccepted, requestLog.HTTPRequest.Status)
+			assert.Equal(t, "test-agent", requestLog.HTTPRequest.UserAgent)
+			assert.Equal(t, "0", requestLog.HTTPRequest.ResponseSize)
+			assert.Equal(t, "127.0.0.1", requestLog.HTTPRequest.RemoteIP)
+			assert.True(t, strings.HasSuffix(requestLog.HTTPRequest.Latency, "s"))
+		})
+	}
+}
+
+func BenchmarkRequestLogging(b *testing.B) {
+	levels := map[string]zerolog.Level{
+		"debug": zerolog.DebugLevel,
+		"warn":  zerolog.WarnLevel,
+	}
+
+	for name, level := range levels {
+		for _, path := range []string{"/ping", "/health"} {
+			b.Run(name+path, func(b *testing.B) {
+				svr := server.New(
+					context.Background(),
+					&server.NoOpRecorder{},
+					server.WithStartRequestLog(),
+					server.WithHealthDependencies(map[string]server.HealthChecker{
+						"database": &HealthCheck{},
+						"cache":    &HealthCheck{},
+						"queue":    &HealthCheck{},
+						"search":   &HealthCheck{},
+					}),
+				)
+				handler := withLogger(svr, zerolog.New(io.Discard).Level(level))
+
+				b.ReportAllocs()
+
+				for b.Loop() {
+					handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+				}
+			})
+		}
+	}
+}
+
+func TestHTTP10Request(t *testing.T) {
+	var logs safeBuffer
+
+	recorder := &SpyRecorder{}
+	testServer := httptest.NewServer(withLogger(server.New(context.Background(), recorder), zerolog.New(&logs)))
+
+	conn, err := net.Dial("tcp", testServer.Listener.Addr().String())
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	// No Host header or keep-alive, as sent by legacy clients
+	_, err = conn.Write([]byte("GET /ping HTTP/1.0\r\n\r\n"))
+	assert.NoError(t, err)
+
+	// The server closes the connection after responding, so reading to EOF ends
+	response, err := io.ReadAll(conn)
+	assert.NoError(t, err)
+	assert.NoError(t, conn.Close())
+
+	testServer.Close()
+
+	assert.True(t, strings.HasPrefix(string(response), "HTTP/1.0 200 OK\r\n"))
+	assert.True(t, strings.HasSuffix(string(response), "\r\n\r\npong"))
+
+	var entry struct {
+		Proto      string `json:"proto"`
+		Route      string `json:"route"`
+		StatusCode int    `json:"status_code"`
+	}
+
+	assert.NoError(t, json.Unmarshal([]byte(logs.String()), &entry))
+	assert.Equal(t, "HTTP/1.0", entry.Proto)
+	assert.Equal(t, "/ping", entry.Route)
+	assert.Equal(t, http.StatusOK, entry.StatusCode)
+
+	durations := recorder.DurationObservations()
+	if assert.Len(t, durations, 1) {
+		assert.Equal(t, "/ping", durations[0].Path)
+		assert.Equal(t, http.StatusOK, durations[0].Code)
+	}
+}
+
+func TestConcurrentRequestLogs(t *testing.T) {
+	const requests = 50
+
+	var logs safeBuffer
+
+	// A logger with existing fields has spare capacity that concurrent requests could otherwise write into
+	log := zerolog.New(&logs).With().Str("service", "test").Logger()
+	testServer := httptest.NewServer(withLogger(server.New(context.Background(), &server.NoOpRecorder{}), log))
+
+	ids := make([]string, requests)
+	wg := sync.WaitGroup{}
+
+	for i := range requests {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			request, _ := http.NewRequestWithContext(
+				context.Background(),
+				http.MethodGet,
+				testServer.URL+"/ping?n="+strconv.Itoa(i),
+				nil,
+			)
+			request.Close = true
+
+			response, err := http.DefaultClient.Do(request)
+			if !assert.NoError(t, err) {
+				return
+			}
+
+			assert.NoError(t, response.Body.Close())
+
+			ids[i] = response.Header.Get("Correlation-ID")
+		}()
+	}
+
+	wg.Wait()
+	testServer.Close()
+
+	lines := strings.Split(strings.TrimSpace(logs.String()), "\n")
+	assert.Len(t, lines, requests)
+
+	for _, line := range lines {
+		var entry struct {
+			CorrelationID string `json:"correlation_id"`
+			URL           string `json:"url"`
+		}
+
+		assert.NoError(t, json.Unmarshal([]byte(line), &entry))
+		assert.Equal(t, 1, strings.Count(line, `"correlation_id"`), line)
+
+		n, err := strconv.Atoi(strings.TrimPrefix(entry.URL, "/ping?n="))
+		if assert.NoError(t, err) {
+			assert.Equal(t, ids[n], entry.CorrelationID)
+		}
+	}
+}
@@ -0,0 +1,171 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"maps"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// writeDeadlineMarginDivisor sets how much of the write timeout is left for writing the response once the request
+// context deadline passes, as a fraction of the write timeout.
+const writeDeadlineMarginDivisor = 10
+
+func (s *Server) requestTimeoutFor(request *http.Request) time.Duration {
+	path, err := mux.CurrentRoute(request).GetPathTemplate()
+	if err != nil {
+		return s.requestTimeout
+	}
+
+	if timeout, ok := s.requestTimeouts[path]; ok {
+		return timeout
+	}
+
+	return s.requestTimeout
+}
+
+// writeDeadlineContextMiddleware gives each request a context deadline shortly before the server write timeout, so
+// context aware handlers can stop and respond while the response can still be written.
+func (s *Server) writeDeadlineContextMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		timeout := s.http.WriteTimeout
+		if timeout <= 0 {
+			next.ServeHTTP(writer, request)
+
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(request.Context(), timeout-timeout/writeDeadlineMarginDivisor)
+		defer cancel()
+
+		next.ServeHTTP(writer, request.WithContext(ctx))
+	})
+}
+
+func (s *Server) timeoutMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		timeout := s.requestTimeoutFor(request)
+		if timeout <= 0 {
+			next.ServeHTTP(writer, request)
+
+			return
+		}
+
+		if s.jsonTimeoutCode != 0 {
+			s.jsonTimeoutHandler(next, timeout).ServeHTTP(writer, request)
+
+			return
+		}
+
+		http.TimeoutHandler(next, timeout, "").ServeHTTP(writer, request)
+	})
+}
+
+// timeoutWriter buffers a response until the handler finishes, so it can be thrown away if the handler times out.
+type timeoutWriter struct {
+	mu          sync.Mutex
+	header      http.Header
+	body        bytes.Buffer
+	code        int
+	wroteHeader bool
+	timedOut    bool
+}
+
+func (w *timeoutWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *timeoutWriter) WriteHeader(code int) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.timedOut || w.wroteHeader {
+		return
+	}
+
+	w.wroteHeader = true
+	w.code = code
+}
+
+func (w *timeoutWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.timedOut {
+		return 0, http.ErrHandlerTimeout
+	}
+
+	if !w.wroteHeader {
+		w.wroteHeader = true
+		w.code = http.StatusOK
+	}
+
+	return w.body.Write(p) //nolint: wrapcheck
+}
+
+// jsonTimeoutHandler works like http.TimeoutHandler, but answers a timed out request with a JSON error that carries
+// the request correlation ID.
+func (s *Server) jsonTimeoutHandler(next http.Handler, timeout time.Duration) http.Handler {
+	return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		ctx, cancel := context.WithTimeout(request.Context(), timeout)
+		defer cancel()
+
+		buffered := &timeoutWriter{
+			mu:          sync.Mutex{},
+			header:      make(http.Header),
+			body:        bytes.Buffer{},
+			code:        http.StatusOK,
+			wroteHeader: false,
+			timedOut:    false,
+		}
+
+		done := make(chan struct{})
+		panicked := make(chan any, 1)
+
+		go func() {
+			defer func() {
+				if p := recover(); p != nil {
+					panicked <- p
+				}
+			}()
+
+			next.ServeHTTP(buffered, request.WithContext(ctx))
+			close(done)
+		}()
+
+		select {
+		case p := <-panicked:
+			panic(p)
+		case <-done:
+			buffered.mu.Lock()
+			defer buffered.mu.Unlock()
+
+			maps.Copy(writer.Header(), buffered.header)
+			writer.WriteHeader(buffered.code)
+			_, _ = writer.Write(buffered.body.Bytes())
+		case <-ctx.Done():
+			buffered.mu.Lock()
+			buffered.timedOut = true
+			buffered.mu.Unlock()
+
+			if !errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				writer.WriteHeader(http.StatusServiceUnavailable)
+
+				return
+			}
+
+			_ = s.WriteJSON(writer, s.jsonTimeoutCode, struct {
+				Error         string `json:"error"`
+				CorrelationID string `json:"correlation_id,omitempty"`
+			}{
+				Error:         "request timeout",
+				CorrelationID: writer.Header().Get(correlationHeader),
+			})
+		}
+	})
+}
@@ -0,0 +1,274 @@
+package server_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/b-sea/go-server/server"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequestTimeout(t *testing.T) {
+	type testCase struct {
+		options    []server.Option
+		path       string
+		result     string
+		statusCode int
+	}
+
+	slow := func() http.HandlerFunc {
+		return func(writer http.ResponseWriter, request *http.Request) {
+			select {
+			case <-time.After(100 * time.Millisecond):
+				_, _ = writer.Write([]byte(`done`))
+			case <-request.Context().Done():
+			}
+		}
+	}
+
+	tests := map[string]testCase{
+		"no timeout": {
+			options:    nil,
+			path:       "/lookup",
+			result:     "done",
+			statusCode: http.StatusOK,
+		},
+		"global timeout": {
+			options:    []server.Option{server.WithRequestTimeout(20 * time.Millisecond)},
+			path:       "/lookup",
+			result:     "<html><head><title>Timeout</title></head><body><h1>Timeout</h1></body></html>",
+			statusCode: http.StatusServiceUnavailable,
+		},
+		"path override longer": {
+			options: []server.Option{
+				server.WithRequestTimeout(20 * time.Millisecond),
+				server.WithRequestTimeouts(map[string]time.Duration{"/export/{id}": time.Second}),
+			},
+			path:       "/export/123",
+			result:     "done",
+			statusCode: http.StatusOK,
+		},
+		"path override shorter": {
+			options: []server.Option{
+				server.WithRequestTimeouts(map[string]time.Duration{"/lookup": 20 * time.Millisecond}),
+			},
+			path:       "/lookup",
+			result:     "<html><head><title>Timeout</title></head><body><h1>Timeout</h1></body></html>",
+			statusCode: http.StatusServiceUnavailable,
+		},
+		"json timeout": {
+			options: []server.Option{
+				server.WithRequestTimeout(20 * time.Millisecond),
+				server.WithJSONTimeout(0),
+				server.WithCustomCorrelationID(func() string { return "correlation-id" }),
+			},
+			path:       "/lookup",
+			result:     "{\"error\":\"request timeout\",\"correlation_id\":\"correlation-id\"}\n",
+			statusCode: http.StatusGatewayTimeout,
+		},
+		"json timeout custom code": {
+			options: []server.Option{
+				server.WithRequestTimeout(20 * time.Millisecond),
+				server.WithJSONTimeout(http.StatusServiceUnavailable),
+				server.WithoutCorrelationID(),
+			},
+			path:       "/lookup",
+			result:     "{\"error\":\"request timeout\"}\n",
+			statusCode: http.StatusServiceUnavailable,
+		},
+		"json timeout invalid code": {
+			options: []server.Option{
+				server.WithRequestTimeout(20 * time.Millisecond),
+				server.WithJSONTimeout(http.StatusOK),
+			},
+			path:       "/lookup",
+			result:     "<html><head><title>Timeout</title></head><body><h1>Timeout</h1></body></html>",
+			statusCode: http.StatusServiceUnavailable,
+		},
+		"json timeout not reached": {
+			options: []server.Option{
+				server.WithRequestTimeout(time.Second),
+				server.WithJSONTimeout(0),
+			},
+			path:       "/lookup",
+			result:     "done",
+			statusCode: http.StatusOK,
+		},
+		"other path unaffected": {
+			options: []server.Option{
+				server.WithRequestTimeouts(map[string]time.Duration{"/lookup": 20 * time.Millisecond}),
+			},
+			path:       "/export/123",
+			result:     "done",
+			statusCode: http.StatusOK,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			svr := server.New(context.Background(), &server.NoOpRecorder{}, test.options...)
+			svr.Router().Handle("/lookup", slow()).Methods(http.MethodGet)
+			svr.Router().Handle("/export/{id}", slow()).Methods(http.MethodGet)
+
+			testServer := httptest.NewServer(svr)
+
+			request, _ := http.NewRequestWithContext(context.Background(), http.MethodGet, testServer.URL+test.path, nil)
+			request.Close = true
+
+			response, err := http.DefaultClient.Do(request)
+			assert.NoError(t, err)
+
+			body, err := io.ReadAll(response.Body)
+			assert.NoError(t, err)
+
+			assert.NoError(t, response.Body.Close())
+
+			assert.Equal(t, test.statusCode, response.StatusCode)
+			assert.Equal(t, test.result, string(body))
+
+			testServer.Close()
+		})
+	}
+}
+
+func TestWriteDeadlineContext(t *testing.T) {
+	type testCase struct {
+		options      []server.Option
+		hasDeadline  bool
+		expectedLeft time.Duration
+	}
+
+	tests := map[string]testCase{
+		"disabled": {
+			options:      nil,
+			hasDeadline:  false,
+			expectedLeft: 0,
+		},
+		"default write timeout": {
+			options:      []server.Option{server.WithWriteDeadlineContext()},
+			hasDeadline:  true,
+			expectedLeft: 4500 * time.Millisecond,
+		},
+		"custom write timeout": {
+			options:      []server.Option{server.WithWriteDeadlineContext(), server.WithWriteTimeout(10 * time.Second)},
+			hasDeadline:  true,
+			expectedLeft: 9 * time.Second,
+		},
+		"no write timeout": {
+			options:      []server.Option{server.WithWriteDeadlineContext(), server.WithWriteTimeout(0)},
+			hasDeadline:  false,
+			expectedLeft: 0,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			var (
+				deadline    time.Time
+				hasDeadline bool
+			)
+
+			svr := server.New(context.Background(), &server.NoOpRecorder{}, test.options...)
+			svr.Router().Handle("/test", http.HandlerFunc(func(_ http.ResponseWriter, request *http.Request) {
+				deadline, hasDeadline = request.Context().Deadline()
+			})).Methods(http.MethodGet)
+
+			start := time.Now()
+
+			svr.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/test", nil))
+
+			assert.Equal(t, test.hasDeadline, hasDeadline)
+
+			if test.hasDeadline {
+				assert.WithinDuration(t, start.Add(test.expectedLeft), deadline, 100*time.Millisecond)
+			}
+		})
+	}
+}
+
+func TestJSONTimeoutStatus(t *testing.T) {
+	svr := server.New(
+		context.Background(),
+		&server.NoOpRecorder{},
+		server.WithRequestTimeout(time.Second),
+		server.WithJSONTimeout(0),
+	)
+	svr.Router().Handle("/orders", http.HandlerFunc(func(writer http.ResponseWriter, _ *http.Request) {
+		writer.Header().Set("Location", "/orders/1")
+		writer.WriteHeader(http.StatusCreated)
+		writer.WriteHeader(http.StatusInternalServerError)
+
+		_, _ = writer.Write([]byte(`created`))
+	})).Methods(http.MethodPost)
+
+	response := httptest.NewRecorder()
+	svr.ServeHTTP(response, httptest.NewRequest(http.MethodPost, "/orders", nil))
+
+	assert.Equal(t, http.StatusCreated, response.Code)
+	assert.Equal(t, "/orders/1", response.Header().Get("Location"))
+	assert.Equal(t, "created", response.Body.String())
+}
+
+func TestJSONTimeoutLateWrite(t *testing.T) {
+	proceed := make(chan struct{})
+	written := make(chan error, 1)
+
+	svr := server.New(
+		context.Background(),
+		&server.NoOpRecorder{},
+		server.WithRequestTimeout(20*time.Millisecond),
+		server.WithJSONTimeout(0),
+		server.WithoutCorrelationID(),
+	)
+	svr.Router().Handle("/lookup", http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		<-request.Context().Done()
+		<-proceed
+
+		writer.WriteHeader(http.StatusCreated)
+
+		_, err := writer.Write([]byte(`too late`))
+		written <- err
+	})).Methods(http.MethodGet)
+
+	response := httptest.NewRecorder()
+	svr.ServeHTTP(response, httptest.NewRequest(http.MethodGet, "/lookup", nil))
+
+	close(proceed)
+
+	assert.Equal(t, http.StatusGatewayTimeout, response.Code)
+	assert.Equal(t, "{\"error\":\"request timeout\"}\n", response.Body.String())
+	assert.ErrorIs(t, <-written, http.ErrHandlerTimeout)
+}
+
+func TestJSONTimeoutPanic(t *testing.T) {
+	var buffer bytes.Buffer
+
+	svr := server.New(
+		context.Background(),
+		&server.NoOpRecorder{},
+		server.WithRequestTimeout(time.Second),
+		server.WithJSONTimeout(0),
+	)
+	svr.Router().Handle("/test/{id}", http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+		panic("uh oh!")
+	})).Methods(http.MethodGet)
+
+	response := httptest.NewRecorder()
+
+	assert.NotPanics(t, func() {
+		withLogger(svr, zerolog.New(&buffer).Level(zerolog.ErrorLevel)).ServeHTTP(
+			response,
+			httptest.NewRequest(http.MethodGet, "/test/123", nil),
+		)
+	})
+
+	assert.Equal(t, http.StatusInternalServerError, response.Code)
+	assert.Contains(t, buffer.String(), `"error":"panic: uh oh!"`)
+	assert.Contains(t, buffer.String(), `"path":"/test/{id}"`)
+}
@@ -0,0 +1,41 @@
+package server
+
+import (
+	"crypto/tls"
+	"net"
+	"net/http"
+)
+
+// tlsEnabled reports whether the Server serves HTTPS itself, as set with WithTLS or WithTLSConfig.
+func (s *Server) tlsEnabled() bool {
+	return s.certFile != "" || s.tlsConfig != nil
+}
+
+// observeConnState records TLS handshake results for connections served over TLS, either with WithTLS or through a
+// TLS listener provided with WithListener. Each connection is recorded once, when it closes or is hijacked, so
+// keep-alive connections are not counted per request. Connections that close before completing a handshake are
+// recorded as handshake errors. Recorders that do not implement TLSRecorder are skipped.
+func (s *Server) observeConnState(conn net.Conn, state http.ConnState) {
+	if state != http.StateClosed && state != http.StateHijacked {
+		return
+	}
+
+	recorder, ok := s.recorder.(TLSRecorder)
+	if !ok {
+		return
+	}
+
+	tlsConn, ok := conn.(*tls.Conn)
+	if !ok {
+		return
+	}
+
+	connState := tlsConn.ConnectionState()
+	if !connState.HandshakeComplete {
+		recorder.ObserveTLSHandshakeError()
+
+		return
+	}
+
+	recorder.ObserveTLSHandshake(tls.VersionName(connState.Version), tls.CipherSuiteName(connState.CipherSuite))
+}
@@ -0,0 +1,229 @@
+package server_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"io/fs"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/b-sea/go-server/server"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestCertificate(t *testing.T) tls.Certificate {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+
+	template := &x509.Certificate{ //nolint: exhaustruct
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "localhost"}, //nolint: exhaustruct
+		DNSNames:     []string{"localhost"},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	assert.NoError(t, err)
+
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key} //nolint: exhaustruct
+}
+
+func TestTLSHandshakeMetrics(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	recorder := server.NewPrometheus("test", server.WithRegisterer(registry))
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+
+	tlsListener := tls.NewListener(listener, &tls.Config{ //nolint: exhaustruct
+		Certificates: []tls.Certificate{newTestCertificate(t)},
+		MinVersion:   tls.VersionTLS12,
+	})
+
+	svr := server.New(context.Background(), recorder, server.WithListener(tlsListener))
+
+	go func() {
+		assert.NoError(t, svr.Start(context.Background()))
+	}()
+
+	client := &http.Client{ //nolint: exhaustruct
+		Transport: &http.Transport{ //nolint: exhaustruct
+			TLSClientConfig: &tls.Config{ //nolint: exhaustruct
+				InsecureSkipVerify: true, //nolint: gosec
+				MaxVersion:         tls.VersionTLS12,
+				CipherSuites:       []uint16{tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256},
+			},
+		},
+	}
+
+	get := func(url string, client *http.Client) string {
+		request, _ := http.NewRequestWithContext(context.Background(), http.MethodGet, url, nil)
+		request.Close = true
+
+		response, err := client.Do(request)
+		if err != nil {
+			return ""
+		}
+
+		body, err := io.ReadAll(response.Body)
+		assert.NoError(t, err)
+		assert.NoError(t, response.Body.Close())
+
+		return string(body)
+	}
+
+	cipherSuite := tls.CipherSuiteName(tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256)
+
+	get("https://"+svr.Addr()+"/ping", client)
+	get("http://"+svr.Addr()+"/ping", http.DefaultClient)
+
+	assert.Eventually(t, func() bool {
+		metrics := scrape(t, registry)
+
+		return strings.Contains(metrics, `test_tls_handshakes_total{cipher_suite="`+cipherSuite+`",version="TLS 1.2"}`) &&
+			strings.Contains(metrics, "test_tls_handshake_errors_total 1")
+	}, 5*time.Second, 10*time.Millisecond)
+
+	assert.NoError(t, svr.Stop(context.Background()))
+}
+
+func writeTestCertificate(t *testing.T, certificate tls.Certificate) (string, string) {
+	t.Helper()
+
+	key, err := x509.MarshalECPrivateKey(certificate.PrivateKey.(*ecdsa.PrivateKey))
+	assert.NoError(t, err)
+
+	certFile := filepath.Join(t.TempDir(), "cert.pem")
+	keyFile := filepath.Join(t.TempDir(), "key.pem")
+
+	certPEM := pem.EncodeToMemory(&pem.Block{ //nolint: exhaustruct
+		Type:  "CERTIFICATE",
+		Bytes: certificate.Certificate[0],
+	})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: key}) //nolint: exhaustruct
+
+	assert.NoError(t, os.WriteFile(certFile, certPEM, 0o600))
+	assert.NoError(t, os.WriteFile(keyFile, keyPEM, 0o600))
+
+	return certFile, keyFile
+}
+
+func TestServerTLS(t *testing.T) {
+	type testCase struct {
+		option func(t *testing.T, certificate tls.Certificate) server.Option
+	}
+
+	tests := map[string]testCase{
+		"certificate files": {
+			option: func(t *testing.T, certificate tls.Certificate) server.Option {
+				t.Helper()
+
+				return server.WithTLS(writeTestCertificate(t, certificate))
+			},
+		},
+		"tls config": {
+			option: func(_ *testing.T, certificate tls.Certificate) server.Option {
+				return server.WithTLSConfig(&tls.Config{ //nolint: exhaustruct
+					Certificates: []tls.Certificate{certificate},
+					MinVersion:   tls.VersionTLS12,
+				})
+			},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			var logs safeBuffer
+
+			ctx := zerolog.New(&logs).WithContext(context.Background())
+			certificate := newTestCertificate(t)
+			port := findOpenPort(t)
+
+			svr := server.New(ctx, &server.NoOpRecorder{}, server.WithPort(port), test.option(t, certificate))
+
+			done := make(chan error)
+
+			go func() {
+				done <- svr.Start(ctx)
+			}()
+
+			leaf, err := x509.ParseCertificate(certificate.Certificate[0])
+			assert.NoError(t, err)
+
+			pool := x509.NewCertPool()
+			pool.AddCert(leaf)
+
+			client := &http.Client{ //nolint: exhaustruct
+				Transport: &http.Transport{ //nolint: exhaustruct
+					TLSClientConfig: &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12}, //nolint: exhaustruct
+				},
+			}
+
+			var response *http.Response
+
+			assert.Eventually(t, func() bool {
+				request, _ := http.NewRequestWithContext(
+					context.Background(),
+					http.MethodGet,
+					fmt.Sprintf("https://localhost:%d/ping", port),
+					nil,
+				)
+				request.Close = true
+
+				var err error
+
+				response, err = client.Do(request)
+
+				return err == nil
+			}, 5*time.Second, 10*time.Millisecond)
+
+			if assert.NotNil(t, response) {
+				body, err := io.ReadAll(response.Body)
+				assert.NoError(t, err)
+				assert.NoError(t, response.Body.Close())
+
+				assert.Equal(t, http.StatusOK, response.StatusCode)
+				assert.Equal(t, "pong", string(body))
+				assert.NotNil(t, response.TLS)
+			}
+
+			assert.NoError(t, svr.Stop(ctx))
+			assert.NoError(t, <-done)
+
+			assert.Contains(t, logs.String(), `"tls":true`)
+		})
+	}
+}
+
+func TestServerTLSMissingCertificate(t *testing.T) {
+	svr := server.New(
+		context.Background(),
+		&server.NoOpRecorder{},
+		server.WithPort(findOpenPort(t)),
+		server.WithTLS(filepath.Join(t.TempDir(), "cert.pem"), filepath.Join(t.TempDir(), "key.pem")),
+	)
+
+	assert.ErrorIs(t, svr.Start(context.Background()), fs.ErrNotExist)
+}
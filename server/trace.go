@@ -0,0 +1,93 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"strings"
+)
+
+const (
+	traceparentHeader  = "Traceparent"
+	traceparentVersion = "00"
+	sampledFlags       = "01"
+
+	traceIDBytes = 16
+	spanIDBytes  = 8
+)
+
+type traceparentKey struct{}
+
+// Traceparent is a W3C trace context traceparent value.
+type Traceparent struct {
+	TraceID string
+	SpanID  string
+	Flags   string
+}
+
+func (t Traceparent) String() string {
+	return traceparentVersion + "-" + t.TraceID + "-" + t.SpanID + "-" + t.Flags
+}
+
+// TraceparentFromContext returns the traceparent of the request, if tracing is enabled with WithTraceparent.
+func TraceparentFromContext(ctx context.Context) (Traceparent, bool) {
+	traceparent, ok := ctx.Value(traceparentKey{}).(Traceparent)
+
+	return traceparent, ok
+}
+
+// newTraceparent continues the trace of a valid incoming traceparent with a new span, or starts a new sampled
+// trace.
+func newTraceparent(incoming string) Traceparent {
+	if parent, ok := parseTraceparent(incoming); ok {
+		parent.SpanID = randomHex(spanIDBytes)
+
+		return parent
+	}
+
+	return Traceparent{
+		TraceID: randomHex(traceIDBytes),
+		SpanID:  randomHex(spanIDBytes),
+		Flags:   sampledFlags,
+	}
+}
+
+func parseTraceparent(value string) (Traceparent, bool) {
+	parts := strings.Split(strings.TrimSpace(value), "-")
+	if len(parts) < 4 || !isHex(parts[0], 1) || parts[0] == "ff" {
+		return Traceparent{}, false
+	}
+
+	// Only version 00 is fully specified; later versions may append fields.
+	if parts[0] == traceparentVersion && len(parts) != 4 {
+		return Traceparent{}, false
+	}
+
+	traceparent := Traceparent{TraceID: parts[1], SpanID: parts[2], Flags: parts[3]}
+
+	if !isHex(traceparent.TraceID, traceIDBytes) || traceparent.TraceID == strings.Repeat("0", 2*traceIDBytes) ||
+		!isHex(traceparent.SpanID, spanIDBytes) || traceparent.SpanID == strings.Repeat("0", 2*spanIDBytes) ||
+		!isHex(traceparent.Flags, 1) {
+		return Traceparent{}, false
+	}
+
+	return traceparent, true
+}
+
+// isHex reports whether value is exactly size bytes of lowercase hex.
+func isHex(value string, size int) bool {
+	if len(value) != 2*size || strings.ToLower(value) != value {
+		return false
+	}
+
+	_, err := hex.DecodeString(value)
+
+	return err == nil
+}
+
+func randomHex(size int) string {
+	data := make([]byte, size)
+	_, _ = rand.Read(data)
+
+	return hex.EncodeToString(data)
+}
@@ -0,0 +1,109 @@
+package server_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/b-sea/go-server/server"
+	"github.com/stretchr/testify/assert"
+)
+
+var traceparentPattern = regexp.MustCompile(`^00-[0-9a-f]{32}-[0-9a-f]{16}-[0-9a-f]{2}$`)
+
+func TestTraceparent(t *testing.T) {
+	type testCase struct {
+		incoming string
+		traceID  string
+		flags    string
+	}
+
+	tests := map[string]testCase{
+		"no incoming": {
+			incoming: "",
+			traceID:  "",
+			flags:    "01",
+		},
+		"incoming": {
+			incoming: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00",
+			traceID:  "4bf92f3577b34da6a3ce929d0e0e4736",
+			flags:    "00",
+		},
+		"incoming future version": {
+			incoming: "01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra",
+			traceID:  "4bf92f3577b34da6a3ce929d0e0e4736",
+			flags:    "01",
+		},
+		"invalid incoming": {
+			incoming: "00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01",
+			traceID:  "",
+			flags:    "01",
+		},
+		"zero trace id": {
+			incoming: "00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+			traceID:  "",
+			flags:    "01",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			var fromContext string
+
+			svr := server.New(context.Background(), &server.NoOpRecorder{}, server.WithTraceparent())
+			svr.Router().Handle("/test", http.HandlerFunc(func(_ http.ResponseWriter, request *http.Request) {
+				traceparent, ok := server.TraceparentFromContext(request.Context())
+				assert.True(t, ok)
+
+				fromContext = traceparent.String()
+			})).Methods(http.MethodGet)
+
+			testServer := httptest.NewServer(svr)
+
+			request, _ := http.NewRequestWithContext(context.Background(), http.MethodGet, testServer.URL+"/test", nil)
+			request.Close = true
+
+			if test.incoming != "" {
+				request.Header.Set("Traceparent", test.incoming)
+			}
+
+			response, err := http.DefaultClient.Do(request)
+			assert.NoError(t, err)
+			assert.NoError(t, response.Body.Close())
+
+			traceparent := response.Header.Get("Traceparent")
+			assert.Regexp(t, traceparentPattern, traceparent)
+			assert.Equal(t, traceparent, fromContext)
+
+			parts := strings.Split(traceparent, "-")
+			assert.Equal(t, test.flags, parts[3])
+
+			if test.traceID != "" {
+				assert.Equal(t, test.traceID, parts[1])
+				assert.NotEqual(t, "00f067aa0ba902b7", parts[2])
+			} else if test.incoming != "" {
+				assert.NotContains(t, test.incoming, parts[1])
+			}
+
+			testServer.Close()
+		})
+	}
+}
+
+func TestTraceparentDisabled(t *testing.T) {
+	testServer := httptest.NewServer(server.New(context.Background(), &server.NoOpRecorder{}))
+
+	request, _ := http.NewRequestWithContext(context.Background(), http.MethodGet, testServer.URL+"/ping", nil)
+	request.Close = true
+
+	response, err := http.DefaultClient.Do(request)
+	assert.NoError(t, err)
+	assert.NoError(t, response.Body.Close())
+
+	assert.Empty(t, response.Header.Get("Traceparent"))
+
+	testServer.Close()
+}
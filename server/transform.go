@@ -0,0 +1,133 @@
+package server
+
+import (
+	"bytes"
+	"net/http"
+)
+
+// maxTransformSize is the largest response buffered for a ResponseTransformer. Larger responses pass through as is.
+const maxTransformSize = 1 << 20
+
+// ResponseTransformer rewrites a complete response body before it is sent. Responses that already set a
+// Content-Encoding, and responses that cannot have a body, such as 204 No Content, 304 Not Modified, and replies to
+// HEAD requests, are sent untransformed.
+type ResponseTransformer func(status int, contentType string, body []byte) []byte
+
+type transformWriter struct {
+	http.ResponseWriter
+
+	transform   ResponseTransformer
+	statusCode  int
+	buffer      bytes.Buffer
+	wroteHeader bool
+	passthrough bool
+}
+
+func (w *transformWriter) WriteHeader(statusCode int) {
+	if w.passthrough || statusCode < http.StatusOK {
+		w.ResponseWriter.WriteHeader(statusCode)
+
+		return
+	}
+
+	if w.wroteHeader {
+		return
+	}
+
+	w.wroteHeader = true
+	w.statusCode = statusCode
+
+	// Encoded bodies, such as gzipped metrics, cannot be transformed without corrupting them
+	if w.Header().Get(contentEncodingHeader) != "" {
+		w.startPassthrough()
+	}
+}
+
+func (w *transformWriter) Write(p []byte) (int, error) {
+	if !w.wroteHeader {
+		// Sniff the content type from the original body, since it is what the transformer sees.
+		if w.Header().Get("Content-Type") == "" {
+			w.Header().Set("Content-Type", http.DetectContentType(p))
+		}
+
+		w.WriteHeader(http.StatusOK)
+	}
+
+	if !w.passthrough && w.buffer.Len()+len(p) > maxTransformSize {
+		w.startPassthrough()
+	}
+
+	if w.passthrough {
+		return w.ResponseWriter.Write(p) //nolint: wrapcheck
+	}
+
+	return w.buffer.Write(p) //nolint: wrapcheck
+}
+
+// Flush marks the response as streaming, sending it untransformed from then on.
+func (w *transformWriter) Flush() {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+
+	if !w.passthrough {
+		w.startPassthrough()
+	}
+
+	_ = http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (w *transformWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// startPassthrough sends the buffered response as is and stops buffering.
+func (w *transformWriter) startPassthrough() {
+	w.passthrough = true
+
+	w.ResponseWriter.WriteHeader(w.statusCode)
+	_, _ = w.ResponseWriter.Write(w.buffer.Bytes())
+	w.buffer.Reset()
+}
+
+// finish transforms the buffered response and sends it.
+func (w *transformWriter) finish() {
+	if w.passthrough || !w.wroteHeader {
+		return
+	}
+
+	if w.statusCode == http.StatusNoContent || w.statusCode == http.StatusNotModified {
+		w.ResponseWriter.WriteHeader(w.statusCode)
+
+		return
+	}
+
+	body := w.transform(w.statusCode, w.Header().Get("Content-Type"), w.buffer.Bytes())
+
+	w.Header().Del("Content-Length")
+	w.ResponseWriter.WriteHeader(w.statusCode)
+	_, _ = w.ResponseWriter.Write(body)
+}
+
+func (s *Server) transformMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		if request.Method == http.MethodHead {
+			next.ServeHTTP(writer, request)
+
+			return
+		}
+
+		transform := &transformWriter{
+			ResponseWriter: writer,
+			transform:      s.responseTransformer,
+			statusCode:     http.StatusOK,
+			buffer:         bytes.Buffer{},
+			wroteHeader:    false,
+			passthrough:    false,
+		}
+
+		next.ServeHTTP(transform, request)
+		transform.finish()
+	})
+}
@@ -0,0 +1,157 @@
+package server_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/b-sea/go-server/server"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResponseTransformer(t *testing.T) {
+	type testCase struct {
+		handler      http.HandlerFunc
+		expectedCode int
+		expectedBody string
+	}
+
+	large := strings.Repeat("a", 2<<20)
+
+	tests := map[string]testCase{
+		"json": {
+			handler: func(writer http.ResponseWriter, _ *http.Request) {
+				writer.Header().Set("Content-Type", "application/json")
+				writer.WriteHeader(http.StatusCreated)
+				_, _ = writer.Write([]byte(`{"name":"test"}`))
+			},
+			expectedCode: http.StatusCreated,
+			expectedBody: `{"injected":true,"name":"test"}`,
+		},
+		"not json": {
+			handler: func(writer http.ResponseWriter, _ *http.Request) {
+				_, _ = writer.Write([]byte(`{"name":"test"}`))
+			},
+			expectedCode: http.StatusOK,
+			expectedBody: `{"name":"test"}`,
+		},
+		"streaming": {
+			handler: func(writer http.ResponseWriter, _ *http.Request) {
+				writer.Header().Set("Content-Type", "application/json")
+				_, _ = writer.Write([]byte(`{"name":`))
+				writer.(http.Flusher).Flush()
+				_, _ = writer.Write([]byte(`"test"}`))
+			},
+			expectedCode: http.StatusOK,
+			expectedBody: `{"name":"test"}`,
+		},
+		"too large": {
+			handler: func(writer http.ResponseWriter, _ *http.Request) {
+				writer.Header().Set("Content-Type", "application/json")
+				_, _ = writer.Write([]byte(large))
+			},
+			expectedCode: http.StatusOK,
+			expectedBody: large,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			recorder := &SpyRecorder{}
+			svr := server.New(
+				context.Background(),
+				recorder,
+				server.WithResponseTransformer(func(_ int, contentType string, body []byte) []byte {
+					if contentType != "application/json" {
+						return body
+					}
+
+					return append([]byte(`{"injected":true,`), bytes.TrimPrefix(body, []byte(`{`))...)
+				}),
+			)
+
+			svr.Router().Handle("/test", test.handler).Methods(http.MethodGet)
+
+			testServer := httptest.NewServer(svr)
+
+			request, _ := http.NewRequestWithContext(context.Background(), http.MethodGet, testServer.URL+"/test", nil)
+			request.Close = true
+
+			response, err := http.DefaultClient.Do(request)
+			assert.NoError(t, err)
+
+			body, err := io.ReadAll(response.Body)
+			assert.NoError(t, err)
+
+			assert.NoError(t, response.Body.Close())
+
+			assert.Equal(t, test.expectedCode, response.StatusCode)
+			assert.Equal(t, test.expectedBody, string(body))
+
+			testServer.Close()
+
+			sizes := recorder.SizeObservations()
+			if assert.Len(t, sizes, 1) {
+				assert.Equal(t, float64(len(test.expectedBody)), sizes[0].Value)
+			}
+		})
+	}
+}
+
+func TestResponseTransformerNoBody(t *testing.T) {
+	type testCase struct {
+		method string
+		code   int
+	}
+
+	tests := map[string]testCase{
+		"no content": {
+			method: http.MethodPost,
+			code:   http.StatusNoContent,
+		},
+		"not modified": {
+			method: http.MethodGet,
+			code:   http.StatusNotModified,
+		},
+		"head": {
+			method: http.MethodHead,
+			code:   http.StatusOK,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			transformed := false
+
+			svr := server.New(
+				context.Background(),
+				&server.NoOpRecorder{},
+				server.WithResponseTransformer(func(int, string, []byte) []byte {
+					transformed = true
+
+					return []byte(`{"injected":true}`)
+				}),
+			)
+			svr.Router().Handle("/test", http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+				writer.Header().Set("Content-Type", "application/json")
+				writer.WriteHeader(test.code)
+
+				// The server drops the body of a HEAD response, so handlers may write it anyway
+				if request.Method == http.MethodHead {
+					_, _ = writer.Write([]byte(`{"name":"test"}`))
+				}
+			})).Methods(test.method)
+
+			response := httptest.NewRecorder()
+			svr.ServeHTTP(response, httptest.NewRequest(test.method, "/test", nil))
+
+			assert.Equal(t, test.code, response.Code)
+			assert.NotContains(t, response.Body.String(), "injected")
+			assert.False(t, transformed)
+		})
+	}
+}